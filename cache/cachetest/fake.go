@@ -0,0 +1,491 @@
+package cachetest
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/clock"
+)
+
+type FakeOptions[K comparable, V any] struct {
+	Loader cache.Loader[K, V]
+	TTL    time.Duration
+	Clock  clock.Clock
+}
+
+type FakeOption[K comparable, V any] func(*FakeOptions[K, V])
+
+// WithLoader sets the Loader called on misses.
+func WithLoader[K comparable, V any](loader cache.Loader[K, V]) FakeOption[K, V] {
+	return func(o *FakeOptions[K, V]) {
+		o.Loader = loader
+	}
+}
+
+// WithTTL sets the TTL of the writes without one. By default they don't expire.
+func WithTTL[K comparable, V any](ttl time.Duration) FakeOption[K, V] {
+	return func(o *FakeOptions[K, V]) {
+		o.TTL = ttl
+	}
+}
+
+// WithClock sets the clock the expiries are checked against, so that tests can
+// move time forward instead of sleeping.
+func WithClock[K comparable, V any](c clock.Clock) FakeOption[K, V] {
+	return func(o *FakeOptions[K, V]) {
+		o.Clock = c
+	}
+}
+
+type fakeKey[K comparable] struct {
+	key  K
+	hash bool
+}
+
+type fakeEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// FakeStore is an in-memory cache.Store that behaves like the Redis store and
+// passes the Suite, for unit tests that shouldn't need Redis. The values are
+// stored as they are, not copied. Expired entries are dropped when they are
+// accessed.
+type FakeStore[K comparable, V any] struct {
+	opts *FakeOptions[K, V]
+
+	mu     sync.Mutex
+	values map[K]fakeEntry[V]
+	hashes map[K]fakeEntry[map[K]V]
+	tags   map[string]map[fakeKey[K]]struct{}
+}
+
+var _ cache.Store[string, int] = (*FakeStore[string, int])(nil)
+
+func NewFakeStore[K comparable, V any](opts ...FakeOption[K, V]) *FakeStore[K, V] {
+	options := &FakeOptions[K, V]{
+		Clock: clock.NewRealClock(),
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &FakeStore[K, V]{
+		opts:   options,
+		values: make(map[K]fakeEntry[V]),
+		hashes: make(map[K]fakeEntry[map[K]V]),
+		tags:   make(map[string]map[fakeKey[K]]struct{}),
+	}
+}
+
+func (f *FakeStore[K, V]) Get(ctx context.Context, key K) (V, error) {
+	f.mu.Lock()
+	entry, ok := f.value(key)
+	f.mu.Unlock()
+	if ok {
+		return entry.value, nil
+	}
+
+	var zero V
+	if f.opts.Loader == nil || cache.LoaderSkipped(ctx) {
+		return zero, cache.ErrorKeyNotFound
+	}
+	value, err := f.opts.Loader.Load(ctx, f, key)
+	if err != nil {
+		return zero, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.setLoaded(key, value)
+	return value, nil
+}
+
+// setLoaded writes a loaded value with the TTL chosen by a cache.TTLLoader,
+// unless the Loader already wrote it through the store. It is called with mu
+// held.
+func (f *FakeStore[K, V]) setLoaded(key K, value V) {
+	if _, ok := f.value(key); ok {
+		return
+	}
+	var opts []cache.WriteOption
+	if ttl := cache.LoadTTL(f.opts.Loader, key, value); ttl > 0 {
+		opts = append(opts, cache.WithTTL(ttl))
+	}
+	f.set(key, value, opts)
+}
+
+func (f *FakeStore[K, V]) Set(ctx context.Context, key K, value V, opts ...cache.WriteOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.set(key, value, opts)
+	return nil
+}
+
+func (f *FakeStore[K, V]) SetNX(ctx context.Context, key K, value V, opts ...cache.WriteOption) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.value(key); ok {
+		return false, nil
+	}
+	f.set(key, value, opts)
+	return true, nil
+}
+
+// CompareAndSet writes value when key holds a value deeply equal to old.
+func (f *FakeStore[K, V]) CompareAndSet(ctx context.Context, key K, old, value V, opts ...cache.WriteOption) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if entry, ok := f.value(key); !ok || !reflect.DeepEqual(entry.value, old) {
+		return false, nil
+	}
+	f.set(key, value, opts)
+	return true, nil
+}
+
+// CompareAndDelete deletes key when it holds a value deeply equal to old.
+func (f *FakeStore[K, V]) CompareAndDelete(ctx context.Context, key K, old V) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if entry, ok := f.value(key); !ok || !reflect.DeepEqual(entry.value, old) {
+		return false, nil
+	}
+	f.delete(key)
+	return true, nil
+}
+
+// BulkGet loads the missing keys with Loader.BulkLoad and caches the values it
+// returns like Get does.
+func (f *FakeStore[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	result := make(map[K]V, len(keys))
+	var missing []K
+	f.mu.Lock()
+	for _, key := range keys {
+		if entry, ok := f.value(key); ok {
+			result[key] = entry.value
+		} else {
+			missing = append(missing, key)
+		}
+	}
+	f.mu.Unlock()
+
+	if len(missing) == 0 || f.opts.Loader == nil || cache.LoaderSkipped(ctx) {
+		return result, nil
+	}
+
+	loaded, err := f.opts.Loader.BulkLoad(ctx, f, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for key, value := range loaded {
+		f.setLoaded(key, value)
+	}
+	for _, key := range missing {
+		if value, ok := loaded[key]; ok {
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+func (f *FakeStore[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, keyVal := range keyVals {
+		f.set(keyVal.Key, keyVal.Value, opts)
+	}
+	return nil
+}
+
+func (f *FakeStore[K, V]) Delete(ctx context.Context, keys ...K) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, key := range keys {
+		f.delete(key)
+	}
+	return nil
+}
+
+// Incr adds value to an integer V. A missing key starts from 0 without expiry,
+// like Redis; an existing key keeps its TTL.
+func (f *FakeStore[K, V]) Incr(ctx context.Context, key K, value int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, _ := f.value(key)
+	current := reflect.ValueOf(&entry.value).Elem()
+
+	var result int64
+	switch current.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		result = current.Int() + value
+		current.SetInt(result)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		result = int64(current.Uint()) + value // nolint: gosec
+		current.SetUint(uint64(result))        // nolint: gosec
+	default:
+		return 0, &cache.UnsupportedError{Op: "incr", Reason: "value is not an integer"}
+	}
+
+	f.values[key] = entry
+	return result, nil
+}
+
+// Expire sets the TTL of a key or a hash. A non-positive TTL deletes it.
+func (f *FakeStore[K, V]) Expire(ctx context.Context, key K, expireTime time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entry, okValue := f.value(key)
+	hash, okHash := f.hash(key)
+	switch {
+	case !okValue && !okHash:
+		return cache.ErrorKeyNotFound
+	case expireTime <= 0:
+		f.delete(key)
+	case okValue:
+		entry.expiresAt = f.opts.Clock.Now().Add(expireTime)
+		f.values[key] = entry
+	default:
+		hash.expiresAt = f.opts.Clock.Now().Add(expireTime)
+		f.hashes[key] = hash
+	}
+	return nil
+}
+
+// TTL returns the remaining TTL of a key or a hash, or -1 when it has no expiry.
+func (f *FakeStore[K, V]) TTL(ctx context.Context, key K) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var expiresAt time.Time
+	if entry, ok := f.value(key); ok {
+		expiresAt = entry.expiresAt
+	} else if hash, ok := f.hash(key); ok {
+		expiresAt = hash.expiresAt
+	} else {
+		return 0, cache.ErrorKeyNotFound
+	}
+
+	if expiresAt.IsZero() {
+		return -1, nil
+	}
+	return expiresAt.Sub(f.opts.Clock.Now()), nil
+}
+
+func (f *FakeStore[K, V]) HSet(ctx context.Context, key K, keyVals ...cache.KeyVal[K, V]) error {
+	return f.HSetWithOptions(ctx, key, keyVals)
+}
+
+// HSetWithOptions sets the fields of the hash. The TTL applies to the whole hash.
+func (f *FakeStore[K, V]) HSetWithOptions(ctx context.Context, key K, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hash, _ := f.hash(key)
+	fields := make(map[K]V, len(hash.value)+len(keyVals))
+	maps.Copy(fields, hash.value)
+	for _, keyVal := range keyVals {
+		fields[keyVal.Key] = keyVal.Value
+	}
+
+	o := cache.NewWriteOptions(f.opts.TTL, opts...)
+	f.hashes[key] = fakeEntry[map[K]V]{value: fields, expiresAt: f.expiresAt(o, hash.expiresAt)}
+	f.tag(fakeKey[K]{key: key, hash: true}, o.Tags)
+	return nil
+}
+
+func (f *FakeStore[K, V]) HGet(ctx context.Context, key, field K) (V, error) {
+	fields, err := f.HGetAll(ctx, key)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	value, ok := fields[field]
+	if !ok {
+		return value, cache.ErrorKeyNotFound
+	}
+	return value, nil
+}
+
+// HGetAll calls Loader.LoadAll when the hash is missing and caches the loaded
+// fields.
+func (f *FakeStore[K, V]) HGetAll(ctx context.Context, key K) (map[K]V, error) {
+	f.mu.Lock()
+	hash, ok := f.hash(key)
+	f.mu.Unlock()
+	if ok {
+		return maps.Clone(hash.value), nil
+	}
+
+	if f.opts.Loader == nil || cache.LoaderSkipped(ctx) {
+		return nil, cache.ErrorKeyNotFound
+	}
+	fields, err := f.opts.Loader.LoadAll(ctx, f, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, cache.ErrorKeyNotFound
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok = f.hash(key); !ok {
+		f.hashes[key] = fakeEntry[map[K]V]{value: maps.Clone(fields), expiresAt: f.expiresAt(cache.NewWriteOptions(f.opts.TTL), time.Time{})}
+	}
+	return fields, nil
+}
+
+func (f *FakeStore[K, V]) HDel(ctx context.Context, key K, fields ...K) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	hash, ok := f.hash(key)
+	if !ok {
+		return nil
+	}
+
+	remaining := maps.Clone(hash.value)
+	for _, field := range fields {
+		delete(remaining, field)
+	}
+	// A hash without fields doesn't exist.
+	if len(remaining) == 0 {
+		delete(f.hashes, key)
+		f.untag(fakeKey[K]{key: key, hash: true})
+		return nil
+	}
+	hash.value = remaining
+	f.hashes[key] = hash
+	return nil
+}
+
+func (f *FakeStore[K, V]) InvalidateTags(ctx context.Context, tags ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, tag := range tags {
+		for key := range f.tags[tag] {
+			if key.hash {
+				delete(f.hashes, key.key)
+			} else {
+				delete(f.values, key.key)
+			}
+			f.untag(key)
+		}
+	}
+	return nil
+}
+
+func (f *FakeStore[K, V]) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (f *FakeStore[K, V]) Close() {}
+
+// Len returns the number of keys and hashes that haven't expired.
+func (f *FakeStore[K, V]) Len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for key := range f.values {
+		if _, ok := f.value(key); ok {
+			n++
+		}
+	}
+	for key := range f.hashes {
+		if _, ok := f.hash(key); ok {
+			n++
+		}
+	}
+	return n
+}
+
+// value returns the entry of key, dropping it if it expired. f.mu must be held.
+func (f *FakeStore[K, V]) value(key K) (fakeEntry[V], bool) {
+	entry, ok := f.values[key]
+	if ok && f.expired(entry.expiresAt) {
+		delete(f.values, key)
+		f.untag(fakeKey[K]{key: key})
+		return fakeEntry[V]{}, false
+	}
+	return entry, ok
+}
+
+func (f *FakeStore[K, V]) hash(key K) (fakeEntry[map[K]V], bool) {
+	hash, ok := f.hashes[key]
+	if ok && f.expired(hash.expiresAt) {
+		delete(f.hashes, key)
+		f.untag(fakeKey[K]{key: key, hash: true})
+		return fakeEntry[map[K]V]{}, false
+	}
+	return hash, ok
+}
+
+func (f *FakeStore[K, V]) set(key K, value V, opts []cache.WriteOption) {
+	o := cache.NewWriteOptions(f.opts.TTL, opts...)
+	previous, _ := f.value(key)
+	f.values[key] = fakeEntry[V]{value: value, expiresAt: f.expiresAt(o, previous.expiresAt)}
+	f.tag(fakeKey[K]{key: key}, o.Tags)
+}
+
+func (f *FakeStore[K, V]) delete(key K) {
+	delete(f.values, key)
+	delete(f.hashes, key)
+	f.untag(fakeKey[K]{key: key})
+	f.untag(fakeKey[K]{key: key, hash: true})
+}
+
+// expiresAt resolves the write options into an expiry, the zero time meaning no
+// expiry. previous is the expiry of the current entry, kept with WithKeepTTL.
+func (f *FakeStore[K, V]) expiresAt(o *cache.WriteOptions, previous time.Time) time.Time {
+	switch {
+	case o.KeepTTL:
+		return previous
+	case o.NoExpiry, o.TTL <= 0:
+		return time.Time{}
+	}
+	return f.opts.Clock.Now().Add(o.TTL)
+}
+
+func (f *FakeStore[K, V]) expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !f.opts.Clock.Now().Before(expiresAt)
+}
+
+func (f *FakeStore[K, V]) tag(key fakeKey[K], tags []string) {
+	for _, tag := range tags {
+		if f.tags[tag] == nil {
+			f.tags[tag] = make(map[fakeKey[K]]struct{})
+		}
+		f.tags[tag][key] = struct{}{}
+	}
+}
+
+func (f *FakeStore[K, V]) untag(key fakeKey[K]) {
+	for tag, keys := range f.tags {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(f.tags, tag)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package cachetest
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+func TestFakeStore(t *testing.T) {
+	Suite[string, int64]{
+		NewStore: func(t *testing.T, loader cache.Loader[string, int64]) cache.Store[string, int64] {
+			return NewFakeStore[string, int64](WithLoader[string, int64](loader))
+		},
+		Key:   strconv.Itoa,
+		Value: func(i int) int64 { return int64(i) * 10 },
+	}.Run(t)
+}
+
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func TestFakeStore_Clock(t *testing.T) {
+	ctx := context.Background()
+	now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewFakeStore[string, int64](WithClock[string, int64](now), WithTTL[string, int64](time.Minute))
+
+	assert.NoError(t, store.Set(ctx, "a", 1))
+	assert.NoError(t, store.Set(ctx, "b", 2, cache.WithNoExpiry()))
+	assert.Equal(t, 2, store.Len())
+
+	now.now = now.now.Add(time.Minute)
+	_, err := store.Get(ctx, "a")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	assert.Equal(t, 1, store.Len())
+}
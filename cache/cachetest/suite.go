@@ -0,0 +1,502 @@
+package cachetest
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+// Factory returns an empty store that loads the missing keys with loader, nil
+// meaning no Loader. The suite closes the stores.
+type Factory[K comparable, V any] func(t *testing.T, loader cache.Loader[K, V]) cache.Store[K, V]
+
+// Suite is the behavior expected from every cache.Store implementation. Key and
+// Value must return distinct keys and values for distinct i. The Incr cases
+// only run when V is an integer type.
+type Suite[K comparable, V any] struct {
+	NewStore Factory[K, V]
+	Key      func(i int) K
+	Value    func(i int) V
+}
+
+// Run runs the suite, each case on a new store.
+func (s Suite[K, V]) Run(t *testing.T) {
+	t.Run("Get", s.testGet)
+	t.Run("TTL", s.testTTL)
+	t.Run("Expiry", s.testExpiry)
+	t.Run("SetNX", s.testSetNX)
+	t.Run("Bulk", s.testBulk)
+	t.Run("Delete", s.testDelete)
+	t.Run("Incr", s.testIncr)
+	t.Run("Hash", s.testHash)
+	t.Run("Tags", s.testTags)
+	t.Run("CompareAndSwap", s.testCompareAndSwap)
+	t.Run("Loader", s.testLoader)
+	t.Run("TTLLoader", s.testTTLLoader)
+	t.Run("HashLoader", s.testHashLoader)
+}
+
+func (s Suite[K, V]) newStore(t *testing.T, loader cache.Loader[K, V]) cache.Store[K, V] {
+	t.Helper()
+
+	store := s.NewStore(t, loader)
+	require.NotNil(t, store)
+	t.Cleanup(store.Close)
+	return store
+}
+
+func (s Suite[K, V]) testGet(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	require.NoError(t, store.Ping(ctx))
+
+	_, err := store.Get(ctx, s.Key(1))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "missing key")
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(1)))
+	value, err := store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(1), value)
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(2)))
+	value, err = store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(2), value, "Set overwrites")
+}
+
+func (s Suite[K, V]) testTTL(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	_, err := store.TTL(ctx, s.Key(1))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "TTL of a missing key")
+	assert.ErrorIs(t, store.Expire(ctx, s.Key(1), time.Minute), cache.ErrorKeyNotFound, "Expire of a missing key")
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(1), cache.WithTTL(time.Hour)))
+	ttl, err := store.TTL(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Minute))
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(2), cache.WithKeepTTL()))
+	ttl, err = store.TTL(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Minute), "WithKeepTTL keeps the TTL")
+
+	require.NoError(t, store.Set(ctx, s.Key(2), s.Value(2), cache.WithNoExpiry()))
+	ttl, err = store.TTL(ctx, s.Key(2))
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl, "WithNoExpiry")
+
+	require.NoError(t, store.Expire(ctx, s.Key(2), time.Minute))
+	ttl, err = store.TTL(ctx, s.Key(2))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(10*time.Second))
+
+	require.NoError(t, store.Expire(ctx, s.Key(2), 0))
+	_, err = store.Get(ctx, s.Key(2))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "a non-positive Expire deletes")
+}
+
+func (s Suite[K, V]) testExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(1), cache.WithTTL(50*time.Millisecond)))
+	require.NoError(t, cache.HSetWithOptions(ctx, store, s.Key(2), []cache.KeyVal[K, V]{{Key: s.Key(3), Value: s.Value(3)}}, cache.WithTTL(50*time.Millisecond)))
+
+	assert.Eventually(t, func() bool {
+		_, errValue := store.Get(ctx, s.Key(1))
+		_, errHash := store.HGet(ctx, s.Key(2), s.Key(3))
+		return errors.Is(errValue, cache.ErrorKeyNotFound) && errors.Is(errHash, cache.ErrorKeyNotFound)
+	}, 5*time.Second, 10*time.Millisecond, "expired entries are missing")
+}
+
+func (s Suite[K, V]) testSetNX(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	ok, err := store.SetNX(ctx, s.Key(1), s.Value(1))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = store.SetNX(ctx, s.Key(1), s.Value(2))
+	require.NoError(t, err)
+	assert.False(t, ok, "SetNX of an existing key")
+
+	value, err := store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(1), value)
+}
+
+func (s Suite[K, V]) testBulk(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	values, err := store.BulkGet(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+
+	require.NoError(t, cache.BulkSet(ctx, store, []cache.KeyVal[K, V]{
+		{Key: s.Key(1), Value: s.Value(1)},
+		{Key: s.Key(2), Value: s.Value(2)},
+	}, cache.WithTTL(time.Hour)))
+
+	values, err = store.BulkGet(ctx, []K{s.Key(1), s.Key(2), s.Key(3)})
+	require.NoError(t, err)
+	assert.Equal(t, map[K]V{s.Key(1): s.Value(1), s.Key(2): s.Value(2)}, values, "missing keys are left out")
+
+	ttl, err := store.TTL(ctx, s.Key(2))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Minute), "BulkSet applies the write options")
+}
+
+func (s Suite[K, V]) testDelete(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(1)))
+	require.NoError(t, store.Set(ctx, s.Key(2), s.Value(2)))
+	require.NoError(t, store.HSet(ctx, s.Key(3), cache.KeyVal[K, V]{Key: s.Key(4), Value: s.Value(4)}))
+
+	require.NoError(t, store.Delete(ctx, s.Key(1), s.Key(3), s.Key(5)), "missing keys are ignored")
+
+	_, err := store.Get(ctx, s.Key(1))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	_, err = store.HGetAll(ctx, s.Key(3))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "Delete removes hashes")
+	value, err := store.Get(ctx, s.Key(2))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(2), value)
+}
+
+func (s Suite[K, V]) testIncr(t *testing.T) {
+	var zero V
+	switch reflect.TypeOf(zero).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		t.Skip("V is not an integer type")
+	}
+
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	n, err := store.Incr(ctx, s.Key(1), 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n, "a missing key starts from 0")
+
+	n, err = store.Incr(ctx, s.Key(1), 3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), n)
+
+	value, err := store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), reflect.ValueOf(value).Convert(reflect.TypeFor[int64]()).Int())
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Go(func() {
+			_, err := store.Incr(ctx, s.Key(1), 1)
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+	n, err = store.Incr(ctx, s.Key(1), 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(18), n, "Incr is atomic")
+}
+
+func (s Suite[K, V]) testHash(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	_, err := store.HGetAll(ctx, s.Key(1))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "missing hash")
+	_, err = store.HGet(ctx, s.Key(1), s.Key(2))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "field of a missing hash")
+
+	require.NoError(t, cache.HSetWithOptions(ctx, store, s.Key(1), []cache.KeyVal[K, V]{
+		{Key: s.Key(2), Value: s.Value(2)},
+		{Key: s.Key(3), Value: s.Value(3)},
+	}, cache.WithTTL(time.Hour)))
+	require.NoError(t, cache.HSetWithOptions(ctx, store, s.Key(1), []cache.KeyVal[K, V]{{Key: s.Key(4), Value: s.Value(4)}}, cache.WithKeepTTL()))
+
+	value, err := store.HGet(ctx, s.Key(1), s.Key(2))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(2), value)
+
+	_, err = store.HGet(ctx, s.Key(1), s.Key(5))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "missing field")
+
+	fields, err := store.HGetAll(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, map[K]V{s.Key(2): s.Value(2), s.Key(3): s.Value(3), s.Key(4): s.Value(4)}, fields, "HSet adds fields")
+
+	ttl, err := store.TTL(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Minute), "the TTL applies to the hash")
+
+	require.NoError(t, store.HDel(ctx, s.Key(1), s.Key(2), s.Key(5)))
+	fields, err = store.HGetAll(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, map[K]V{s.Key(3): s.Value(3), s.Key(4): s.Value(4)}, fields)
+
+	require.NoError(t, store.HDel(ctx, s.Key(1), s.Key(3), s.Key(4)))
+	_, err = store.HGetAll(ctx, s.Key(1))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "a hash without fields doesn't exist")
+	require.NoError(t, store.HDel(ctx, s.Key(1), s.Key(3)), "HDel of a missing hash")
+}
+
+func (s Suite[K, V]) testTags(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(1), cache.WithTags("a")))
+	require.NoError(t, store.Set(ctx, s.Key(2), s.Value(2), cache.WithTags("a", "b")))
+	require.NoError(t, store.Set(ctx, s.Key(3), s.Value(3), cache.WithTags("b")))
+	require.NoError(t, store.Set(ctx, s.Key(4), s.Value(4)))
+	require.NoError(t, cache.HSetWithOptions(ctx, store, s.Key(5), []cache.KeyVal[K, V]{{Key: s.Key(6), Value: s.Value(6)}}, cache.WithTags("a")))
+
+	require.NoError(t, cache.InvalidateTags(ctx, store, "a"))
+	for _, i := range []int{1, 2} {
+		_, err := store.Get(ctx, s.Key(i))
+		assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "key %d is tagged", i)
+	}
+	_, err := store.HGetAll(ctx, s.Key(5))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "hashes are tagged too")
+	for _, i := range []int{3, 4} {
+		_, err = store.Get(ctx, s.Key(i))
+		assert.NoError(t, err, "key %d isn't tagged", i)
+	}
+
+	require.NoError(t, cache.InvalidateTags(ctx, store, "unknown"))
+}
+
+func (s Suite[K, V]) testCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, nil)
+	if _, ok := store.(cache.CompareAndSwapper[K, V]); !ok {
+		t.Skip("the store doesn't implement cache.CompareAndSwapper")
+	}
+
+	ok, err := cache.CompareAndSet(ctx, store, s.Key(1), s.Value(1), s.Value(2))
+	require.NoError(t, err)
+	assert.False(t, ok, "a missing key holds nothing")
+
+	require.NoError(t, store.Set(ctx, s.Key(1), s.Value(1)))
+	ok, err = cache.CompareAndSet(ctx, store, s.Key(1), s.Value(3), s.Value(2))
+	require.NoError(t, err)
+	assert.False(t, ok, "the key holds another value")
+	ok, err = cache.CompareAndSet(ctx, store, s.Key(1), s.Value(1), s.Value(2), cache.WithTTL(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	value, err := store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(2), value)
+	ttl, err := store.TTL(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	ok, err = cache.CompareAndDelete(ctx, store, s.Key(1), s.Value(1))
+	require.NoError(t, err)
+	assert.False(t, ok, "the key holds another value")
+	ok, err = cache.CompareAndDelete(ctx, store, s.Key(1), s.Value(2))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = store.Get(ctx, s.Key(1))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+}
+
+func (s Suite[K, V]) testLoader(t *testing.T) {
+	ctx := context.Background()
+	loader := s.newLoader()
+	store := s.newStore(t, loader)
+
+	value, err := store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(1), value)
+	value, err = store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(1), value)
+	assert.Equal(t, 1, loader.loads(), "a loaded key is cached")
+
+	_, err = store.Get(cache.WithoutLoader(ctx), s.Key(2))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "cache.WithoutLoader skips the Loader")
+	assert.Equal(t, 1, loader.loads(), "cache.WithoutLoader skips the Loader")
+
+	_, err = store.Get(ctx, s.Key(notFoundKey))
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound, "a key missing at the source")
+
+	_, err = store.Get(ctx, s.Key(failingKey))
+	assert.ErrorIs(t, err, errSource, "the Loader error is returned")
+
+	values, err := store.BulkGet(ctx, []K{s.Key(1), s.Key(2), s.Key(notFoundKey)})
+	require.NoError(t, err)
+	assert.Equal(t, map[K]V{s.Key(1): s.Value(1), s.Key(2): s.Value(2)}, values, "BulkGet loads the missing keys")
+}
+
+func (s Suite[K, V]) testTTLLoader(t *testing.T) {
+	ctx := context.Background()
+	store := s.newStore(t, &ttlSuiteLoader[K, V]{suiteLoader: s.newLoader()})
+
+	value, err := store.Get(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(1), value)
+	ttl, err := store.TTL(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(5*time.Second), "Get writes with the TTL of the TTLLoader")
+
+	values, err := store.BulkGet(ctx, []K{s.Key(2), s.Key(3)})
+	require.NoError(t, err)
+	assert.Equal(t, map[K]V{s.Key(2): s.Value(2), s.Key(3): s.Value(3)}, values)
+	ttl, err = store.TTL(ctx, s.Key(3))
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(5*time.Second), "BulkGet writes with the TTL of the TTLLoader")
+}
+
+func (s Suite[K, V]) testHashLoader(t *testing.T) {
+	ctx := context.Background()
+	loader := s.newLoader()
+	store := s.newStore(t, loader)
+
+	fields, err := store.HGetAll(ctx, s.Key(1))
+	require.NoError(t, err)
+	assert.Equal(t, map[K]V{s.Key(2): s.Value(2), s.Key(3): s.Value(3)}, fields)
+
+	value, err := store.HGet(ctx, s.Key(1), s.Key(3))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(3), value)
+	assert.Equal(t, 1, loader.loadAlls(), "a loaded hash is cached")
+
+	value, err = store.HGet(ctx, s.Key(10), s.Key(11))
+	require.NoError(t, err)
+	assert.Equal(t, s.Value(11), value, "HGet loads the hash")
+}
+
+const (
+	notFoundKey = 99
+	failingKey  = 98
+)
+
+var errSource = errors.New("cachetest: source failed")
+
+// suiteLoader is the source of the loader cases. It writes the values it loads,
+// as the Loader contract allows.
+type suiteLoader[K comparable, V any] struct {
+	s Suite[K, V]
+
+	mu       sync.Mutex
+	load     int
+	loadAll  int
+	bulkLoad int
+}
+
+func (s Suite[K, V]) newLoader() *suiteLoader[K, V] {
+	return &suiteLoader[K, V]{s: s}
+}
+
+func (l *suiteLoader[K, V]) source(key K) (V, error) {
+	var zero V
+	switch key {
+	case l.s.Key(notFoundKey):
+		return zero, cache.ErrorKeyNotFound
+	case l.s.Key(failingKey):
+		return zero, errSource
+	}
+	for i := range 20 {
+		if key == l.s.Key(i) {
+			return l.s.Value(i), nil
+		}
+	}
+	return zero, cache.ErrorKeyNotFound
+}
+
+func (l *suiteLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V], key K) (V, error) {
+	l.mu.Lock()
+	l.load++
+	l.mu.Unlock()
+
+	value, err := l.source(key)
+	if err != nil {
+		return value, err
+	}
+	return value, c.Set(ctx, key, value)
+}
+
+// LoadAll returns the fields i+1 and i+2 for the hash i.
+func (l *suiteLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K, V], key K) (map[K]V, error) {
+	l.mu.Lock()
+	l.loadAll++
+	l.mu.Unlock()
+
+	for i := range 20 {
+		if key != l.s.Key(i) {
+			continue
+		}
+		fields := map[K]V{l.s.Key(i + 1): l.s.Value(i + 1), l.s.Key(i + 2): l.s.Value(i + 2)}
+		keyVals := make([]cache.KeyVal[K, V], 0, len(fields))
+		for field, value := range fields {
+			keyVals = append(keyVals, cache.KeyVal[K, V]{Key: field, Value: value})
+		}
+		return fields, c.HSet(ctx, key, keyVals...)
+	}
+	return nil, cache.ErrorKeyNotFound
+}
+
+func (l *suiteLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K, V], keys []K) (map[K]V, error) {
+	l.mu.Lock()
+	l.bulkLoad++
+	l.mu.Unlock()
+
+	values := make(map[K]V, len(keys))
+	for _, key := range keys {
+		value, err := l.source(key)
+		if errors.Is(err, cache.ErrorKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// ttlSuiteLoader is a cache.TTLLoader keeping every value for a minute. It
+// leaves writing the values it loads to the store.
+type ttlSuiteLoader[K comparable, V any] struct {
+	*suiteLoader[K, V]
+}
+
+func (l *ttlSuiteLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V], key K) (V, error) {
+	l.mu.Lock()
+	l.load++
+	l.mu.Unlock()
+
+	return l.source(key)
+}
+
+func (l *ttlSuiteLoader[K, V]) LoadTTL(key K, value V) time.Duration {
+	return time.Minute
+}
+
+func (l *suiteLoader[K, V]) loads() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.load
+}
+
+func (l *suiteLoader[K, V]) loadAlls() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.loadAll
+}
@@ -0,0 +1,29 @@
+package cachehotkey
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type hotKeysResponse[K comparable] struct {
+	Store     string      `json:"store"`
+	Threshold float64     `json:"threshold"`
+	HotKeys   []HotKey[K] `json:"hot_keys"`
+}
+
+// ServeHTTP writes the hot keys as JSON, so that the Store can be mounted as a
+// debug endpoint, e.g. http.Handle("/debug/hotkeys/users", store).
+func (s *Store[K, V]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(hotKeysResponse[K]{
+		Store:     s.name,
+		Threshold: s.opts.Threshold,
+		HotKeys:   s.HotKeys(),
+	})
+}
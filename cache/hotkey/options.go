@@ -0,0 +1,100 @@
+package cachehotkey
+
+import (
+	"time"
+
+	"github.com/trinhdaiphuc/go-kit/clock"
+)
+
+type Options struct {
+	// Threshold is the reads per second above which a key is hot.
+	Threshold float64
+	// Window is the period the reads are counted over.
+	Window time.Duration
+	// LocalTTL is how long a hot key is served from the process before it is
+	// read from the store again.
+	LocalTTL time.Duration
+	// MaxKeys bounds the number of hot keys kept at the same time.
+	MaxKeys int
+	// SketchWidth is the number of counters per row of the count-min sketch.
+	SketchWidth int
+	// SampleRate is the fraction of the reads that are counted.
+	SampleRate float64
+	Clock      clock.Clock
+}
+
+type Option func(*Options)
+
+// WithThreshold sets the reads per second above which a key is promoted into
+// the process, 1000 by default.
+func WithThreshold(qps float64) Option {
+	return func(o *Options) {
+		o.Threshold = qps
+	}
+}
+
+// WithWindow sets the period the reads are counted over, one second by
+// default. A key cools down within two windows of its reads slowing down. A
+// non-positive window is ignored.
+func WithWindow(window time.Duration) Option {
+	return func(o *Options) {
+		if window > 0 {
+			o.Window = window
+		}
+	}
+}
+
+// WithLocalTTL sets how long the value of a hot key is served from the process,
+// one second by default. It bounds how stale the value is after a write made by
+// another process.
+func WithLocalTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.LocalTTL = ttl
+	}
+}
+
+// WithMaxKeys sets how many hot keys are kept at most, 64 by default. When it
+// is reached, a new hot key replaces the coldest one if it is hotter.
+func WithMaxKeys(n int) Option {
+	return func(o *Options) {
+		o.MaxKeys = n
+	}
+}
+
+// WithSketchWidth sets the width of the count-min sketch, 4096 by default. A
+// wider sketch overcounts less when many distinct keys are read.
+func WithSketchWidth(width int) Option {
+	return func(o *Options) {
+		o.SketchWidth = width
+	}
+}
+
+// WithSampleRate counts only a fraction of the reads, between 0 and 1, to lower
+// the overhead on very busy stores. The estimates are scaled back up.
+func WithSampleRate(rate float64) Option {
+	return func(o *Options) {
+		if rate > 0 && rate <= 1 {
+			o.SampleRate = rate
+		}
+	}
+}
+
+// WithClock sets the clock of the windows and the local TTL, so that tests can
+// move time forward instead of sleeping.
+func WithClock(c clock.Clock) Option {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
+func newDefaultOption() *Options {
+	return &Options{
+		Threshold:   1000,
+		Window:      time.Second,
+		LocalTTL:    time.Second,
+		MaxKeys:     64,
+		SketchWidth: 4096,
+		SampleRate:  1,
+		Clock:       clock.NewRealClock(),
+	}
+}
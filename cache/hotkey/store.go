@@ -0,0 +1,434 @@
+// Package cachehotkey detects the hot keys of a cache.Store and shields the
+// store from them. The reads are counted in a count-min sketch; a key read more
+// often than a threshold is promoted into a short-lived in-process copy, so that
+// a single viral key doesn't saturate the Redis shard that holds it.
+package cachehotkey
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/cache/internal/sketch"
+	"github.com/trinhdaiphuc/go-kit/metrics"
+)
+
+// HotKey is a key currently detected as hot.
+type HotKey[K comparable] struct {
+	Key K `json:"key"`
+	// QPS is the estimated reads per second of the key.
+	QPS float64 `json:"qps"`
+	// Since is when the key became hot.
+	Since time.Time `json:"since"`
+}
+
+type hotEntry[V any] struct {
+	// qps holds the bits of the float64 rate, raised without the write lock.
+	qps   atomic.Uint64
+	since time.Time
+	// gen changes whenever the local copy is evicted, so that a read racing
+	// with a write doesn't promote the value it read before the write.
+	gen       uint64
+	value     V
+	cached    bool
+	expiresAt time.Time
+}
+
+func (e *hotEntry[V]) rate() float64 {
+	return math.Float64frombits(e.qps.Load())
+}
+
+func (e *hotEntry[V]) setRate(qps float64) {
+	e.qps.Store(math.Float64bits(qps))
+}
+
+// raise keeps the highest rate seen during the window.
+func (e *hotEntry[V]) raise(qps float64) {
+	for {
+		old := e.qps.Load()
+		if math.Float64frombits(old) >= qps || e.qps.CompareAndSwap(old, math.Float64bits(qps)) {
+			return
+		}
+	}
+}
+
+// window is a counting window. It is replaced, never changed, when it is over.
+type window[K comparable] struct {
+	start    time.Time
+	current  *sketch.Sketch[K]
+	previous *sketch.Sketch[K]
+}
+
+// Store is a cache.Store that serves its hot keys from the process. Get and
+// BulkGet are counted and shielded; the writes through the Store evict the local
+// copy of the keys they change, and InvalidateTags evicts every local copy. A
+// write made by another process is seen once the local copy expires, after
+// LocalTTL at most. The other methods are passed through.
+//
+// The reads are counted in lock-free sketches. The lock is only taken to look
+// the key up while some keys are hot, and to promote or cool keys down.
+type Store[K comparable, V any] struct {
+	cache.Store[K, V]
+	name string
+	opts *Options
+
+	window atomic.Pointer[window[K]]
+	// hotKeys is len(hot), read without the lock.
+	hotKeys atomic.Int64
+
+	mu  sync.RWMutex
+	hot map[K]*hotEntry[V]
+	gen uint64
+}
+
+var _ cache.Store[string, int] = (*Store[string, int])(nil)
+
+// NewStore decorates store to detect its hot keys, labelled by name in the
+// metrics and the debug endpoint.
+func NewStore[K comparable, V any](store cache.Store[K, V], name string, opts ...Option) *Store[K, V] {
+	options := newDefaultOption()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	s := &Store[K, V]{
+		Store: store,
+		name:  name,
+		opts:  options,
+		hot:   make(map[K]*hotEntry[V]),
+	}
+	s.window.Store(&window[K]{
+		start:    options.Clock.Now(),
+		current:  sketch.New[K](options.SketchWidth),
+		previous: sketch.New[K](options.SketchWidth),
+	})
+	return s
+}
+
+func (s *Store[K, V]) Get(ctx context.Context, key K) (V, error) {
+	value, ok, gen := s.access(key)
+	if ok {
+		return value, nil
+	}
+
+	value, err := s.Store.Get(ctx, key)
+	if err == nil && gen != 0 {
+		s.promote(key, value, gen)
+	}
+	return value, err
+}
+
+func (s *Store[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, error) {
+	local := make(map[K]V)
+	gens := make(map[K]uint64)
+	missing := make([]K, 0, len(keys))
+	for _, key := range keys {
+		value, ok, gen := s.access(key)
+		switch {
+		case ok:
+			local[key] = value
+		case gen != 0:
+			gens[key] = gen
+			missing = append(missing, key)
+		default:
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return local, nil
+	}
+
+	values, err := s.Store.BulkGet(ctx, missing)
+	if err != nil {
+		return values, err
+	}
+	for key, gen := range gens {
+		if value, ok := values[key]; ok {
+			s.promote(key, value, gen)
+		}
+	}
+	if values == nil {
+		values = make(map[K]V, len(local))
+	}
+	for key, value := range local {
+		values[key] = value
+	}
+	return values, nil
+}
+
+func (s *Store[K, V]) Set(ctx context.Context, key K, value V, opts ...cache.WriteOption) error {
+	defer s.evict(key)
+	return s.Store.Set(ctx, key, value, opts...)
+}
+
+func (s *Store[K, V]) SetNX(ctx context.Context, key K, value V, opts ...cache.WriteOption) (bool, error) {
+	defer s.evict(key)
+	return s.Store.SetNX(ctx, key, value, opts...)
+}
+
+// BulkSet forwards to the wrapped store, through cache.BulkSet.
+func (s *Store[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	defer func() {
+		for _, kv := range keyVals {
+			s.evict(kv.Key)
+		}
+	}()
+	return cache.BulkSet(ctx, s.Store, keyVals, opts...)
+}
+
+// HSetWithOptions forwards to the wrapped store, hashes aren't shielded.
+func (s *Store[K, V]) HSetWithOptions(ctx context.Context, key K, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	return cache.HSetWithOptions(ctx, s.Store, key, keyVals, opts...)
+}
+
+func (s *Store[K, V]) Delete(ctx context.Context, keys ...K) error {
+	defer s.evict(keys...)
+	return s.Store.Delete(ctx, keys...)
+}
+
+func (s *Store[K, V]) Incr(ctx context.Context, key K, value int64) (int64, error) {
+	defer s.evict(key)
+	return s.Store.Incr(ctx, key, value)
+}
+
+func (s *Store[K, V]) Expire(ctx context.Context, key K, expireTime time.Duration) error {
+	defer s.evict(key)
+	return s.Store.Expire(ctx, key, expireTime)
+}
+
+// InvalidateTags forwards to the wrapped store, through cache.InvalidateTags.
+func (s *Store[K, V]) InvalidateTags(ctx context.Context, tags ...string) error {
+	defer s.evictAll()
+	return cache.InvalidateTags(ctx, s.Store, tags...)
+}
+
+// CompareAndSet forwards to the wrapped store, through cache.CompareAndSet.
+func (s *Store[K, V]) CompareAndSet(ctx context.Context, key K, old, value V, opts ...cache.WriteOption) (bool, error) {
+	defer s.evict(key)
+	return cache.CompareAndSet(ctx, s.Store, key, old, value, opts...)
+}
+
+// CompareAndDelete forwards to the wrapped store, through cache.CompareAndDelete.
+func (s *Store[K, V]) CompareAndDelete(ctx context.Context, key K, old V) (bool, error) {
+	defer s.evict(key)
+	return cache.CompareAndDelete(ctx, s.Store, key, old)
+}
+
+// Close removes the hot keys from the metrics and closes the wrapped store.
+func (s *Store[K, V]) Close() {
+	s.mu.Lock()
+	for key := range s.hot {
+		metrics.CacheHotKeyCooled(s.name, fmt.Sprint(key))
+	}
+	clear(s.hot)
+	s.hotKeys.Store(0)
+	s.mu.Unlock()
+
+	s.Store.Close()
+}
+
+// HotKeys returns the keys currently detected as hot, the hottest first.
+func (s *Store[K, V]) HotKeys() []HotKey[K] {
+	s.rotate(s.opts.Clock.Now())
+	s.mu.RLock()
+	keys := make([]HotKey[K], 0, len(s.hot))
+	for key, e := range s.hot {
+		keys = append(keys, HotKey[K]{Key: key, QPS: e.rate(), Since: e.since})
+	}
+	s.mu.RUnlock()
+
+	slices.SortFunc(keys, func(a, b HotKey[K]) int {
+		return cmp.Compare(b.QPS, a.QPS)
+	})
+	return keys
+}
+
+// access counts a read of key. It returns the local copy of a hot key when there
+// is one, or else the generation to promote the value read from the store with;
+// 0 means that the key isn't hot.
+func (s *Store[K, V]) access(key K) (value V, ok bool, gen uint64) {
+	now := s.opts.Clock.Now()
+	w := s.rotate(now)
+	if s.opts.SampleRate >= 1 || rand.Float64() < s.opts.SampleRate { // nolint: gosec
+		s.count(w, key, now)
+	}
+	if s.hotKeys.Load() == 0 {
+		return value, false, 0
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	e, hot := s.hot[key]
+	if !hot {
+		return value, false, 0
+	}
+	if e.cached && now.Before(e.expiresAt) {
+		return e.value, true, 0
+	}
+	return value, false, e.gen
+}
+
+// count adds a read of key to the sketch of w and heats the key up when its
+// estimated rate crosses the threshold. The rate is weighted across the current
+// and the previous window, like a sliding window.
+func (s *Store[K, V]) count(w *window[K], key K, now time.Time) {
+	count := float64(w.current.Increment(key))
+	elapsed := float64(now.Sub(w.start)) / float64(s.opts.Window)
+	count += float64(w.previous.Estimate(key)) * (1 - elapsed)
+	qps := count / s.opts.SampleRate / s.opts.Window.Seconds()
+	if qps < s.opts.Threshold {
+		return
+	}
+
+	s.mu.RLock()
+	e, ok := s.hot[key]
+	if ok {
+		e.raise(qps)
+	}
+	s.mu.RUnlock()
+	if !ok {
+		s.heat(key, qps, now)
+	}
+}
+
+// heat adds key to the hot keys, in place of the coldest one when there are
+// MaxKeys already.
+func (s *Store[K, V]) heat(key K, qps float64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.hot[key]; ok {
+		e.raise(qps)
+		return
+	}
+	if len(s.hot) >= max(1, s.opts.MaxKeys) {
+		coldest, ok := s.coldest()
+		if !ok || s.hot[coldest].rate() >= qps {
+			return
+		}
+		s.cool(coldest)
+	}
+
+	s.gen++
+	e := &hotEntry[V]{since: now, gen: s.gen}
+	e.setRate(qps)
+	s.hot[key] = e
+	s.hotKeys.Store(int64(len(s.hot)))
+	metrics.CacheHotKey(s.name, fmt.Sprint(key), qps)
+}
+
+// rotate starts a new window when the current one is over, and cools down the
+// keys whose rate over the window that ended fell below the threshold. It
+// returns the window of now.
+func (s *Store[K, V]) rotate(now time.Time) *window[K] {
+	w := s.window.Load()
+	if now.Sub(w.start) < s.opts.Window {
+		return w
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another read may have rotated meanwhile.
+	w = s.window.Load()
+	windows := now.Sub(w.start) / s.opts.Window
+	if windows < 1 {
+		return w
+	}
+
+	// The sketch of the window before the previous one is reused. The reads
+	// still counting in it end up in the new window.
+	next := &window[K]{
+		start:    w.start.Add(windows * s.opts.Window),
+		current:  w.previous,
+		previous: w.current,
+	}
+	next.current.Reset()
+	if windows > 1 {
+		// Nothing was read during the window before the current one.
+		next.previous.Reset()
+	}
+	s.window.Store(next)
+
+	for key, e := range s.hot {
+		e.setRate(float64(next.previous.Estimate(key)) / s.opts.SampleRate / s.opts.Window.Seconds())
+		if e.rate() < s.opts.Threshold {
+			s.cool(key)
+			continue
+		}
+		metrics.CacheHotKey(s.name, fmt.Sprint(key), e.rate())
+	}
+	return next
+}
+
+func (s *Store[K, V]) coldest() (K, bool) {
+	var (
+		coldest K
+		qps     float64
+		found   bool
+	)
+	for key, e := range s.hot {
+		if !found || e.rate() < qps {
+			coldest, qps, found = key, e.rate(), true
+		}
+	}
+	return coldest, found
+}
+
+// cool removes a hot key. s.mu must be held.
+func (s *Store[K, V]) cool(key K) {
+	delete(s.hot, key)
+	s.hotKeys.Store(int64(len(s.hot)))
+	metrics.CacheHotKeyCooled(s.name, fmt.Sprint(key))
+}
+
+// promote keeps the value of a hot key in the process for LocalTTL, unless the
+// key was written or cooled down since it was read.
+func (s *Store[K, V]) promote(key K, value V, gen uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.hot[key]
+	if !ok || e.gen != gen {
+		return
+	}
+	e.value = value
+	e.cached = true
+	e.expiresAt = s.opts.Clock.Now().Add(s.opts.LocalTTL)
+}
+
+func (s *Store[K, V]) evict(keys ...K) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if e, ok := s.hot[key]; ok {
+			s.drop(e)
+		}
+	}
+}
+
+func (s *Store[K, V]) evictAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, e := range s.hot {
+		s.drop(e)
+	}
+}
+
+func (s *Store[K, V]) drop(e *hotEntry[V]) {
+	var zero V
+	s.gen++
+	e.gen = s.gen
+	e.value = zero
+	e.cached = false
+}
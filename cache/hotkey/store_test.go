@@ -0,0 +1,189 @@
+package cachehotkey
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/cache/cachetest"
+)
+
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+// countingStore counts the keys read from the wrapped store.
+type countingStore struct {
+	*cachetest.FakeStore[string, string]
+	reads atomic.Int64
+}
+
+func (c *countingStore) Get(ctx context.Context, key string) (string, error) {
+	c.reads.Add(1)
+	return c.FakeStore.Get(ctx, key)
+}
+
+func (c *countingStore) BulkGet(ctx context.Context, keys []string) (map[string]string, error) {
+	c.reads.Add(int64(len(keys)))
+	return c.FakeStore.BulkGet(ctx, keys)
+}
+
+func newTestStore(now *manualClock) (*Store[string, string], *countingStore) {
+	backend := &countingStore{FakeStore: cachetest.NewFakeStore[string, string]()}
+	store := NewStore[string, string](backend, "test",
+		WithThreshold(5), WithWindow(time.Second), WithLocalTTL(500*time.Millisecond), WithClock(now))
+	return store, backend
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("promotes hot keys", func(t *testing.T) {
+		now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		store, backend := newTestStore(now)
+		assert.NoError(t, store.Set(ctx, "viral", "v1"))
+		assert.NoError(t, store.Set(ctx, "cold", "c1"))
+
+		// The fifth read crosses the threshold and is kept in the process.
+		for range 10 {
+			value, err := store.Get(ctx, "viral")
+			assert.NoError(t, err)
+			assert.Equal(t, "v1", value)
+		}
+		assert.Equal(t, int64(5), backend.reads.Load())
+
+		_, err := store.Get(ctx, "cold")
+		assert.NoError(t, err)
+		hot := store.HotKeys()
+		assert.Len(t, hot, 1)
+		assert.Equal(t, "viral", hot[0].Key)
+		assert.GreaterOrEqual(t, hot[0].QPS, 5.0)
+
+		// A write evicts the local copy.
+		assert.NoError(t, store.Set(ctx, "viral", "v2"))
+		value, err := store.Get(ctx, "viral")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", value)
+		value, err = store.Get(ctx, "viral")
+		assert.NoError(t, err)
+		assert.Equal(t, "v2", value)
+		assert.Equal(t, int64(7), backend.reads.Load())
+
+		// A write of another process is seen once the local copy expires.
+		assert.NoError(t, backend.Set(ctx, "viral", "v3"))
+		now.now = now.now.Add(600 * time.Millisecond)
+		value, err = store.Get(ctx, "viral")
+		assert.NoError(t, err)
+		assert.Equal(t, "v3", value)
+
+		values, err := store.BulkGet(ctx, []string{"viral", "cold"})
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"viral": "v3", "cold": "c1"}, values)
+		assert.Equal(t, int64(9), backend.reads.Load())
+
+		// The key cools down once it is no longer read.
+		now.now = now.now.Add(3 * time.Second)
+		assert.Empty(t, store.HotKeys())
+		_, err = store.Get(ctx, "viral")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10), backend.reads.Load())
+	})
+
+	t.Run("bounds the hot keys", func(t *testing.T) {
+		now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		backend := &countingStore{FakeStore: cachetest.NewFakeStore[string, string]()}
+		store := NewStore[string, string](backend, "bounded",
+			WithThreshold(2), WithMaxKeys(1), WithClock(now))
+
+		for range 2 {
+			_, _ = store.Get(ctx, "a")
+		}
+		for range 4 {
+			_, _ = store.Get(ctx, "b")
+		}
+		hot := store.HotKeys()
+		assert.Len(t, hot, 1)
+		assert.Equal(t, "b", hot[0].Key)
+	})
+
+	t.Run("ignores a non-positive window", func(t *testing.T) {
+		now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		backend := &countingStore{FakeStore: cachetest.NewFakeStore[string, string]()}
+		store := NewStore[string, string](backend, "window",
+			WithThreshold(2), WithWindow(0), WithClock(now))
+
+		_, _ = store.Get(ctx, "a")
+		now.now = now.now.Add(2 * time.Second)
+		for range 2 {
+			_, _ = store.Get(ctx, "a")
+		}
+		assert.Len(t, store.HotKeys(), 1)
+	})
+
+	t.Run("concurrent reads", func(t *testing.T) {
+		backend := &countingStore{FakeStore: cachetest.NewFakeStore[string, string]()}
+		now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		store := NewStore[string, string](backend, "concurrent",
+			WithThreshold(100), WithWindow(time.Second), WithLocalTTL(time.Minute), WithClock(now))
+		assert.NoError(t, store.Set(ctx, "viral", "v1"))
+		assert.NoError(t, store.Set(ctx, "cold", "c1"))
+
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range 1000 {
+					key := "viral"
+					if i%100 == 0 {
+						key = "cold"
+					}
+					_, err := store.Get(ctx, key)
+					assert.NoError(t, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		hot := store.HotKeys()
+		assert.Len(t, hot, 1)
+		assert.Equal(t, "viral", hot[0].Key)
+		// Once promoted, the hot key is no longer read from the store.
+		assert.Less(t, backend.reads.Load(), int64(1000))
+	})
+
+	t.Run("debug endpoint", func(t *testing.T) {
+		now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+		store, _ := newTestStore(now)
+		assert.NoError(t, store.Set(ctx, "viral", "v1", cache.WithTTL(time.Minute)))
+		for range 6 {
+			_, _ = store.Get(ctx, "viral")
+		}
+
+		rec := httptest.NewRecorder()
+		store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/hotkeys", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var body hotKeysResponse[string]
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, "test", body.Store)
+		assert.Len(t, body.HotKeys, 1)
+		assert.Equal(t, "viral", body.HotKeys[0].Key)
+
+		rec = httptest.NewRecorder()
+		store.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/hotkeys", nil))
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+}
@@ -0,0 +1,16 @@
+package cacheinstrumented
+
+import (
+	cacheredis "github.com/trinhdaiphuc/go-kit/cache/redis"
+	"github.com/trinhdaiphuc/go-kit/metrics"
+)
+
+// CompressionObserver records the compression ratio of the values written by a
+// cacheredis store named name:
+//
+//	cacheredis.WithCompressionObserver[K, V](cacheinstrumented.CompressionObserver("users"))
+func CompressionObserver(name string) cacheredis.CompressionObserver {
+	return func(algorithm cacheredis.Compression, raw, compressed int) {
+		metrics.CacheCompression(name, algorithm.String(), raw, compressed)
+	}
+}
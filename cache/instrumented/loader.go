@@ -0,0 +1,98 @@
+package cacheinstrumented
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/metrics"
+	"github.com/trinhdaiphuc/go-kit/tracing"
+)
+
+const (
+	opLoad     = "load"
+	opLoadAll  = "load_all"
+	opBulkLoad = "bulk_load"
+)
+
+var (
+	storeKey     = attribute.Key("cache.store")
+	operationKey = attribute.Key("cache.operation")
+	keysKey      = attribute.Key("cache.keys")
+)
+
+type instrumentedLoader[K comparable, V any] struct {
+	cache.WrappedLoader[K, V]
+	name string
+	opts *Options
+}
+
+// NewLoader wraps a Loader to record the load count, errors and latency of the
+// store named name, and to trace every call in a span. Pass the wrapped loader
+// to the store that NewStore decorates, so that loaded keys count as misses.
+func NewLoader[K comparable, V any](loader cache.Loader[K, V], name string, options ...Option) cache.Loader[K, V] {
+	opts := newDefaultOption()
+	for _, o := range options {
+		o(opts)
+	}
+
+	return &instrumentedLoader[K, V]{
+		WrappedLoader: cache.WrappedLoader[K, V]{Loader: loader},
+		name:          name,
+		opts:          opts,
+	}
+}
+
+func (l *instrumentedLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V], key K) (value V, err error) {
+	ctx, done := l.start(ctx, opLoad, 1)
+	defer func() { done(err) }()
+
+	return l.Loader.Load(ctx, c, key)
+}
+
+func (l *instrumentedLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K, V], key K) (values map[K]V, err error) {
+	ctx, done := l.start(ctx, opLoadAll, 1)
+	defer func() { done(err) }()
+
+	return l.Loader.LoadAll(ctx, c, key)
+}
+
+func (l *instrumentedLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K, V], keys []K) (values map[K]V, err error) {
+	ctx, done := l.start(ctx, opBulkLoad, len(keys))
+	defer func() { done(err) }()
+
+	return l.Loader.BulkLoad(ctx, c, keys)
+}
+
+// start opens the span of a loader call and returns the function recording its
+// outcome.
+func (l *instrumentedLoader[K, V]) start(ctx context.Context, operation string, keys int) (context.Context, func(err error)) {
+	markLoaded(ctx, keys)
+
+	ctx, span := tracing.CreateSpan(ctx, "cache."+operation,
+		trace.WithAttributes(
+			storeKey.String(l.name),
+			operationKey.String(operation),
+			keysKey.Int(keys),
+		),
+		trace.WithSpanKind(trace.SpanKindInternal),
+	)
+	start := time.Now()
+
+	return ctx, func(err error) {
+		defer span.End()
+
+		if err != nil && l.opts.IsNotFound(err) {
+			err = nil
+		}
+		metrics.CacheLoad(l.name, operation, err, time.Since(start))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package cacheinstrumented
+
+import (
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	// IsNotFound tells which loader errors are misses rather than load errors.
+	IsNotFound cache.NotFoundFunc
+}
+
+func newDefaultOption() *Options {
+	return &Options{
+		IsNotFound: cache.IsErrorKeyNotFound,
+	}
+}
+
+// WithNotFound sets which loader errors mean that the key doesn't exist at the
+// source. They are counted as misses, not as load errors.
+func WithNotFound(isNotFound cache.NotFoundFunc) Option {
+	return func(o *Options) {
+		if isNotFound != nil {
+			o.IsNotFound = isNotFound
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package cacheinstrumented
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type recorderKey struct{}
+
+// recorder is put in the context of a Store call so that the instrumented Loader,
+// which receives that context, can tell the Store that the keys were loaded:
+// the value returned by the Store alone doesn't tell a hit from a load.
+type recorder struct {
+	loaded atomic.Int64
+}
+
+func withRecorder(ctx context.Context) (context.Context, *recorder) {
+	r := &recorder{}
+	return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+func markLoaded(ctx context.Context, n int) {
+	if r, ok := ctx.Value(recorderKey{}).(*recorder); ok {
+		r.loaded.Add(int64(n))
+	}
+}
@@ -0,0 +1,107 @@
+package cacheinstrumented
+
+import (
+	"context"
+	"errors"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/metrics"
+)
+
+// instrumentedStore counts the hits and misses of the reads of a cache.Store.
+// The other methods are passed through.
+type instrumentedStore[K comparable, V any] struct {
+	cache.Store[K, V]
+	name string
+}
+
+// NewStore decorates store to record its hits and misses, labelled by name
+// (e.g. the key prefix). It works with any store: a key is a miss when the
+// store returns cache.ErrorKeyNotFound for it, or when its Loader was called,
+// which the decorator only sees if the loader was wrapped with NewLoader.
+func NewStore[K comparable, V any](store cache.Store[K, V], name string) cache.Store[K, V] {
+	return &instrumentedStore[K, V]{
+		Store: store,
+		name:  name,
+	}
+}
+
+func (s *instrumentedStore[K, V]) Get(ctx context.Context, key K) (V, error) {
+	ctx, rec := withRecorder(ctx)
+	value, err := s.Store.Get(ctx, key)
+	s.recordOne(rec, err)
+	return value, err
+}
+
+func (s *instrumentedStore[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, error) {
+	ctx, rec := withRecorder(ctx)
+	values, err := s.Store.BulkGet(ctx, keys)
+	if err != nil {
+		return values, err
+	}
+
+	// The loader is only called with the keys missing from the cache.
+	misses := int(rec.loaded.Load())
+	if misses == 0 {
+		misses = len(keys) - len(values)
+	}
+	metrics.CacheHits(s.name, len(keys)-misses)
+	metrics.CacheMisses(s.name, misses)
+
+	return values, nil
+}
+
+func (s *instrumentedStore[K, V]) HGet(ctx context.Context, key, field K) (V, error) {
+	ctx, rec := withRecorder(ctx)
+	value, err := s.Store.HGet(ctx, key, field)
+	s.recordOne(rec, err)
+	return value, err
+}
+
+func (s *instrumentedStore[K, V]) HGetAll(ctx context.Context, key K) (map[K]V, error) {
+	ctx, rec := withRecorder(ctx)
+	values, err := s.Store.HGetAll(ctx, key)
+	if err == nil && len(values) == 0 {
+		// Without a loader a missing hash is an empty map.
+		s.recordOne(rec, cache.ErrorKeyNotFound)
+	} else {
+		s.recordOne(rec, err)
+	}
+	return values, err
+}
+
+// HSetWithOptions forwards to the wrapped store, which the embedding would hide.
+func (s *instrumentedStore[K, V]) HSetWithOptions(ctx context.Context, key K, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	return cache.HSetWithOptions(ctx, s.Store, key, keyVals, opts...)
+}
+
+// BulkSet forwards to the wrapped store, which the embedding would hide.
+func (s *instrumentedStore[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	return cache.BulkSet(ctx, s.Store, keyVals, opts...)
+}
+
+// InvalidateTags forwards to the wrapped store, which the embedding would hide.
+func (s *instrumentedStore[K, V]) InvalidateTags(ctx context.Context, tags ...string) error {
+	return cache.InvalidateTags(ctx, s.Store, tags...)
+}
+
+// CompareAndSet forwards to the wrapped store, which the embedding would hide.
+func (s *instrumentedStore[K, V]) CompareAndSet(ctx context.Context, key K, old, value V, opts ...cache.WriteOption) (bool, error) {
+	return cache.CompareAndSet(ctx, s.Store, key, old, value, opts...)
+}
+
+// CompareAndDelete forwards to the wrapped store, which the embedding would hide.
+func (s *instrumentedStore[K, V]) CompareAndDelete(ctx context.Context, key K, old V) (bool, error) {
+	return cache.CompareAndDelete(ctx, s.Store, key, old)
+}
+
+// recordOne records the outcome of a single key read. Errors other than
+// cache.ErrorKeyNotFound are neither hits nor misses.
+func (s *instrumentedStore[K, V]) recordOne(rec *recorder, err error) {
+	switch {
+	case err == nil && rec.loaded.Load() == 0:
+		metrics.CacheHits(s.name, 1)
+	case err == nil, errors.Is(err, cache.ErrorKeyNotFound):
+		metrics.CacheMisses(s.name, 1)
+	}
+}
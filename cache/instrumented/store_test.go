@@ -0,0 +1,78 @@
+package cacheinstrumented
+
+import (
+	"context"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
+	"github.com/trinhdaiphuc/go-kit/metrics"
+)
+
+type testLoader struct{}
+
+func (testLoader) Load(ctx context.Context, c cache.Store[string, int], key string) (int, error) {
+	if key == "missing" {
+		return 0, cache.ErrorKeyNotFound
+	}
+	return len(key), nil
+}
+
+func (testLoader) LoadAll(ctx context.Context, c cache.Store[string, int], key string) (map[string]int, error) {
+	return nil, cache.ErrorKeyNotFound
+}
+
+func (testLoader) BulkLoad(ctx context.Context, c cache.Store[string, int], keys []string) (map[string]int, error) {
+	values := make(map[string]int, len(keys))
+	for _, key := range keys {
+		values[key] = len(key)
+	}
+	return values, nil
+}
+
+// counter returns the value of the cache counter named name for the store.
+func counter(t *testing.T, name, store string) float64 {
+	families, err := prom.DefaultGatherer.Gather()
+	assert.NoError(t, err)
+
+	var total float64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "store" && label.GetValue() == store {
+					total += m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return total
+}
+
+func TestStore(t *testing.T) {
+	metrics.NewServerMonitor("test")
+	ctx := context.Background()
+
+	loader := NewLoader[string, int](testLoader{}, "users")
+	store := NewStore(cachelocal.NewClient(cachelocal.WithLoader[string, int](loader)), "users")
+	defer store.Close()
+
+	// Loaded, then served from the cache.
+	_, err := store.Get(ctx, "alice")
+	assert.NoError(t, err)
+	_, err = store.Get(ctx, "alice")
+	assert.NoError(t, err)
+	// Not found at the source.
+	_, err = store.Get(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+
+	assert.Equal(t, float64(1), counter(t, "cache_hits_total", "users"))
+	assert.Equal(t, float64(2), counter(t, "cache_misses_total", "users"))
+	assert.Equal(t, float64(2), counter(t, "cache_loads_total", "users"))
+	assert.Equal(t, float64(0), counter(t, "cache_load_errors_total", "users"))
+}
@@ -0,0 +1,103 @@
+// Package sketch implements a count-min sketch: an approximate frequency counter
+// with a fixed memory footprint, used to estimate how popular a key is.
+package sketch
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"sync/atomic"
+)
+
+const depth = 4
+
+// Sketch counts keys of type K. Estimates never undercount but may overcount
+// when keys collide. It is safe for concurrent use, without locks: Halve and
+// Reset may lose the increments racing with them, which only makes the counts
+// a little more approximate.
+type Sketch[K comparable] struct {
+	seed     maphash.Seed
+	counters [depth][]atomic.Uint32
+	mask     uint64
+}
+
+// New creates a sketch with width counters per row, rounded up to a power of two.
+func New[K comparable](width int) *Sketch[K] {
+	if width < 16 {
+		width = 16
+	}
+	width = 1 << bits.Len(uint(width-1))
+
+	s := &Sketch[K]{
+		seed: maphash.MakeSeed(),
+		mask: uint64(width - 1),
+	}
+	for i := range s.counters {
+		s.counters[i] = make([]atomic.Uint32, width)
+	}
+	return s
+}
+
+// Increment counts one occurrence of key and returns its new estimate.
+func (s *Sketch[K]) Increment(key K) uint32 {
+	h := maphash.Comparable(s.seed, key)
+
+	estimate := uint32(0)
+	for i := range s.counters {
+		if c := increment(&s.counters[i][s.index(h, i)]); i == 0 || c < estimate {
+			estimate = c
+		}
+	}
+	return estimate
+}
+
+// increment adds one to counter, saturating at the largest uint32, and returns
+// its new value.
+func increment(counter *atomic.Uint32) uint32 {
+	for {
+		c := counter.Load()
+		if c == ^uint32(0) {
+			return c
+		}
+		if counter.CompareAndSwap(c, c+1) {
+			return c + 1
+		}
+	}
+}
+
+// Estimate returns the approximate number of occurrences of key.
+func (s *Sketch[K]) Estimate(key K) uint32 {
+	h := maphash.Comparable(s.seed, key)
+
+	estimate := uint32(0)
+	for i := range s.counters {
+		if c := s.counters[i][s.index(h, i)].Load(); i == 0 || c < estimate {
+			estimate = c
+		}
+	}
+	return estimate
+}
+
+// Halve divides every counter by two, so that old popularity fades away.
+func (s *Sketch[K]) Halve() {
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			s.counters[i][j].Store(s.counters[i][j].Load() >> 1)
+		}
+	}
+}
+
+// Reset sets every counter back to zero.
+func (s *Sketch[K]) Reset() {
+	for i := range s.counters {
+		for j := range s.counters[i] {
+			s.counters[i][j].Store(0)
+		}
+	}
+}
+
+// index derives the counter of row i from the two halves of the hash
+// (Kirsch-Mitzenmacher double hashing).
+func (s *Sketch[K]) index(h uint64, i int) uint64 {
+	h1, h2 := h&0xffffffff, h>>32
+	return (h1 + uint64(i)*h2) & s.mask // nolint: gosec
+}
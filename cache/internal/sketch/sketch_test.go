@@ -0,0 +1,25 @@
+package sketch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSketch(t *testing.T) {
+	s := New[string](100)
+
+	for range 10 {
+		s.Increment("hot")
+	}
+	assert.Equal(t, uint32(11), s.Increment("hot"))
+	assert.GreaterOrEqual(t, s.Estimate("hot"), uint32(11))
+	assert.Less(t, s.Estimate("cold"), uint32(11))
+
+	s.Halve()
+	assert.GreaterOrEqual(t, s.Estimate("hot"), uint32(5))
+	assert.Less(t, s.Estimate("hot"), uint32(11))
+
+	s.Reset()
+	assert.Equal(t, uint32(0), s.Estimate("hot"))
+}
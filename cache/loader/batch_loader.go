@@ -0,0 +1,210 @@
+package cacheloader
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+type BatchOptions struct {
+	Wait     time.Duration
+	MaxBatch int
+}
+
+type BatchOption func(*BatchOptions)
+
+// WithBatchWait sets how long a batch collects keys after its first key
+// before it is loaded.
+func WithBatchWait(wait time.Duration) BatchOption {
+	return func(o *BatchOptions) {
+		o.Wait = wait
+	}
+}
+
+// WithMaxBatch sets the maximum number of keys of a batch. A full batch is
+// loaded right away.
+func WithMaxBatch(size int) BatchOption {
+	return func(o *BatchOptions) {
+		o.MaxBatch = size
+	}
+}
+
+// BatchLoader collects the keys of concurrent Load and BulkLoad calls and loads
+// them with a single BulkLoad of the wrapped Loader, DataLoader style. A key is
+// only loaded once while it is pending or in flight: the calls asking for it wait
+// for the same batch. A key missing from the BulkLoad result fails with
+// cache.ErrorKeyNotFound.
+//
+// A batch runs with the context of its first caller, without its cancellation,
+// and the Store of its first caller. Each caller stops waiting when its own
+// context is done. A panic of the wrapped BulkLoad fails the calls waiting for
+// its batch. LoadAll isn't batched.
+type BatchLoader[K comparable, V any] struct {
+	cache.WrappedLoader[K, V]
+	opts *BatchOptions
+
+	mu      sync.Mutex
+	pending *batch[K, V]
+	// batches maps the pending and in-flight keys to their batch.
+	batches map[K]*batch[K, V]
+}
+
+type batch[K comparable, V any] struct {
+	ctx   context.Context
+	store cache.Store[K, V]
+	keys  []K
+	timer *time.Timer
+	once  sync.Once
+	done  chan struct{}
+
+	values map[K]V
+	err    error
+}
+
+func NewBatchLoader[K comparable, V any](loader cache.Loader[K, V], opts ...BatchOption) *BatchLoader[K, V] {
+	options := &BatchOptions{
+		Wait:     2 * time.Millisecond,
+		MaxBatch: 100,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &BatchLoader[K, V]{
+		WrappedLoader: cache.WrappedLoader[K, V]{Loader: loader},
+		opts:          options,
+		batches:       make(map[K]*batch[K, V]),
+	}
+}
+
+func (l *BatchLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V], key K) (value V, err error) {
+	batches := l.enqueue(ctx, c, []K{key})
+	b := batches[key]
+
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return value, ctx.Err()
+	}
+
+	if b.err != nil {
+		return value, b.err
+	}
+	value, ok := b.values[key]
+	if !ok {
+		return value, cache.ErrorKeyNotFound
+	}
+	return value, nil
+}
+
+func (l *BatchLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K, V], key K) (map[K]V, error) {
+	return l.Loader.LoadAll(ctx, c, key)
+}
+
+// BulkLoad returns the values of every batch the keys were loaded by. It fails
+// if one of them failed.
+func (l *BatchLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K, V], keys []K) (map[K]V, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	batches := l.enqueue(ctx, c, keys)
+
+	values := make(map[K]V, len(keys))
+	for _, key := range keys {
+		b := batches[key]
+		select {
+		case <-b.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if b.err != nil {
+			return nil, b.err
+		}
+		if value, ok := b.values[key]; ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// enqueue adds the keys that aren't pending or in flight yet to the pending
+// batch, and returns the batch of every key.
+func (l *BatchLoader[K, V]) enqueue(ctx context.Context, c cache.Store[K, V], keys []K) map[K]*batch[K, V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	batches := make(map[K]*batch[K, V], len(keys))
+	for _, key := range keys {
+		if b, ok := l.batches[key]; ok {
+			batches[key] = b
+			continue
+		}
+
+		if l.pending == nil {
+			b := &batch[K, V]{
+				ctx:   context.WithoutCancel(ctx),
+				store: c,
+				done:  make(chan struct{}),
+			}
+			b.timer = time.AfterFunc(l.opts.Wait, func() {
+				l.mu.Lock()
+				if l.pending == b {
+					l.pending = nil
+				}
+				l.mu.Unlock()
+				l.run(b)
+			})
+			l.pending = b
+		}
+
+		b := l.pending
+		b.keys = append(b.keys, key)
+		l.batches[key] = b
+		batches[key] = b
+
+		if l.opts.MaxBatch > 0 && len(b.keys) >= l.opts.MaxBatch {
+			l.pending = nil
+			b.timer.Stop()
+			go l.run(b)
+		}
+	}
+	return batches
+}
+
+// run loads a batch once, whether it was triggered by its timer or by reaching
+// the maximum size. The batch runs on its own goroutine, so a panic of the
+// wrapped Loader is logged and fails the batch instead of crashing the process.
+func (l *BatchLoader[K, V]) run(b *batch[K, V]) {
+	b.once.Do(func() {
+		var values map[K]V
+		var err error
+		defer func() {
+			if r := recover(); r != nil {
+				values, err = nil, fmt.Errorf("cacheloader: batch load panicked: %v", r)
+				log.For(b.ctx).Error("Batch load panicked", zap.Error(err), zap.ByteString("stack", debug.Stack()))
+			}
+
+			l.mu.Lock()
+			for _, key := range b.keys {
+				if l.batches[key] == b {
+					delete(l.batches, key)
+				}
+			}
+			l.mu.Unlock()
+
+			b.values, b.err = values, err
+			close(b.done)
+		}()
+
+		values, err = l.Loader.BulkLoad(b.ctx, b.store, b.keys)
+	})
+}
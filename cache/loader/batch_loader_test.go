@@ -0,0 +1,127 @@
+package cacheloader
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+type countingBulkLoader struct {
+	mu      sync.Mutex
+	batches [][]int
+	err     error
+	panics  bool
+}
+
+func (l *countingBulkLoader) Load(ctx context.Context, c cache.Store[int, string], key int) (string, error) {
+	return "", errors.New("unexpected Load")
+}
+
+func (l *countingBulkLoader) LoadAll(ctx context.Context, c cache.Store[int, string], key int) (map[int]string, error) {
+	return nil, errors.New("unexpected LoadAll")
+}
+
+func (l *countingBulkLoader) BulkLoad(ctx context.Context, c cache.Store[int, string], keys []int) (map[int]string, error) {
+	l.mu.Lock()
+	l.batches = append(l.batches, slices.Sorted(slices.Values(keys)))
+	l.mu.Unlock()
+
+	if l.panics {
+		panic("source exploded")
+	}
+	if l.err != nil {
+		return nil, l.err
+	}
+	values := make(map[int]string, len(keys))
+	for _, key := range keys {
+		// Key 0 doesn't exist at the source.
+		if key != 0 {
+			values[key] = string(rune('a' + key))
+		}
+	}
+	return values, nil
+}
+
+func TestBatchLoader(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("concurrent calls share one BulkLoad", func(t *testing.T) {
+		inner := &countingBulkLoader{}
+		l := NewBatchLoader[int, string](inner, WithBatchWait(20*time.Millisecond))
+
+		var wg sync.WaitGroup
+		for key := 1; key <= 5; key++ {
+			wg.Go(func() {
+				value, err := l.Load(ctx, nil, key)
+				assert.NoError(t, err)
+				assert.Equal(t, string(rune('a'+key)), value)
+			})
+		}
+		wg.Go(func() {
+			values, err := l.BulkLoad(ctx, nil, []int{3, 4, 5, 6, 7})
+			assert.NoError(t, err)
+			assert.Len(t, values, 5)
+		})
+		wg.Wait()
+
+		assert.Equal(t, [][]int{{1, 2, 3, 4, 5, 6, 7}}, inner.batches)
+	})
+
+	t.Run("a full batch is loaded right away", func(t *testing.T) {
+		inner := &countingBulkLoader{}
+		l := NewBatchLoader[int, string](inner, WithBatchWait(time.Hour), WithMaxBatch(2))
+
+		values, err := l.BulkLoad(ctx, nil, []int{1, 2, 3, 4})
+		assert.NoError(t, err)
+		assert.Len(t, values, 4)
+		assert.ElementsMatch(t, [][]int{{1, 2}, {3, 4}}, inner.batches)
+	})
+
+	t.Run("missing keys and errors", func(t *testing.T) {
+		l := NewBatchLoader[int, string](&countingBulkLoader{})
+		_, err := l.Load(ctx, nil, 0)
+		assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+
+		failed := errors.New("failed")
+		l = NewBatchLoader[int, string](&countingBulkLoader{err: failed})
+		_, err = l.Load(ctx, nil, 1)
+		assert.ErrorIs(t, err, failed)
+		_, err = l.BulkLoad(ctx, nil, []int{1, 2})
+		assert.ErrorIs(t, err, failed)
+	})
+
+	t.Run("a panic fails the batch", func(t *testing.T) {
+		inner := &countingBulkLoader{panics: true}
+		l := NewBatchLoader[int, string](inner)
+
+		var wg sync.WaitGroup
+		for key := 1; key <= 3; key++ {
+			wg.Go(func() {
+				_, err := l.Load(ctx, nil, key)
+				assert.ErrorContains(t, err, "batch load panicked: source exploded")
+			})
+		}
+		wg.Wait()
+
+		// The keys of the failed batch are loaded again.
+		inner.panics = false
+		value, err := l.Load(ctx, nil, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "b", value)
+	})
+
+	t.Run("a caller stops waiting when its context is done", func(t *testing.T) {
+		l := NewBatchLoader[int, string](&countingBulkLoader{}, WithBatchWait(time.Hour))
+		ctx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		_, err := l.Load(ctx, nil, 1)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,236 @@
+package cacheloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/sony/gobreaker/v2"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/breaker"
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+type BreakerOptions[K comparable, V any] struct {
+	LastGood           cache.Store[K, V]
+	LastGoodTTL        time.Duration
+	LastGoodMaxEntries int
+	Breaker            []breaker.CircuitBreakerOptions
+}
+
+type BreakerOption[K comparable, V any] func(*BreakerOptions[K, V])
+
+// WithLastGoodStore sets where the last good copies are kept, e.g. the Redis
+// store of the cache under another prefix so that every pod shares them. The
+// store must not have a Loader. By default they are kept in a cachelocal client
+// bounded by WithLastGoodMaxEntries.
+func WithLastGoodStore[K comparable, V any](store cache.Store[K, V]) BreakerOption[K, V] {
+	return func(o *BreakerOptions[K, V]) {
+		o.LastGood = store
+	}
+}
+
+// WithLastGoodTTL sets how long a last good copy is kept, which bounds how stale
+// a value served while the breaker is open can be.
+func WithLastGoodTTL[K comparable, V any](ttl time.Duration) BreakerOption[K, V] {
+	return func(o *BreakerOptions[K, V]) {
+		o.LastGoodTTL = ttl
+	}
+}
+
+// WithLastGoodMaxEntries bounds the number of last good copies kept by the
+// default store, 10000 by default. The least recently used ones are evicted.
+func WithLastGoodMaxEntries[K comparable, V any](n int) BreakerOption[K, V] {
+	return func(o *BreakerOptions[K, V]) {
+		o.LastGoodMaxEntries = n
+	}
+}
+
+// WithBreakerOptions configures the circuit breaker. They apply after the
+// defaults of NewBreakerLoader, which enable the metrics.
+func WithBreakerOptions[K comparable, V any](opts ...breaker.CircuitBreakerOptions) BreakerOption[K, V] {
+	return func(o *BreakerOptions[K, V]) {
+		o.Breaker = append(o.Breaker, opts...)
+	}
+}
+
+// BreakerLoader runs the wrapped Loader through a circuit breaker and keeps a
+// long-lived copy of every value it loads. While the breaker rejects calls, the
+// wrapped Loader isn't called and the last good copies are served instead; keys
+// without a copy fail with the breaker error. The errors matching
+// cache.ErrorKeyNotFound don't count as failures.
+//
+// A context prepared with WithStaleMarker tells whether a last good copy was
+// served, see IsStale.
+type BreakerLoader[K comparable, V any] struct {
+	cache.WrappedLoader[K, V]
+	breaker      breaker.CircuitBreaker[any]
+	opts         *BreakerOptions[K, V]
+	ownsLastGood bool
+}
+
+// NewBreakerLoader wraps loader with a circuit breaker named name, which labels
+// its metrics.
+func NewBreakerLoader[K comparable, V any](name string, loader cache.Loader[K, V], opts ...BreakerOption[K, V]) (*BreakerLoader[K, V], error) {
+	options := &BreakerOptions[K, V]{
+		LastGoodTTL:        24 * time.Hour,
+		LastGoodMaxEntries: 10000,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	breakerOpts := make([]breaker.CircuitBreakerOptions, 0, len(options.Breaker)+3)
+	breakerOpts = append(breakerOpts,
+		breaker.WithCircuitBreakerName(name),
+		breaker.WithCircuitBreakerIsSuccessful(func(err error) bool {
+			return err == nil || cache.IsErrorKeyNotFound(err)
+		}),
+		breaker.WithCircuitBreakerEnableMetric(true),
+	)
+	breakerOpts = append(breakerOpts, options.Breaker...)
+
+	cb, err := breaker.NewCircuitBreaker[any](breakerOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &BreakerLoader[K, V]{
+		WrappedLoader: cache.WrappedLoader[K, V]{Loader: loader},
+		breaker:       cb,
+		opts:          options,
+	}
+	if options.LastGood == nil {
+		options.LastGood = cachelocal.NewClient[K, V](
+			cachelocal.WithTTL[K, V](options.LastGoodTTL),
+			cachelocal.WithMaxEntries[K, V](options.LastGoodMaxEntries),
+		)
+		l.ownsLastGood = true
+	}
+	return l, nil
+}
+
+func (l *BreakerLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V], key K) (value V, err error) {
+	out, err := l.breaker.Execute(func() (any, error) {
+		return l.Loader.Load(ctx, c, key)
+	})
+	if err == nil {
+		value, _ = out.(V)
+		l.keep(ctx, l.opts.LastGood.Set(ctx, key, value, cache.WithTTL(l.opts.LastGoodTTL)))
+		return value, nil
+	}
+	if !rejected(err) {
+		return value, err
+	}
+
+	value, errLastGood := l.opts.LastGood.Get(ctx, key)
+	if errLastGood != nil {
+		return value, fmt.Errorf("%w: no last good value: %w", err, errLastGood)
+	}
+	markStale(ctx)
+	return value, nil
+}
+
+func (l *BreakerLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K, V], key K) (map[K]V, error) {
+	out, err := l.breaker.Execute(func() (any, error) {
+		return l.Loader.LoadAll(ctx, c, key)
+	})
+	if err == nil {
+		values, _ := out.(map[K]V)
+		if len(values) > 0 {
+			l.keep(ctx, cache.HSetWithOptions(ctx, l.opts.LastGood, key, keyVals(values), cache.WithTTL(l.opts.LastGoodTTL)))
+		}
+		return values, nil
+	}
+	if !rejected(err) {
+		return nil, err
+	}
+
+	values, errLastGood := l.opts.LastGood.HGetAll(ctx, key)
+	if errLastGood != nil || len(values) == 0 {
+		return nil, fmt.Errorf("%w: no last good value", err)
+	}
+	markStale(ctx)
+	return values, nil
+}
+
+// BulkLoad serves the keys that have a last good copy while the breaker is open,
+// and leaves the other ones out.
+func (l *BreakerLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K, V], keys []K) (map[K]V, error) {
+	out, err := l.breaker.Execute(func() (any, error) {
+		return l.Loader.BulkLoad(ctx, c, keys)
+	})
+	if err == nil {
+		values, _ := out.(map[K]V)
+		if len(values) > 0 {
+			l.keep(ctx, cache.BulkSet(ctx, l.opts.LastGood, keyVals(values), cache.WithTTL(l.opts.LastGoodTTL)))
+		}
+		return values, nil
+	}
+	if !rejected(err) {
+		return nil, err
+	}
+
+	values, errLastGood := l.opts.LastGood.BulkGet(ctx, keys)
+	if errLastGood != nil {
+		return nil, fmt.Errorf("%w: no last good value: %w", err, errLastGood)
+	}
+	if len(values) > 0 {
+		markStale(ctx)
+	}
+	return values, nil
+}
+
+// Close closes the default last good store. A store set with WithLastGoodStore
+// is left open.
+func (l *BreakerLoader[K, V]) Close() {
+	if l.ownsLastGood {
+		l.opts.LastGood.Close()
+	}
+}
+
+// keep logs a failed write of a last good copy: the load itself succeeded.
+func (l *BreakerLoader[K, V]) keep(ctx context.Context, err error) {
+	if err != nil {
+		log.For(ctx).Error("Keep last good value failed", zap.Error(err))
+	}
+}
+
+// rejected reports whether the breaker refused to call the Loader.
+func rejected(err error) bool {
+	return errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests)
+}
+
+func keyVals[K comparable, V any](values map[K]V) []cache.KeyVal[K, V] {
+	kvs := make([]cache.KeyVal[K, V], 0, len(values))
+	for k, v := range values {
+		kvs = append(kvs, cache.KeyVal[K, V]{Key: k, Value: v})
+	}
+	return kvs
+}
+
+type staleKey struct{}
+
+// WithStaleMarker returns a context in which BreakerLoader records that it
+// served a last good copy instead of a freshly loaded value.
+func WithStaleMarker(ctx context.Context) context.Context {
+	return context.WithValue(ctx, staleKey{}, new(atomic.Bool))
+}
+
+// IsStale reports whether a last good copy was served to a call made with ctx,
+// which must come from WithStaleMarker.
+func IsStale(ctx context.Context) bool {
+	stale, ok := ctx.Value(staleKey{}).(*atomic.Bool)
+	return ok && stale.Load()
+}
+
+func markStale(ctx context.Context) {
+	if stale, ok := ctx.Value(staleKey{}).(*atomic.Bool); ok {
+		stale.Store(true)
+	}
+}
@@ -0,0 +1,91 @@
+package cacheloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sony/gobreaker/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/breaker"
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+type flakyLoader struct {
+	calls int
+	err   error
+}
+
+func (l *flakyLoader) Load(ctx context.Context, c cache.Store[string, *Data], key string) (*Data, error) {
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &Data{Name: key}, nil
+}
+
+func (l *flakyLoader) LoadAll(ctx context.Context, c cache.Store[string, *Data], key string) (map[string]*Data, error) {
+	l.calls++
+	return nil, l.err
+}
+
+func (l *flakyLoader) BulkLoad(ctx context.Context, c cache.Store[string, *Data], keys []string) (map[string]*Data, error) {
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	values := make(map[string]*Data, len(keys))
+	for _, key := range keys {
+		values[key] = &Data{Name: key}
+	}
+	return values, nil
+}
+
+func TestBreakerLoader(t *testing.T) {
+	ctx := context.Background()
+	inner := &flakyLoader{}
+	l, err := NewBreakerLoader[string, *Data]("test_cache_loader", inner, WithBreakerOptions[string, *Data](
+		breaker.WithCircuitBreakerEnableMetric(false),
+		breaker.WithCircuitBreakerReadyToTrip(func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= 2
+		}),
+	))
+	assert.NoError(t, err)
+	defer l.Close()
+
+	value, err := l.Load(ctx, nil, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, &Data{Name: "a"}, value)
+	_, err = l.BulkLoad(ctx, nil, []string{"b"})
+	assert.NoError(t, err)
+
+	// Not found errors don't trip the breaker.
+	inner.err = cache.ErrorKeyNotFound
+	for range 3 {
+		_, err = l.Load(ctx, nil, "missing")
+		assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	}
+
+	inner.err = errors.New("database is down")
+	for range 2 {
+		_, err = l.Load(ctx, nil, "a")
+		assert.ErrorIs(t, err, inner.err)
+	}
+	calls := inner.calls
+
+	staleCtx := WithStaleMarker(ctx)
+	value, err = l.Load(staleCtx, nil, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, &Data{Name: "a"}, value)
+	assert.True(t, IsStale(staleCtx))
+
+	values, err := l.BulkLoad(ctx, nil, []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Data{"a": {Name: "a"}, "b": {Name: "b"}}, values)
+
+	_, err = l.Load(ctx, nil, "c")
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+	assert.Equal(t, calls, inner.calls)
+	assert.False(t, IsStale(WithStaleMarker(ctx)))
+}
@@ -3,6 +3,7 @@ package cacheloader
 import (
 	"context"
 	"fmt"
+	"maps"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,75 +13,223 @@ import (
 	"github.com/trinhdaiphuc/go-kit/log"
 )
 
+type RedSyncOptions struct {
+	WaitTimeout  time.Duration
+	PollInterval time.Duration
+	PollTimeout  time.Duration
+}
+
+type RedSyncOption func(*RedSyncOptions)
+
+// WithLockWait makes the callers that find the lock taken wait up to timeout for
+// it, with the retries of the RedLock, instead of failing. Once they hold the
+// lock they usually find the value written by the previous holder.
+func WithLockWait(timeout time.Duration) RedSyncOption {
+	return func(o *RedSyncOptions) {
+		o.WaitTimeout = timeout
+	}
+}
+
+// WithStorePolling makes the callers that find the lock taken read the store
+// every interval, up to timeout, until the lock holder has written the value.
+// It suits loaders that write through the store.
+func WithStorePolling(interval, timeout time.Duration) RedSyncOption {
+	return func(o *RedSyncOptions) {
+		o.PollInterval = interval
+		o.PollTimeout = timeout
+	}
+}
+
+// RedSyncLoader runs the wrapped Loader under a distributed lock per key, so
+// that only one pod loads a key at a time. After acquiring the lock it reads the
+// store again and only loads on a miss. The read uses cache.WithoutLoader, so
+// that it doesn't go back through the Loader of the store, e.g. a
+// SingleFlightLoader wrapping this one. By default the callers that find the
+// lock taken fail; see WithLockWait and WithStorePolling.
 type RedSyncLoader[K comparable, V any] struct {
 	redLock redislock.RedLock
-	loader  cache.Loader[K, V]
+	cache.WrappedLoader[K, V]
 	expiry  time.Duration
 	loadKey LoadKeyFunc
+	opts    *RedSyncOptions
 }
 
 type LoadKeyFunc func(string) string
 
-func NewRedSyncLoader[K comparable, V any](redLock redislock.RedLock, loader cache.Loader[K, V], loadKey LoadKeyFunc, expiry time.Duration) *RedSyncLoader[K, V] {
+func NewRedSyncLoader[K comparable, V any](redLock redislock.RedLock, loader cache.Loader[K, V], loadKey LoadKeyFunc, expiry time.Duration, opts ...RedSyncOption) *RedSyncLoader[K, V] {
+	options := &RedSyncOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	return &RedSyncLoader[K, V]{
-		redLock: redLock,
-		loader:  loader,
-		expiry:  expiry,
-		loadKey: loadKey,
+		redLock:       redLock,
+		WrappedLoader: cache.WrappedLoader[K, V]{Loader: loader},
+		expiry:        expiry,
+		loadKey:       loadKey,
+		opts:          options,
 	}
 }
 
 func (r *RedSyncLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V], key K) (value V, err error) {
-	mutex := r.redLock.GetLock(r.loadKey(defaultKeyEncoder(key)), r.expiry)
-	err = mutex.TryLockContext(ctx)
-	if err != nil {
-		return value, fmt.Errorf("acquire lock failed: %w", err)
+	if cache.LoaderSkipped(ctx) {
+		return value, cache.ErrorKeyNotFound
 	}
 
-	defer func() {
-		ok, errUnlock := mutex.Unlock()
-		if !ok || errUnlock != nil {
-			log.For(ctx).Error("Unlock failed", zap.Error(err))
+	get := func(ctx context.Context) bool {
+		if c == nil {
+			return false
 		}
-	}()
+		v, err := c.Get(cache.WithoutLoader(ctx), key)
+		if err != nil {
+			return false
+		}
+		value = v
+		return true
+	}
 
-	value, err = r.loader.Load(ctx, c, key)
+	mutex, found, err := r.acquire(ctx, r.loadKey(defaultKeyEncoder(key)), get)
+	if err != nil || found {
+		return value, err
+	}
+	defer r.unlock(ctx, mutex)
 
-	return
+	if get(ctx) {
+		return value, nil
+	}
+	return r.Loader.Load(ctx, c, key)
 }
 
 func (r *RedSyncLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K, V], key K) (map[K]V, error) {
-	mutex := r.redLock.GetLock(defaultKeyEncoder(key), r.expiry)
-	err := mutex.TryLockContext(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("acquire lock failed: %w", err)
+	if cache.LoaderSkipped(ctx) {
+		return nil, cache.ErrorKeyNotFound
 	}
 
-	defer func() {
-		ok, errUnlock := mutex.Unlock()
-		if !ok || errUnlock != nil {
-			log.For(ctx).Error("Unlock failed", zap.Error(err))
+	var values map[K]V
+	getAll := func(ctx context.Context) bool {
+		if c == nil {
+			return false
+		}
+		v, err := c.HGetAll(cache.WithoutLoader(ctx), key)
+		if err != nil || len(v) == 0 {
+			return false
 		}
-	}()
+		values = v
+		return true
+	}
 
-	return r.loader.LoadAll(ctx, c, key)
+	mutex, found, err := r.acquire(ctx, r.loadKey(defaultKeyEncoder(key)), getAll)
+	if err != nil || found {
+		return values, err
+	}
+	defer r.unlock(ctx, mutex)
+
+	if getAll(ctx) {
+		return values, nil
+	}
+	return r.Loader.LoadAll(ctx, c, key)
 }
 
 func (r *RedSyncLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K, V], keys []K) (map[K]V, error) {
-	mutex := r.redLock.GetLock(r.loadKey(defaultKeyEncoder(keys)), r.expiry)
-	err := mutex.TryLockContext(ctx)
+	if cache.LoaderSkipped(ctx) {
+		return nil, cache.ErrorKeyNotFound
+	}
+
+	var values map[K]V
+	bulkGet := func(ctx context.Context) bool {
+		if c == nil {
+			return false
+		}
+		v, err := c.BulkGet(cache.WithoutLoader(ctx), keys)
+		if err != nil {
+			return false
+		}
+		values = v
+		return len(missingKeys(keys, values)) == 0
+	}
+
+	mutex, found, err := r.acquire(ctx, r.loadKey(defaultKeyEncoder(keys)), bulkGet)
+	if err != nil || found {
+		return values, err
+	}
+	defer r.unlock(ctx, mutex)
+
+	if bulkGet(ctx) {
+		return values, nil
+	}
+
+	loaded, err := r.Loader.BulkLoad(ctx, c, missingKeys(keys, values))
 	if err != nil {
-		return nil, fmt.Errorf("acquire lock failed: %w", err)
+		return nil, err
+	}
+	if len(values) == 0 {
+		return loaded, nil
+	}
+	maps.Copy(values, loaded)
+	return values, nil
+}
+
+// acquire takes the lock of lockKey. When another caller holds it, it waits for
+// the lock or polls the store with get, depending on the options. found reports
+// that get succeeded while polling, in which case no lock is held.
+func (r *RedSyncLoader[K, V]) acquire(ctx context.Context, lockKey string, get func(ctx context.Context) bool) (mutex redislock.LockMutex, found bool, err error) {
+	mutex = r.redLock.GetLock(lockKey, r.expiry)
+	err = mutex.TryLockContext(ctx)
+	if err == nil {
+		return mutex, false, nil
 	}
 
-	defer func() {
-		ok, errUnlock := mutex.Unlock()
-		if !ok || errUnlock != nil {
-			log.For(ctx).Error("Unlock failed", zap.Error(err))
+	switch {
+	case r.opts.WaitTimeout > 0:
+		waitCtx, cancel := context.WithTimeout(ctx, r.opts.WaitTimeout)
+		defer cancel()
+		if err = mutex.LockContext(waitCtx); err == nil {
+			return mutex, false, nil
+		}
+	case r.opts.PollInterval > 0:
+		if r.poll(ctx, get) {
+			return nil, true, nil
 		}
-	}()
+	}
+
+	return nil, false, fmt.Errorf("acquire lock failed: %w", err)
+}
+
+func (r *RedSyncLoader[K, V]) poll(ctx context.Context, get func(ctx context.Context) bool) bool {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(r.opts.PollTimeout)
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timeout.C:
+			return false
+		case <-ticker.C:
+			if get(ctx) {
+				return true
+			}
+		}
+	}
+}
+
+func (r *RedSyncLoader[K, V]) unlock(ctx context.Context, mutex redislock.LockMutex) {
+	ok, err := mutex.Unlock()
+	if !ok || err != nil {
+		log.For(ctx).Error("Unlock failed", zap.Error(err))
+	}
+}
 
-	return r.loader.BulkLoad(ctx, c, keys)
+func missingKeys[K comparable, V any](keys []K, found map[K]V) []K {
+	missing := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
 }
 
 func defaultKeyEncoder(key any) string {
@@ -11,6 +11,7 @@ import (
 	"go.uber.org/mock/gomock"
 
 	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
 	redislock "github.com/trinhdaiphuc/go-kit/cache/redis/lock/mocks"
 )
 
@@ -160,3 +161,102 @@ func newRedSyncLoaderMock[K comparable, V any](t *testing.T, loader cache.Loader
 		mutexMock:   mutexMock,
 	}
 }
+
+func TestRedSyncLoader_Contention(t *testing.T) {
+	ctx := context.Background()
+	newStore := func(t *testing.T) cache.Store[string, *Data] {
+		store := cachelocal.NewClient[string, *Data]()
+		t.Cleanup(store.Close)
+		return store
+	}
+	prefixKey := func(key string) string { return "lock:" + key }
+
+	t.Run("re-reads the store after acquiring the lock", func(t *testing.T) {
+		r, mock := newRedSyncLoaderMock[string, *Data](t, &loaderFailed{})
+		store := newStore(t)
+		assert.NoError(t, store.Set(ctx, "key", &Data{Name: "winner"}))
+
+		mock.redLockMock.EXPECT().GetLock("key", time.Second).Return(mock.mutexMock)
+		mock.mutexMock.EXPECT().TryLockContext(gomock.Any()).Return(nil)
+		mock.mutexMock.EXPECT().Unlock().Return(true, nil)
+
+		value, err := r.Load(ctx, store, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, &Data{Name: "winner"}, value)
+	})
+
+	t.Run("waits for the lock", func(t *testing.T) {
+		r, mock := newRedSyncLoaderMock[string, *Data](t, &loaderFailed{})
+		r.opts.WaitTimeout = time.Second
+		store := newStore(t)
+
+		mock.redLockMock.EXPECT().GetLock("key", time.Second).Return(mock.mutexMock)
+		mock.mutexMock.EXPECT().TryLockContext(gomock.Any()).Return(errors.New("taken"))
+		mock.mutexMock.EXPECT().LockContext(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+			// The holder writes the value before releasing the lock.
+			return store.Set(ctx, "key", &Data{Name: "winner"})
+		})
+		mock.mutexMock.EXPECT().Unlock().Return(true, nil)
+
+		value, err := r.Load(ctx, store, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, &Data{Name: "winner"}, value)
+	})
+
+	t.Run("polls the store", func(t *testing.T) {
+		r, mock := newRedSyncLoaderMock[string, *Data](t, &loaderFailed{})
+		r.opts.PollInterval, r.opts.PollTimeout = time.Millisecond, time.Second
+		store := newStore(t)
+
+		mock.redLockMock.EXPECT().GetLock("key", time.Second).Return(mock.mutexMock)
+		mock.mutexMock.EXPECT().TryLockContext(gomock.Any()).Return(errors.New("taken"))
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			_ = store.Set(ctx, "key", &Data{Name: "winner"})
+		}()
+
+		value, err := r.Load(ctx, store, "key")
+		assert.NoError(t, err)
+		assert.Equal(t, &Data{Name: "winner"}, value)
+	})
+
+	t.Run("LoadAll uses the load key", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		redLockMock := redislock.NewMockRedLock(ctrl)
+		mutexMock := redislock.NewMockLockMutex(ctrl)
+		r := NewRedSyncLoader[string, *Data](redLockMock, &loaderSuccess{}, prefixKey, time.Second)
+
+		redLockMock.EXPECT().GetLock("lock:key", time.Second).Return(mutexMock)
+		mutexMock.EXPECT().TryLockContext(gomock.Any()).Return(nil)
+		mutexMock.EXPECT().Unlock().Return(true, nil)
+
+		values, err := r.LoadAll(ctx, newStore(t), "key")
+		assert.NoError(t, err)
+		assert.Len(t, values, 2)
+	})
+
+	t.Run("the re-read doesn't go through the store loader", func(t *testing.T) {
+		r, mock := newRedSyncLoaderMock[string, *Data](t, &loaderSuccess{})
+		// The re-read of a store loading through a SingleFlightLoader used to
+		// wait for the flight it was part of.
+		store := cachelocal.NewClient[string, *Data](cachelocal.WithLoader[string, *Data](NewSingleFlightLoader[string, *Data](r)))
+		t.Cleanup(store.Close)
+
+		mock.redLockMock.EXPECT().GetLock("key", time.Second).Return(mock.mutexMock)
+		mock.mutexMock.EXPECT().TryLockContext(gomock.Any()).Return(nil)
+		mock.mutexMock.EXPECT().Unlock().Return(true, nil)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			value, err := store.Get(ctx, "key")
+			assert.NoError(t, err)
+			assert.Equal(t, &Data{Name: "John Doe", Value: 100}, value)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Get deadlocked")
+		}
+	})
+}
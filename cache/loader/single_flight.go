@@ -9,20 +9,20 @@ import (
 )
 
 type SingleFlightLoader[K comparable, V any] struct {
-	loader cache.Loader[K, V]
+	cache.WrappedLoader[K, V]
 	singleflight.Group
 }
 
 func NewSingleFlightLoader[K comparable, V any](loader cache.Loader[K, V]) *SingleFlightLoader[K, V] {
 	return &SingleFlightLoader[K, V]{
-		loader: loader,
-		Group:  singleflight.Group{},
+		WrappedLoader: cache.WrappedLoader[K, V]{Loader: loader},
+		Group:         singleflight.Group{},
 	}
 }
 
 func (s *SingleFlightLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V], key K) (value V, err error) {
 	out, err, _ := s.Group.Do(defaultKeyEncoder(key), func() (any, error) {
-		return s.loader.Load(ctx, c, key)
+		return s.Loader.Load(ctx, c, key)
 	})
 	if err != nil {
 		var zero V
@@ -33,7 +33,7 @@ func (s *SingleFlightLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V]
 
 func (s *SingleFlightLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K, V], key K) (map[K]V, error) {
 	out, err, _ := s.Group.Do(defaultKeyEncoder(key), func() (any, error) {
-		return s.loader.LoadAll(ctx, c, key)
+		return s.Loader.LoadAll(ctx, c, key)
 	})
 	if err != nil {
 		return nil, err
@@ -44,7 +44,7 @@ func (s *SingleFlightLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K,
 
 func (s *SingleFlightLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K, V], keys []K) (map[K]V, error) {
 	out, err, _ := s.Group.Do(defaultKeyEncoder(keys), func() (any, error) {
-		return s.loader.BulkLoad(ctx, c, keys)
+		return s.Loader.BulkLoad(ctx, c, keys)
 	})
 	if err != nil {
 		return nil, err
@@ -0,0 +1,389 @@
+package cacheloader
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// Entry is the envelope stored by StaleWhileRevalidate: the value, its soft
+// expiry and how long the last load took (used by the early refresh).
+type Entry[V any] struct {
+	Value      V     `json:"value"`
+	SoftExpiry int64 `json:"soft_expiry"` // unix nanoseconds
+	Delta      int64 `json:"delta"`       // load duration in nanoseconds
+}
+
+var _ cache.Store[string, any] = (*StaleWhileRevalidate[string, any])(nil)
+
+type SWROptions struct {
+	SoftTTL        time.Duration
+	Beta           float64
+	RefreshTimeout time.Duration
+}
+
+type SWROption func(*SWROptions)
+
+// WithSoftTTL sets how long a value is fresh. After that it is still served
+// but refreshed in the background; the hard TTL of the wrapped store bounds
+// how long a stale value may be served.
+func WithSoftTTL(ttl time.Duration) SWROption {
+	return func(o *SWROptions) {
+		o.SoftTTL = ttl
+	}
+}
+
+// WithEarlyRefresh enables the probabilistic early refresh (XFetch). A value is
+// refreshed before its soft expiry with a probability that grows as the expiry
+// gets closer and as the load gets slower. beta > 1 favors earlier refreshes,
+// 1 is the recommended default and 0 disables it.
+func WithEarlyRefresh(beta float64) SWROption {
+	return func(o *SWROptions) {
+		o.Beta = beta
+	}
+}
+
+// WithRefreshTimeout bounds a load, 10 seconds by default. The loads outlive
+// the callers waiting for them, so they are bounded by it rather than by the
+// deadline of the callers.
+func WithRefreshTimeout(timeout time.Duration) SWROption {
+	return func(o *SWROptions) {
+		o.RefreshTimeout = timeout
+	}
+}
+
+// StaleWhileRevalidate is a cache.Store that keeps a soft expiry next to each
+// value of the wrapped store. A value past its soft expiry is returned right
+// away and reloaded in the background, so only hard misses wait for the Loader.
+// Refreshes and loads are deduplicated per key through the group of a
+// SingleFlightLoader: the loader's own when it is one, a new one otherwise.
+//
+// A Loader implementing cache.TTLLoader chooses the soft TTL of each value.
+//
+// The wrapped store must not have a Loader of its own. Values returned by the
+// Loader are written back by StaleWhileRevalidate, the Loader doesn't need to.
+type StaleWhileRevalidate[K comparable, V any] struct {
+	store  cache.Store[K, Entry[V]]
+	loader cache.Loader[K, V]
+	opts   *SWROptions
+	group  *singleflight.Group
+}
+
+func NewStaleWhileRevalidate[K comparable, V any](store cache.Store[K, Entry[V]], loader cache.Loader[K, V], opts ...SWROption) *StaleWhileRevalidate[K, V] {
+	options := &SWROptions{
+		SoftTTL:        time.Minute,
+		RefreshTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// The wrapped loader of a SingleFlightLoader is called directly, its group
+	// already deduplicates the loads.
+	flight, ok := loader.(*SingleFlightLoader[K, V])
+	if !ok {
+		flight = NewSingleFlightLoader(loader)
+	}
+
+	return &StaleWhileRevalidate[K, V]{
+		store:  store,
+		loader: flight.Loader,
+		opts:   options,
+		group:  &flight.Group,
+	}
+}
+
+func (s *StaleWhileRevalidate[K, V]) Get(ctx context.Context, key K) (value V, err error) {
+	entry, err := s.store.Get(ctx, key)
+	if err == nil {
+		if s.shouldRefresh(entry) {
+			s.refresh(ctx, key)
+		}
+		return entry.Value, nil
+	}
+
+	if !errors.Is(err, cache.ErrorKeyNotFound) {
+		return value, err
+	}
+
+	return s.load(ctx, key)
+}
+
+func (s *StaleWhileRevalidate[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	entries, err := s.store.BulkGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make(map[K]V, len(keys))
+	for key, entry := range entries {
+		if s.shouldRefresh(entry) {
+			s.refresh(ctx, key)
+		}
+		rs[key] = entry.Value
+	}
+
+	missingKeys := make([]K, 0, len(keys)-len(rs))
+	for _, key := range keys {
+		if _, ok := rs[key]; !ok {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+	if len(missingKeys) == 0 || s.loader == nil || cache.LoaderSkipped(ctx) {
+		return rs, nil
+	}
+
+	start := time.Now()
+	values, err := s.loader.BulkLoad(ctx, s, missingKeys)
+	if err != nil {
+		return nil, err
+	}
+	delta := time.Since(start)
+
+	loaded := make([]cache.KeyVal[K, Entry[V]], 0, len(values))
+	for _, key := range missingKeys {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		loaded = append(loaded, cache.KeyVal[K, Entry[V]]{Key: key, Value: s.newEntry(value, delta, cache.LoadTTL(s.loader, key, value))})
+		rs[key] = value
+	}
+	if err = cache.BulkSet(ctx, s.store, loaded); err != nil {
+		log.For(ctx).Error("Set loaded values failed", zap.Error(err))
+	}
+
+	return rs, nil
+}
+
+// Set and SetNX pass the write options to the wrapped store: they set the hard
+// TTL, the soft TTL is always the configured one.
+func (s *StaleWhileRevalidate[K, V]) Set(ctx context.Context, key K, value V, opts ...cache.WriteOption) error {
+	return s.store.Set(ctx, key, s.newEntry(value, 0, 0), opts...)
+}
+
+func (s *StaleWhileRevalidate[K, V]) SetNX(ctx context.Context, key K, value V, opts ...cache.WriteOption) (bool, error) {
+	return s.store.SetNX(ctx, key, s.newEntry(value, 0, 0), opts...)
+}
+
+func (s *StaleWhileRevalidate[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	entries := make([]cache.KeyVal[K, Entry[V]], 0, len(keyVals))
+	for _, keyVal := range keyVals {
+		entries = append(entries, cache.KeyVal[K, Entry[V]]{Key: keyVal.Key, Value: s.newEntry(keyVal.Value, 0, 0)})
+	}
+	return cache.BulkSet(ctx, s.store, entries, opts...)
+}
+
+func (s *StaleWhileRevalidate[K, V]) Delete(ctx context.Context, keys ...K) error {
+	return s.store.Delete(ctx, keys...)
+}
+
+// Incr is not supported: the stored values are envelopes, not integers.
+func (s *StaleWhileRevalidate[K, V]) Incr(ctx context.Context, key K, value int64) (int64, error) {
+	return 0, &cache.UnsupportedError{Op: "incr", Reason: "stale-while-revalidate values are envelopes"}
+}
+
+func (s *StaleWhileRevalidate[K, V]) Expire(ctx context.Context, key K, expireTime time.Duration) error {
+	return s.store.Expire(ctx, key, expireTime)
+}
+
+func (s *StaleWhileRevalidate[K, V]) TTL(ctx context.Context, key K) (time.Duration, error) {
+	return s.store.TTL(ctx, key)
+}
+
+func (s *StaleWhileRevalidate[K, V]) HSet(ctx context.Context, key K, keyVals ...cache.KeyVal[K, V]) error {
+	return s.HSetWithOptions(ctx, key, keyVals)
+}
+
+func (s *StaleWhileRevalidate[K, V]) HSetWithOptions(ctx context.Context, key K, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	entries := make([]cache.KeyVal[K, Entry[V]], 0, len(keyVals))
+	for _, keyVal := range keyVals {
+		entries = append(entries, cache.KeyVal[K, Entry[V]]{Key: keyVal.Key, Value: s.newEntry(keyVal.Value, 0, 0)})
+	}
+	return cache.HSetWithOptions(ctx, s.store, key, entries, opts...)
+}
+
+// HGet and HGetAll fall back to Loader.LoadAll when the hash is missing. Hash
+// fields have no soft expiry, they live as long as the hash does.
+func (s *StaleWhileRevalidate[K, V]) HGet(ctx context.Context, key, field K) (value V, err error) {
+	entry, err := s.store.HGet(ctx, key, field)
+	if err == nil {
+		return entry.Value, nil
+	}
+	if !errors.Is(err, cache.ErrorKeyNotFound) {
+		return value, err
+	}
+
+	values, err := s.loadAll(ctx, key)
+	if err != nil {
+		return value, err
+	}
+
+	value, ok := values[field]
+	if !ok {
+		return value, cache.ErrorKeyNotFound
+	}
+	return value, nil
+}
+
+func (s *StaleWhileRevalidate[K, V]) HGetAll(ctx context.Context, key K) (map[K]V, error) {
+	entries, err := s.store.HGetAll(ctx, key)
+	if err != nil && !errors.Is(err, cache.ErrorKeyNotFound) {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return s.loadAll(ctx, key)
+	}
+
+	rs := make(map[K]V, len(entries))
+	for field, entry := range entries {
+		rs[field] = entry.Value
+	}
+	return rs, nil
+}
+
+func (s *StaleWhileRevalidate[K, V]) HDel(ctx context.Context, key K, fields ...K) error {
+	return s.store.HDel(ctx, key, fields...)
+}
+
+func (s *StaleWhileRevalidate[K, V]) InvalidateTags(ctx context.Context, tags ...string) error {
+	return cache.InvalidateTags(ctx, s.store, tags...)
+}
+
+func (s *StaleWhileRevalidate[K, V]) Ping(ctx context.Context) error {
+	return s.store.Ping(ctx)
+}
+
+func (s *StaleWhileRevalidate[K, V]) Close() {
+	s.store.Close()
+}
+
+// load runs a synchronous load for a hard miss, sharing the in-flight call with
+// any background refresh of the same key. The shared load doesn't run with the
+// context of the caller that started it: a caller giving up returns early but
+// doesn't fail the others.
+func (s *StaleWhileRevalidate[K, V]) load(ctx context.Context, key K) (value V, err error) {
+	if s.loader == nil || cache.LoaderSkipped(ctx) {
+		return value, cache.ErrorKeyNotFound
+	}
+
+	ch := s.group.DoChan(defaultKeyEncoder(key), func() (any, error) {
+		loadCtx, cancel := s.detach(ctx)
+		defer cancel()
+
+		return s.loadAndSet(loadCtx, key)
+	})
+
+	select {
+	case <-ctx.Done():
+		return value, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return value, res.Err
+		}
+		value, _ = res.Val.(V)
+		return value, nil
+	}
+}
+
+// refresh reloads the key in the background. The refresh outlives the request
+// that triggered it, so it only inherits the request's values, not its deadline.
+func (s *StaleWhileRevalidate[K, V]) refresh(ctx context.Context, key K) {
+	if s.loader == nil || cache.LoaderSkipped(ctx) {
+		return
+	}
+
+	s.group.DoChan(defaultKeyEncoder(key), func() (any, error) {
+		refreshCtx, cancel := s.detach(ctx)
+		defer cancel()
+
+		value, err := s.loadAndSet(refreshCtx, key)
+		if err != nil {
+			log.For(refreshCtx).Warn("Background refresh failed", zap.Any("key", key), zap.Error(err))
+		}
+		return value, err
+	})
+}
+
+// detach returns the context of a shared load: the values of ctx without its
+// cancellation, bounded by the refresh timeout.
+func (s *StaleWhileRevalidate[K, V]) detach(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), s.opts.RefreshTimeout)
+}
+
+func (s *StaleWhileRevalidate[K, V]) loadAndSet(ctx context.Context, key K) (value V, err error) {
+	start := time.Now()
+	value, err = s.loader.Load(ctx, s, key)
+	if err != nil {
+		return value, err
+	}
+
+	if err = s.store.Set(ctx, key, s.newEntry(value, time.Since(start), cache.LoadTTL(s.loader, key, value))); err != nil {
+		log.For(ctx).Error("Set loaded value failed", zap.Error(err))
+	}
+
+	return value, nil
+}
+
+func (s *StaleWhileRevalidate[K, V]) loadAll(ctx context.Context, key K) (map[K]V, error) {
+	if s.loader == nil || cache.LoaderSkipped(ctx) {
+		return nil, cache.ErrorKeyNotFound
+	}
+
+	values, err := s.loader.LoadAll(ctx, s, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) > 0 {
+		keyVals := make([]cache.KeyVal[K, V], 0, len(values))
+		for field, value := range values {
+			keyVals = append(keyVals, cache.KeyVal[K, V]{Key: field, Value: value})
+		}
+		if err = s.HSet(ctx, key, keyVals...); err != nil {
+			log.For(ctx).Error("HSet loaded values failed", zap.Error(err))
+		}
+	}
+
+	return values, nil
+}
+
+// newEntry wraps value with its soft expiry. softTTL is the TTL chosen by a
+// cache.TTLLoader; zero means the configured soft TTL.
+func (s *StaleWhileRevalidate[K, V]) newEntry(value V, delta, softTTL time.Duration) Entry[V] {
+	if softTTL <= 0 {
+		softTTL = s.opts.SoftTTL
+	}
+	return Entry[V]{
+		Value:      value,
+		SoftExpiry: time.Now().Add(softTTL).UnixNano(),
+		Delta:      int64(delta),
+	}
+}
+
+// shouldRefresh reports whether the entry is past its soft expiry or, with
+// early refresh enabled, wins the XFetch draw:
+//
+//	now - delta * beta * ln(rand()) >= expiry
+func (s *StaleWhileRevalidate[K, V]) shouldRefresh(entry Entry[V]) bool {
+	now := time.Now().UnixNano()
+	if s.opts.Beta <= 0 || entry.Delta <= 0 {
+		return now >= entry.SoftExpiry
+	}
+
+	// 1 - Float64() is in (0, 1], so the logarithm is finite.
+	gap := float64(entry.Delta) * s.opts.Beta * -math.Log(1-rand.Float64()) // nolint: gosec
+	return float64(now)+gap >= float64(entry.SoftExpiry)
+}
@@ -0,0 +1,194 @@
+package cacheloader
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
+	cacheredis "github.com/trinhdaiphuc/go-kit/cache/redis"
+)
+
+type countingLoader struct {
+	calls atomic.Int32
+}
+
+func (l *countingLoader) Load(ctx context.Context, c cache.Store[string, *Data], key string) (*Data, error) {
+	n := l.calls.Add(1)
+	return &Data{Name: key, Value: int(n)}, nil
+}
+
+func (l *countingLoader) LoadAll(ctx context.Context, c cache.Store[string, *Data], key string) (map[string]*Data, error) {
+	l.calls.Add(1)
+	return map[string]*Data{"field1": {Name: "field1", Value: 1}}, nil
+}
+
+func (l *countingLoader) BulkLoad(ctx context.Context, c cache.Store[string, *Data], keys []string) (map[string]*Data, error) {
+	l.calls.Add(1)
+	rs := make(map[string]*Data, len(keys))
+	for _, key := range keys {
+		rs[key] = &Data{Name: key}
+	}
+	return rs, nil
+}
+
+// blockingLoader blocks every load until released, and records whether the
+// context of a load was done when it returned.
+type blockingLoader struct {
+	countingLoader
+	started  chan struct{}
+	release  chan struct{}
+	canceled atomic.Bool
+}
+
+func (l *blockingLoader) Load(ctx context.Context, c cache.Store[string, *Data], key string) (*Data, error) {
+	l.started <- struct{}{}
+	<-l.release
+	l.canceled.Store(ctx.Err() != nil)
+	return l.countingLoader.Load(ctx, c, key)
+}
+
+func newStaleWhileRevalidate(loader cache.Loader[string, *Data], opts ...SWROption) *StaleWhileRevalidate[string, *Data] {
+	store := cachelocal.NewClient[string, Entry[*Data]](cachelocal.WithTTL[string, Entry[*Data]](time.Minute))
+	return NewStaleWhileRevalidate[string, *Data](store, loader, opts...)
+}
+
+func TestStaleWhileRevalidate_Get(t *testing.T) {
+	ctx := context.Background()
+	loader := &countingLoader{}
+	swr := newStaleWhileRevalidate(loader, WithSoftTTL(50*time.Millisecond))
+	defer swr.Close()
+
+	// Hard miss loads synchronously.
+	got, err := swr.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &Data{Name: "key", Value: 1}, got)
+
+	// Fresh value is served from the store.
+	got, err = swr.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got.Value)
+	assert.Equal(t, int32(1), loader.calls.Load())
+
+	// Stale value is served right away and refreshed in the background.
+	time.Sleep(60 * time.Millisecond)
+	got, err = swr.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got.Value)
+
+	assert.Eventually(t, func() bool {
+		got, err = swr.Get(ctx, "key")
+		return err == nil && got.Value == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestStaleWhileRevalidate_BulkGet(t *testing.T) {
+	ctx := context.Background()
+	loader := &countingLoader{}
+	swr := newStaleWhileRevalidate(loader)
+	defer swr.Close()
+
+	assert.NoError(t, swr.Set(ctx, "key1", &Data{Name: "key1", Value: 1}))
+
+	got, err := swr.BulkGet(ctx, []string{"key1", "key2"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Data{
+		"key1": {Name: "key1", Value: 1},
+		"key2": {Name: "key2"},
+	}, got)
+	assert.Equal(t, int32(1), loader.calls.Load())
+
+	_, err = swr.Get(ctx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), loader.calls.Load())
+}
+
+func TestStaleWhileRevalidate_BulkGet_Redis(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	store := cacheredis.NewRedisCache[string, Entry[*Data]](client,
+		cacheredis.WithPrefix[string, Entry[*Data]]("test"),
+		cacheredis.WithTTL[string, Entry[*Data]](time.Minute),
+	)
+	loader := &countingLoader{}
+	swr := NewStaleWhileRevalidate[string, *Data](store, loader)
+	defer swr.Close()
+	ctx := context.Background()
+
+	// The keys missing from Redis are loaded, not reported as an error.
+	fresh := fmt.Sprintf(`{"value":{"name":"key1","value":1},"soft_expiry":%d,"delta":0}`, time.Now().Add(time.Hour).UnixNano())
+	mock.ExpectMGet("test:key1", "test:key2").SetVal([]any{fresh, nil})
+	mock.Regexp().ExpectSet("test:key2", `^\{"value":\{"name":"key2","value":0\},"soft_expiry":\d+,"delta":\d+\}$`, time.Minute).SetVal("OK")
+
+	got, err := swr.BulkGet(ctx, []string{"key1", "key2"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Data{
+		"key1": {Name: "key1", Value: 1},
+		"key2": {Name: "key2"},
+	}, got)
+	assert.Equal(t, int32(1), loader.calls.Load())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStaleWhileRevalidate_shouldRefresh(t *testing.T) {
+	swr := newStaleWhileRevalidate(nil, WithEarlyRefresh(1))
+	defer swr.Close()
+
+	now := time.Now()
+	assert.True(t, swr.shouldRefresh(Entry[*Data]{SoftExpiry: now.Add(-time.Second).UnixNano()}))
+	assert.False(t, swr.shouldRefresh(Entry[*Data]{SoftExpiry: now.Add(time.Hour).UnixNano()}))
+	// A load that takes much longer than the remaining freshness is refreshed early.
+	assert.True(t, swr.shouldRefresh(Entry[*Data]{
+		SoftExpiry: now.Add(time.Millisecond).UnixNano(),
+		Delta:      int64(time.Hour),
+	}))
+}
+
+func TestStaleWhileRevalidate_SharedLoad(t *testing.T) {
+	loader := &blockingLoader{started: make(chan struct{}, 1), release: make(chan struct{})}
+	flight := NewSingleFlightLoader[string, *Data](loader)
+	swr := newStaleWhileRevalidate(flight)
+	defer swr.Close()
+
+	type result struct {
+		value *Data
+		err   error
+	}
+	get := func(ctx context.Context) <-chan result {
+		ch := make(chan result, 1)
+		go func() {
+			value, err := swr.Get(ctx, "key")
+			ch <- result{value, err}
+		}()
+		return ch
+	}
+
+	// The caller that started the load gives up.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := get(ctx)
+	<-loader.started
+	second := get(context.Background())
+	shared := make(chan *Data, 1)
+	go func() {
+		value, _ := flight.Load(context.Background(), nil, "key")
+		shared <- value
+	}()
+
+	cancel()
+	assert.ErrorIs(t, (<-first).err, context.Canceled)
+
+	// The others still get the value of the same load.
+	time.Sleep(20 * time.Millisecond)
+	close(loader.release)
+	res := <-second
+	assert.NoError(t, res.err)
+	assert.Equal(t, &Data{Name: "key", Value: 1}, res.value)
+	assert.Equal(t, res.value, <-shared)
+	assert.Equal(t, int32(1), loader.calls.Load())
+	assert.False(t, loader.canceled.Load())
+}
@@ -12,8 +12,8 @@ import (
 // SuppressedLoader wraps another Loader and suppresses duplicate
 // calls to its Load method.
 type SuppressedLoader[K comparable, V any] struct {
-	loader cache.Loader[K, V]
-	group  *singleflight.Group
+	cache.WrappedLoader[K, V]
+	group *singleflight.Group
 }
 
 // Load executes a custom item retrieval logic and returns the item that
@@ -32,7 +32,7 @@ func (l *SuppressedLoader[K, V]) Load(ctx context.Context, c cache.Store[K, V],
 	// the error that we return ourselves in the func below, which
 	// is also nil
 	res, err, _ := l.group.Do(strKey, func() (any, error) {
-		v, err := l.loader.Load(ctx, c, key)
+		v, err := l.Loader.Load(ctx, c, key)
 		if err != nil {
 			return nil, err
 		}
@@ -72,7 +72,7 @@ func (l *SuppressedLoader[K, V]) LoadAll(ctx context.Context, c cache.Store[K, V
 	// the error that we return ourselves in the func below, which
 	// is also nil
 	res, err, _ := l.group.Do(strKey, func() (any, error) {
-		v, err := l.loader.LoadAll(ctx, c, key)
+		v, err := l.Loader.LoadAll(ctx, c, key)
 		if err != nil {
 			return nil, err
 		}
@@ -104,7 +104,7 @@ func (l *SuppressedLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K,
 	strKey := defaultKeyEncoder(keys)
 
 	res, err, _ := l.group.Do(strKey, func() (any, error) {
-		v, err := l.loader.BulkLoad(ctx, c, keys)
+		v, err := l.Loader.BulkLoad(ctx, c, keys)
 		if err != nil {
 			return nil, err
 		}
@@ -130,7 +130,7 @@ func (l *SuppressedLoader[K, V]) BulkLoad(ctx context.Context, c cache.Store[K,
 // NewSuppressedLoader creates a new instance of suppressed loader.
 func NewSuppressedLoader[K comparable, V any](loader cache.Loader[K, V]) cache.Loader[K, V] {
 	return &SuppressedLoader[K, V]{
-		loader: loader,
-		group:  &singleflight.Group{},
+		WrappedLoader: cache.WrappedLoader[K, V]{Loader: loader},
+		group:         &singleflight.Group{},
 	}
 }
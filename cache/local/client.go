@@ -2,6 +2,11 @@ package cachelocal
 
 import (
 	"context"
+	"errors"
+	"maps"
+	"math"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/jellydator/ttlcache/v3"
@@ -10,8 +15,22 @@ import (
 )
 
 type client[K comparable, V any] struct {
-	cli  *ttlcache.Cache[K, V]
-	opts *Options[K, V]
+	cli        *ttlcache.Cache[K, V]
+	hashes     *ttlcache.Cache[K, map[K]V]
+	tombstones *ttlcache.Cache[K, struct{}]
+	opts       *Options[K, V]
+	bounds     *bounds[K]
+	tags       *tagIndex[K]
+	// mu serializes the writes, so that no write is lost between the read and
+	// the write of SetNX, Incr, Expire and the hash writes.
+	mu sync.Mutex
+	// boundsMu makes the ttlcache writes of a bounded client atomic with their
+	// bounds bookkeeping, see update. It is taken after mu, never before.
+	boundsMu sync.Mutex
+
+	done           chan struct{}
+	closeOnce      sync.Once
+	stopOnEviction []func()
 }
 
 func NewClient[K comparable, V any](opts ...Option[K, V]) cache.Store[K, V] {
@@ -26,7 +45,27 @@ func NewClient[K comparable, V any](opts ...Option[K, V]) cache.Store[K, V] {
 			ttlcache.WithDisableTouchOnHit[K, V](),
 			ttlcache.WithTTL[K, V](option.TTL),
 		),
+		hashes: ttlcache.New[K, map[K]V](
+			ttlcache.WithDisableTouchOnHit[K, map[K]V](),
+			ttlcache.WithTTL[K, map[K]V](option.TTL),
+		),
+		tombstones: ttlcache.New[K, struct{}](
+			ttlcache.WithDisableTouchOnHit[K, struct{}](),
+			ttlcache.WithTTL[K, struct{}](option.NegativeTTL),
+		),
 		opts: option,
+		tags: newTagIndex[K](),
+		done: make(chan struct{}),
+	}
+
+	if option.MaxEntries > 0 || option.MaxCost > 0 {
+		cli.bounds = newBounds[K](option.MaxEntries, option.MaxCost, option.EvictionPolicy)
+	}
+	if cli.bounds != nil || option.OnEviction != nil {
+		cli.stopOnEviction = []func(){
+			cli.cli.OnEviction(cli.onValueEviction),
+			cli.hashes.OnEviction(cli.onHashEviction),
+		}
 	}
 
 	go cli.cleanUpExpired()
@@ -35,11 +74,24 @@ func NewClient[K comparable, V any](opts ...Option[K, V]) cache.Store[K, V] {
 }
 
 func (c *client[K, V]) Get(ctx context.Context, key K) (v V, err error) {
-	item := c.cli.Get(key, ttlcache.WithLoader[K, V](WrapLoadFunc[K, V](c.opts, ctx, c, key)))
-	if item == nil {
+	if c.tombstones.Has(key) {
 		return v, cache.ErrorKeyNotFound
 	}
 
+	var loadErr error
+	item := c.cli.Get(key, ttlcache.WithLoader[K, V](c.loadFunc(ctx, &loadErr)))
+	if item == nil {
+		switch {
+		case loadErr == nil:
+			return v, cache.ErrorKeyNotFound
+		case c.isNotFound(loadErr):
+			c.tombstones.Set(key, struct{}{}, ttlcache.DefaultTTL)
+			return v, cache.NotFoundError(loadErr)
+		}
+		return v, loadErr
+	}
+
+	c.access(entryKey[K]{key: key})
 	return item.Value(), nil
 }
 
@@ -60,69 +112,299 @@ func (c *client[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, error) {
 	return result, nil
 }
 
-func (c *client[K, V]) Set(ctx context.Context, key K, value V) error {
-	item := c.cli.Set(key, value, c.opts.TTL)
+func (c *client[K, V]) Set(ctx context.Context, key K, value V, opts ...cache.WriteOption) error {
+	c.mu.Lock()
+	var item *ttlcache.Item[K, V]
+	evicted := c.update(func() []victim[K] {
+		item = c.cli.Set(key, value, c.expiration(c.cli.Has(key), opts))
+		if item == nil {
+			return nil
+		}
+		return c.trackValue(key, value)
+	})
+	if item != nil {
+		c.tombstones.Delete(key)
+		c.tag(entryKey[K]{key: key}, opts)
+	}
+	c.mu.Unlock()
+
+	c.notify(evicted)
 	if item == nil {
 		return cache.ErrorFailedSetCache
 	}
-
 	return nil
 }
 
-func (c *client[K, V]) SetNX(ctx context.Context, key K, value V) (bool, error) {
-	// TTLCache doesn't have native SetNX, so we check existence first
+func (c *client[K, V]) SetNX(ctx context.Context, key K, value V, opts ...cache.WriteOption) (bool, error) {
+	// TTLCache doesn't have native SetNX, so we check existence first, under
+	// the lock so that only one of concurrent SetNX succeeds.
+	c.mu.Lock()
 	if c.cli.Has(key) {
+		c.mu.Unlock()
 		return false, nil
 	}
-	item := c.cli.Set(key, value, c.opts.TTL)
+	var item *ttlcache.Item[K, V]
+	evicted := c.update(func() []victim[K] {
+		item = c.cli.Set(key, value, c.expiration(false, opts))
+		if item == nil {
+			return nil
+		}
+		return c.trackValue(key, value)
+	})
+	if item != nil {
+		c.tombstones.Delete(key)
+		c.tag(entryKey[K]{key: key}, opts)
+	}
+	c.mu.Unlock()
+
+	// Evictions are reported outside of the lock, the callback may call the client.
+	c.notify(evicted)
 	if item == nil {
 		return false, cache.ErrorFailedSetCache
 	}
 	return true, nil
 }
 
+func (c *client[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	for _, keyVal := range keyVals {
+		if err := c.Set(ctx, keyVal.Key, keyVal.Value, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *client[K, V]) Delete(ctx context.Context, keys ...K) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	for _, key := range keys {
-		c.cli.Delete(key)
+		c.delete(key)
 	}
 	return nil
 }
 
+// delete removes the key and the hash. It is called with mu held.
+func (c *client[K, V]) delete(key K) {
+	c.update(func() []victim[K] {
+		c.cli.Delete(key)
+		c.hashes.Delete(key)
+		c.untrack(key)
+		return nil
+	})
+	c.tombstones.Delete(key)
+	c.tags.remove(entryKey[K]{key: key})
+	c.tags.remove(entryKey[K]{key: key, hash: true})
+}
+
+// Incr atomically adds value to an integer V. A missing key starts from 0 and
+// gets the store-wide TTL; an existing key keeps its TTL. Like Redis, it fails
+// and leaves the key untouched when the new value would overflow V.
 func (c *client[K, V]) Incr(ctx context.Context, key K, value int64) (int64, error) {
-	// TTLCache doesn't support atomic increment
-	return 0, nil
+	c.mu.Lock()
+
+	var current V
+	if item := c.cli.Get(key); item != nil {
+		current = item.Value()
+	}
+
+	next, result, err := incr(current, value)
+	if err != nil {
+		c.mu.Unlock()
+		return 0, err
+	}
+
+	evicted := c.update(func() []victim[K] {
+		c.cli.Set(key, next, ttlcache.PreviousOrDefaultTTL)
+		return c.trackValue(key, next)
+	})
+	c.tombstones.Delete(key)
+	c.mu.Unlock()
+
+	// Evictions are reported outside of the lock, the callback may call the client.
+	c.notify(evicted)
+	return result, nil
 }
 
+// Expire sets the TTL of a key or a hash, a non-positive TTL deletes it. A
+// missing key is cache.ErrorKeyNotFound, see cache.Store.
 func (c *client[K, V]) Expire(ctx context.Context, key K, expireTime time.Duration) error {
-	item := c.cli.Get(key)
-	if item == nil {
-		return cache.ErrorKeyNotFound
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if expireTime <= 0 {
+		if !c.cli.Has(key) && !c.hashes.Has(key) {
+			return cache.ErrorKeyNotFound
+		}
+		c.delete(key)
+		return nil
 	}
-	c.cli.Set(key, item.Value(), expireTime)
-	return nil
+
+	// The entries are rewritten under the bounds lock, so that a concurrent
+	// eviction can't be undone.
+	found := false
+	c.update(func() []victim[K] {
+		if item := c.cli.Get(key); item != nil {
+			c.cli.Set(key, item.Value(), expireTime)
+			found = true
+		}
+		return nil
+	})
+	if found {
+		return nil
+	}
+
+	c.update(func() []victim[K] {
+		if item := c.hashes.Get(key); item != nil {
+			c.hashes.Set(key, item.Value(), expireTime)
+			found = true
+		}
+		return nil
+	})
+	if found {
+		return nil
+	}
+	return cache.ErrorKeyNotFound
 }
 
+// TTL returns the remaining TTL of a key or a hash, or -1 when it has no
+// expiry. A missing key is cache.ErrorKeyNotFound, see cache.Store.
 func (c *client[K, V]) TTL(ctx context.Context, key K) (time.Duration, error) {
-	item := c.cli.Get(key)
-	if item == nil {
-		return 0, cache.ErrorKeyNotFound
+	if item := c.cli.Get(key); item != nil {
+		return ttl(item.ExpiresAt()), nil
 	}
-	return time.Until(item.ExpiresAt()), nil
+	if item := c.hashes.Get(key); item != nil {
+		return ttl(item.ExpiresAt()), nil
+	}
+	return 0, cache.ErrorKeyNotFound
 }
 
 func (c *client[K, V]) HSet(ctx context.Context, key K, keyVals ...cache.KeyVal[K, V]) error {
+	return c.HSetWithOptions(ctx, key, keyVals)
+}
+
+// HSetWithOptions sets the fields of the hash. The TTL applies to the whole
+// hash; with cache.WithKeepTTL the current TTL of the hash is left untouched.
+func (c *client[K, V]) HSetWithOptions(ctx context.Context, key K, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	c.mu.Lock()
+
+	// The stored map is never mutated, so that HGetAll can hand out copies
+	// without holding the lock.
+	var fields map[K]V
+	item := c.hashes.Get(key)
+	if item != nil {
+		fields = maps.Clone(item.Value())
+	} else {
+		fields = make(map[K]V, len(keyVals))
+	}
+	for _, keyVal := range keyVals {
+		fields[keyVal.Key] = keyVal.Value
+	}
+
+	var set bool
+	evicted := c.update(func() []victim[K] {
+		if set = c.hashes.Set(key, fields, c.expiration(item != nil, opts)) != nil; !set {
+			return nil
+		}
+		return c.trackHash(key, fields)
+	})
+	c.mu.Unlock()
+	c.notify(evicted)
+	if !set {
+		return cache.ErrorFailedSetCache
+	}
+
+	c.tag(entryKey[K]{key: key, hash: true}, opts)
 	return nil
 }
 
+// HGet and HGetAll call Loader.LoadAll when the hash is missing and store the
+// loaded fields with the store-wide TTL.
 func (c *client[K, V]) HGet(ctx context.Context, key, field K) (v V, err error) {
-	return
+	if item := c.hashes.Get(key); item != nil {
+		c.access(entryKey[K]{key: key, hash: true})
+		v, ok := item.Value()[field]
+		if !ok {
+			return v, cache.ErrorKeyNotFound
+		}
+		return v, nil
+	}
+
+	fields, err := c.loadAll(ctx, key)
+	if err != nil {
+		return v, err
+	}
+
+	v, ok := fields[field]
+	if !ok {
+		return v, cache.ErrorKeyNotFound
+	}
+	return v, nil
 }
 
 func (c *client[K, V]) HGetAll(ctx context.Context, key K) (map[K]V, error) {
-	return nil, nil
+	if item := c.hashes.Get(key); item != nil {
+		c.access(entryKey[K]{key: key, hash: true})
+		return maps.Clone(item.Value()), nil
+	}
+
+	return c.loadAll(ctx, key)
 }
 
 func (c *client[K, V]) HDel(ctx context.Context, key K, fields ...K) error {
+	c.mu.Lock()
+
+	item := c.hashes.Get(key)
+	if item == nil {
+		c.mu.Unlock()
+		return nil
+	}
+
+	remaining := maps.Clone(item.Value())
+	for _, field := range fields {
+		delete(remaining, field)
+	}
+
+	// Like Redis, a hash without fields doesn't exist.
+	if len(remaining) == 0 {
+		c.update(func() []victim[K] {
+			c.hashes.Delete(key)
+			c.untrackHash(key)
+			return nil
+		})
+		c.mu.Unlock()
+		c.tags.remove(entryKey[K]{key: key, hash: true})
+		return nil
+	}
+	evicted := c.update(func() []victim[K] {
+		c.hashes.Set(key, remaining, ttlcache.PreviousOrDefaultTTL)
+		return c.trackHash(key, remaining)
+	})
+	c.mu.Unlock()
+
+	c.notify(evicted)
+	return nil
+}
+
+// InvalidateTags deletes every key and hash written with one of the tags.
+func (c *client[K, V]) InvalidateTags(ctx context.Context, tags ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range c.tags.take(tags) {
+		c.update(func() []victim[K] {
+			if key.hash {
+				c.hashes.Delete(key.key)
+				c.untrackHash(key.key)
+				return nil
+			}
+			c.cli.Delete(key.key)
+			if c.bounds != nil {
+				c.bounds.remove(key)
+			}
+			return nil
+		})
+	}
 	return nil
 }
 
@@ -130,20 +412,266 @@ func (c *client[K, V]) Ping(ctx context.Context) error {
 	return nil
 }
 
+// Close stops the cleanup goroutine. It is safe to call more than once.
 func (c *client[K, V]) Close() {
-	c.cli.Stop()
+	c.closeOnce.Do(func() {
+		close(c.done)
+		for _, stop := range c.stopOnEviction {
+			stop()
+		}
+		c.cli.Stop()
+	})
 }
 
 func (c *client[K, V]) cleanUpExpired() {
+	ticker := time.NewTicker(c.opts.CleanUpInterval)
+	defer ticker.Stop()
+
 	for {
-		time.Sleep(c.opts.CleanUpInterval)
-		c.cli.DeleteExpired()
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.cli.DeleteExpired()
+			c.hashes.DeleteExpired()
+			c.tombstones.DeleteExpired()
+			c.tags.prune(c.exists)
+		}
+	}
+}
+
+func (c *client[K, V]) tag(key entryKey[K], opts []cache.WriteOption) {
+	if len(opts) > 0 {
+		c.tags.add(key, cache.NewWriteOptions(0, opts...).Tags)
 	}
 }
 
+func (c *client[K, V]) exists(key entryKey[K]) bool {
+	if key.hash {
+		return c.hashes.Has(key.key)
+	}
+	return c.cli.Has(key.key)
+}
+
+// trackValue and trackHash record a write in the bounds and return the entries
+// to evict. They are called by the update functions.
+func (c *client[K, V]) trackValue(key K, value V) []victim[K] {
+	if c.bounds == nil {
+		return nil
+	}
+	return c.bounds.add(entryKey[K]{key: key}, c.opts.Cost(key, value))
+}
+
+func (c *client[K, V]) trackHash(key K, fields map[K]V) []victim[K] {
+	if c.bounds == nil {
+		return nil
+	}
+	var cost int64
+	for field, value := range fields {
+		cost += c.opts.Cost(field, value)
+	}
+	return c.bounds.add(entryKey[K]{key: key, hash: true}, cost)
+}
+
+func (c *client[K, V]) access(key entryKey[K]) {
+	if c.bounds != nil {
+		c.bounds.access(key)
+	}
+}
+
+func (c *client[K, V]) untrack(key K) {
+	if c.bounds == nil {
+		return
+	}
+	c.bounds.remove(entryKey[K]{key: key})
+	c.bounds.remove(entryKey[K]{key: key, hash: true})
+}
+
+func (c *client[K, V]) untrackHash(key K) {
+	if c.bounds != nil {
+		c.bounds.remove(entryKey[K]{key: key, hash: true})
+	}
+}
+
+// evicted is an entry removed by the eviction policy, reported to OnEviction
+// once the locks are released.
+type evicted[K comparable, V any] struct {
+	key    K
+	values []V
+	reason EvictionReason
+}
+
+// update runs fn, which writes to ttlcache and records the write in the bounds,
+// and removes the entries the bounds chose to evict. For a bounded client it
+// holds boundsMu throughout, so that an eviction or an expiration can't remove
+// an entry rewritten in the meantime, nor leave the bounds tracking an entry
+// ttlcache no longer has. The caller reports the evicted entries with notify.
+func (c *client[K, V]) update(fn func() []victim[K]) []evicted[K, V] {
+	if c.bounds == nil {
+		fn()
+		return nil
+	}
+
+	c.boundsMu.Lock()
+	defer c.boundsMu.Unlock()
+	return c.evict(fn())
+}
+
+// evict removes the victims from ttlcache. It is called with boundsMu held.
+func (c *client[K, V]) evict(victims []victim[K]) []evicted[K, V] {
+	var out []evicted[K, V]
+	for _, v := range victims {
+		c.tags.remove(v.entryKey)
+		e := evicted[K, V]{key: v.key, reason: v.reason}
+		if v.hash {
+			item := c.hashes.Get(v.key)
+			c.hashes.Delete(v.key)
+			if item != nil {
+				e.values = slices.Collect(maps.Values(item.Value()))
+			}
+		} else {
+			item := c.cli.Get(v.key)
+			c.cli.Delete(v.key)
+			if item != nil {
+				e.values = []V{item.Value()}
+			}
+		}
+		if len(e.values) > 0 {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// notify reports the evicted entries. It must be called without holding a lock
+// of the client, the callback may call the client.
+func (c *client[K, V]) notify(entries []evicted[K, V]) {
+	if c.opts.OnEviction == nil {
+		return
+	}
+	for _, e := range entries {
+		for _, value := range e.values {
+			c.opts.OnEviction(e.key, value, e.reason)
+		}
+	}
+}
+
+// onValueEviction and onHashEviction handle the expirations detected by ttlcache.
+// Deletions, including the ones done by evict, are already accounted for.
+func (c *client[K, V]) onValueEviction(_ context.Context, reason ttlcache.EvictionReason, item *ttlcache.Item[K, V]) {
+	if reason != ttlcache.EvictionReasonExpired {
+		return
+	}
+	// The key may have been written again since it expired.
+	c.update(func() []victim[K] {
+		if c.bounds != nil && !c.cli.Has(item.Key()) {
+			c.bounds.remove(entryKey[K]{key: item.Key()})
+		}
+		return nil
+	})
+	if c.opts.OnEviction != nil {
+		c.opts.OnEviction(item.Key(), item.Value(), EvictionReasonExpired)
+	}
+}
+
+func (c *client[K, V]) onHashEviction(_ context.Context, reason ttlcache.EvictionReason, item *ttlcache.Item[K, map[K]V]) {
+	if reason != ttlcache.EvictionReasonExpired {
+		return
+	}
+	c.update(func() []victim[K] {
+		if c.bounds != nil && !c.hashes.Has(item.Key()) {
+			c.bounds.remove(entryKey[K]{key: item.Key(), hash: true})
+		}
+		return nil
+	})
+	if c.opts.OnEviction != nil {
+		for _, value := range item.Value() {
+			c.opts.OnEviction(item.Key(), value, EvictionReasonExpired)
+		}
+	}
+}
+
+// loadFunc is like WrapLoadFunc but records the Loader error, so that Get can
+// tell a "not found" from any other failure.
+func (c *client[K, V]) loadFunc(ctx context.Context, loadErr *error) ttlcache.LoaderFunc[K, V] {
+	return func(ttlCache *ttlcache.Cache[K, V], key K) *ttlcache.Item[K, V] {
+		if c.opts.Loader == nil || cache.LoaderSkipped(ctx) {
+			return nil
+		}
+		value, err := c.opts.Loader.Load(ctx, c, key)
+		if err != nil {
+			*loadErr = err
+			return nil
+		}
+		c.mu.Lock()
+		// A Loader that isn't a cache.TTLLoader may have written the value
+		// itself, with its own options.
+		if !cache.ChoosesTTL(c.opts.Loader) {
+			if item := ttlCache.Get(key); item != nil {
+				c.mu.Unlock()
+				return item
+			}
+		}
+
+		var opts []cache.WriteOption
+		if ttl := cache.LoadTTL(c.opts.Loader, key, value); ttl > 0 {
+			opts = append(opts, cache.WithTTL(ttl))
+		}
+		var item *ttlcache.Item[K, V]
+		evicted := c.update(func() []victim[K] {
+			item = ttlCache.Set(key, value, c.expiration(false, opts))
+			return c.trackValue(key, value)
+		})
+		c.mu.Unlock()
+
+		c.notify(evicted)
+		return item
+	}
+}
+
+func (c *client[K, V]) loadAll(ctx context.Context, key K) (map[K]V, error) {
+	if c.opts.Loader == nil || cache.LoaderSkipped(ctx) {
+		return nil, cache.ErrorKeyNotFound
+	}
+
+	fields, err := c.opts.Loader.LoadAll(ctx, c, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) > 0 {
+		keyVals := make([]cache.KeyVal[K, V], 0, len(fields))
+		for field, value := range fields {
+			keyVals = append(keyVals, cache.KeyVal[K, V]{Key: field, Value: value})
+		}
+		if err = c.HSet(ctx, key, keyVals...); err != nil {
+			return nil, err
+		}
+	}
+
+	return fields, nil
+}
+
+// expiration resolves the per-call write options into a ttlcache TTL. Like
+// Redis, keeping the TTL of a key that doesn't exist yet means no expiry.
+func (c *client[K, V]) expiration(exists bool, opts []cache.WriteOption) time.Duration {
+	o := cache.NewWriteOptions(c.opts.TTL, opts...)
+	switch {
+	case o.KeepTTL && exists:
+		return ttlcache.PreviousOrDefaultTTL
+	case o.KeepTTL, o.NoExpiry:
+		return ttlcache.NoTTL
+	}
+	return cache.Jitter(o.TTL, c.opts.TTLJitter)
+}
+
+func (c *client[K, V]) isNotFound(err error) bool {
+	return c.opts.NegativeTTL > 0 && c.opts.IsNotFound != nil && c.opts.IsNotFound(err)
+}
+
 func WrapLoadFunc[K comparable, V any](opts *Options[K, V], ctx context.Context, store cache.Store[K, V], key K) ttlcache.LoaderFunc[K, V] {
 	return func(ttlCache *ttlcache.Cache[K, V], key K) *ttlcache.Item[K, V] {
-		if opts.Loader == nil {
+		if opts.Loader == nil || cache.LoaderSkipped(ctx) {
 			return nil
 		}
 		value, err := opts.Loader.Load(ctx, store, key)
@@ -154,3 +682,73 @@ func WrapLoadFunc[K comparable, V any](opts *Options[K, V], ctx context.Context,
 		return ttlCache.Set(key, value, opts.TTL)
 	}
 }
+
+// ttl converts an expiry time to a remaining TTL, -1 meaning no expiry.
+func ttl(expiresAt time.Time) time.Duration {
+	if expiresAt.IsZero() {
+		return -1
+	}
+	return time.Until(expiresAt)
+}
+
+// incr adds delta to an integer value. It returns the new value both as V and
+// as int64, a cache.UnsupportedError if V isn't an integer type, or
+// errIncrOverflow if the new value doesn't fit in V or in an int64.
+func incr[V any](current V, delta int64) (next V, result int64, err error) {
+	var n any
+	switch v := any(current).(type) {
+	case int:
+		n, result, err = addInt(v, delta, math.MinInt, math.MaxInt)
+	case int8:
+		n, result, err = addInt(v, delta, math.MinInt8, math.MaxInt8)
+	case int16:
+		n, result, err = addInt(v, delta, math.MinInt16, math.MaxInt16)
+	case int32:
+		n, result, err = addInt(v, delta, math.MinInt32, math.MaxInt32)
+	case int64:
+		n, result, err = addInt(v, delta, math.MinInt64, math.MaxInt64)
+	case uint:
+		n, result, err = addInt(v, delta, 0, math.MaxInt64)
+	case uint8:
+		n, result, err = addInt(v, delta, 0, math.MaxUint8)
+	case uint16:
+		n, result, err = addInt(v, delta, 0, math.MaxUint16)
+	case uint32:
+		n, result, err = addInt(v, delta, 0, math.MaxUint32)
+	case uint64:
+		n, result, err = addInt(v, delta, 0, math.MaxInt64)
+	default:
+		return next, 0, &cache.UnsupportedError{Op: "incr", Reason: "value is not an integer"}
+	}
+	if err != nil {
+		return next, 0, err
+	}
+
+	next, ok := n.(V)
+	if !ok {
+		return next, 0, errors.New("incr: unexpected value type")
+	}
+	return next, result, nil
+}
+
+// errIncrOverflow is returned by Incr when the new value doesn't fit in V,
+// like the overflow error of Redis.
+var errIncrOverflow = errors.New("incr: increment or decrement would overflow")
+
+type integer interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 | ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64
+}
+
+// addInt adds delta to v and checks that the sum is within [lo, hi]. The
+// unsigned types are capped at math.MaxInt64, the sum being returned as int64.
+func addInt[T integer](v T, delta, lo, hi int64) (T, int64, error) {
+	if lo == 0 && uint64(v) > math.MaxInt64 { // nolint: gosec
+		return 0, 0, errIncrOverflow
+	}
+	base := int64(v) // nolint: gosec
+	sum := base + delta
+	if (delta > 0 && sum < base) || (delta < 0 && sum > base) || sum < lo || sum > hi {
+		return 0, 0, errIncrOverflow
+	}
+	return T(sum), sum, nil
+}
@@ -0,0 +1,338 @@
+package cachelocal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+type hashLoader struct {
+	calls int
+}
+
+func (l *hashLoader) Load(ctx context.Context, c cache.Store[string, int64], key string) (int64, error) {
+	return 0, cache.ErrorKeyNotFound
+}
+
+func (l *hashLoader) LoadAll(ctx context.Context, c cache.Store[string, int64], key string) (map[string]int64, error) {
+	l.calls++
+	return map[string]int64{"a": 1, "b": 2}, nil
+}
+
+func (l *hashLoader) BulkLoad(ctx context.Context, c cache.Store[string, int64], keys []string) (map[string]int64, error) {
+	return nil, nil
+}
+
+func Test_client_Hash(t *testing.T) {
+	ctx := context.Background()
+	loader := &hashLoader{}
+	c := NewClient[string, int64](WithLoader[string, int64](loader), WithTTL[string, int64](time.Minute))
+	defer c.Close()
+
+	// A missing hash is loaded once, then served from the cache.
+	v, err := c.HGet(ctx, "hash", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	all, err := c.HGetAll(ctx, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 1, "b": 2}, all)
+	assert.Equal(t, 1, loader.calls)
+
+	_, err = c.HGet(ctx, "hash", "missing")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+
+	assert.NoError(t, c.HSet(ctx, "hash", cache.KeyVal[string, int64]{Key: "c", Value: 3}))
+	assert.NoError(t, c.HDel(ctx, "hash", "a", "b"))
+	all, err = c.HGetAll(ctx, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"c": 3}, all)
+
+	ttl, err := c.TTL(ctx, "hash")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	assert.NoError(t, cache.HSetWithOptions(ctx, c, "other", []cache.KeyVal[string, int64]{{Key: "a", Value: 1}}, cache.WithNoExpiry()))
+	ttl, err = c.TTL(ctx, "other")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+}
+
+func Test_client_Incr(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient[string, int64]()
+	defer c.Close()
+
+	n, err := c.Incr(ctx, "counter", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	n, err = c.Incr(ctx, "counter", -5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), n)
+	v, err := c.Get(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-3), v)
+
+	s := NewClient[string, string]()
+	defer s.Close()
+	_, err = s.Incr(ctx, "counter", 1)
+	var unsupported *cache.UnsupportedError
+	assert.ErrorAs(t, err, &unsupported)
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+
+	// Overflowing the type of the values fails and keeps the value.
+	small := NewClient[string, int8]()
+	defer small.Close()
+	assert.NoError(t, small.Set(ctx, "counter", 127))
+	_, err = small.Incr(ctx, "counter", 1)
+	assert.ErrorIs(t, err, errIncrOverflow)
+	v8, err := small.Get(ctx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int8(127), v8)
+
+	unsigned := NewClient[string, uint32]()
+	defer unsigned.Close()
+	_, err = unsigned.Incr(ctx, "counter", -1)
+	assert.ErrorIs(t, err, errIncrOverflow)
+
+	assert.NoError(t, c.Set(ctx, "counter", math.MaxInt64))
+	_, err = c.Incr(ctx, "counter", 1)
+	assert.ErrorIs(t, err, errIncrOverflow)
+}
+
+func Test_client_SetNX(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient[string, int64]()
+	defer c.Close()
+
+	// Only one of concurrent SetNX reserves each key.
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		won   [200]atomic.Int32
+	)
+	for i := range len(won) * 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			key := i % len(won)
+			ok, err := c.SetNX(ctx, fmt.Sprint(key), int64(i))
+			assert.NoError(t, err)
+			if ok {
+				won[key].Add(1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for key := range won {
+		assert.Equal(t, int32(1), won[key].Load(), "key %d", key)
+	}
+}
+
+func Test_client_Expire(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient[string, int64]()
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "key", 1))
+	assert.NoError(t, c.Expire(ctx, "key", time.Hour))
+	ttl, err := c.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+
+	assert.NoError(t, c.Expire(ctx, "key", 0))
+	_, err = c.Get(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	assert.ErrorIs(t, c.Expire(ctx, "key", time.Hour), cache.ErrorKeyNotFound)
+}
+
+// writingLoader writes the values it loads itself, without an expiry.
+type writingLoader struct {
+	hashLoader
+}
+
+func (l *writingLoader) Load(ctx context.Context, c cache.Store[string, int64], key string) (int64, error) {
+	return 1, c.Set(ctx, key, 1, cache.WithNoExpiry())
+}
+
+func Test_client_LoaderWrite(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient[string, int64](WithLoader[string, int64](&writingLoader{}), WithTTL[string, int64](time.Minute))
+	defer c.Close()
+
+	// The value written by the Loader is kept, with its options.
+	v, err := c.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), v)
+	ttl, err := c.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+}
+
+func Test_client_Eviction(t *testing.T) {
+	ctx := context.Background()
+
+	type evicted struct {
+		key    string
+		reason EvictionReason
+	}
+
+	t.Run("LRU evicts the least recently used entry", func(t *testing.T) {
+		var got []evicted
+		c := NewClient[string, int64](
+			WithMaxEntries[string, int64](2),
+			WithOnEviction[string, int64](func(key string, value int64, reason EvictionReason) {
+				got = append(got, evicted{key: key, reason: reason})
+			}),
+		)
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "a", 1))
+		assert.NoError(t, c.Set(ctx, "b", 2))
+		_, err := c.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.NoError(t, c.Set(ctx, "c", 3))
+
+		assert.Equal(t, []evicted{{key: "b", reason: EvictionReasonMaxEntries}}, got)
+		_, err = c.Get(ctx, "b")
+		assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	})
+
+	t.Run("LFU evicts the least frequently used entry", func(t *testing.T) {
+		c := NewClient[string, int64](
+			WithMaxEntries[string, int64](2),
+			WithEvictionPolicy[string, int64](PolicyLFU),
+		)
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "a", 1))
+		assert.NoError(t, c.Set(ctx, "b", 2))
+		for range 3 {
+			_, _ = c.Get(ctx, "a")
+		}
+		_, _ = c.Get(ctx, "b")
+		assert.NoError(t, c.Set(ctx, "c", 3))
+
+		_, err := c.Get(ctx, "a")
+		assert.NoError(t, err)
+		_, err = c.Get(ctx, "b")
+		assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	})
+
+	t.Run("W-TinyLFU keeps popular entries during a scan", func(t *testing.T) {
+		c := NewClient[string, int64](
+			WithMaxEntries[string, int64](100),
+			WithEvictionPolicy[string, int64](PolicyWTinyLFU),
+		)
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "hot", 1))
+		for range 10 {
+			_, _ = c.Get(ctx, "hot")
+		}
+		for i := range 1000 {
+			assert.NoError(t, c.Set(ctx, fmt.Sprintf("scan-%d", i), int64(i)))
+		}
+
+		_, err := c.Get(ctx, "hot")
+		assert.NoError(t, err)
+	})
+
+	t.Run("max cost", func(t *testing.T) {
+		var got []evicted
+		c := NewClient[string, int64](
+			WithMaxCost[string, int64](10, func(key string, value int64) int64 { return value }),
+			WithOnEviction[string, int64](func(key string, value int64, reason EvictionReason) {
+				got = append(got, evicted{key: key, reason: reason})
+			}),
+		)
+		defer c.Close()
+
+		assert.NoError(t, c.Set(ctx, "a", 6))
+		assert.NoError(t, c.Set(ctx, "b", 4))
+		assert.Empty(t, got)
+		assert.NoError(t, c.Set(ctx, "c", 1))
+		assert.Equal(t, []evicted{{key: "a", reason: EvictionReasonMaxCost}}, got)
+	})
+
+	t.Run("concurrent writes keep the bounds in sync", func(t *testing.T) {
+		c := NewClient[string, int64](WithMaxEntries[string, int64](8))
+		defer c.Close()
+
+		var wg sync.WaitGroup
+		for w := range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range 1000 {
+					key := fmt.Sprintf("key-%d", (w*i)%32)
+					if i%5 == 0 {
+						assert.NoError(t, c.Delete(ctx, key))
+						continue
+					}
+					assert.NoError(t, c.Set(ctx, key, int64(i)))
+				}
+			}()
+		}
+		wg.Wait()
+
+		cli := c.(*client[string, int64])
+		assert.LessOrEqual(t, cli.cli.Len(), 8)
+		assert.Len(t, cli.bounds.costs, cli.cli.Len())
+		for key := range cli.bounds.costs {
+			assert.True(t, cli.cli.Has(key.key), key.key)
+		}
+	})
+}
+
+func Test_client_Close(t *testing.T) {
+	c := NewClient[string, int64](WithCleanUpInterval[string, int64](time.Millisecond))
+	c.Close()
+	c.Close()
+
+	cli, ok := c.(*client[string, int64])
+	assert.True(t, ok)
+	_, open := <-cli.done
+	assert.False(t, open)
+}
+
+func Test_client_InvalidateTags(t *testing.T) {
+	ctx := context.Background()
+	c := NewClient[string, int64](WithTTL[string, int64](time.Minute))
+	defer c.Close()
+
+	assert.NoError(t, c.Set(ctx, "profile:42", 1, cache.WithTags("user:42")))
+	assert.NoError(t, c.Set(ctx, "profile:43", 2, cache.WithTags("user:43")))
+	assert.NoError(t, cache.HSetWithOptions(ctx, c, "orders:42", []cache.KeyVal[string, int64]{{Key: "a", Value: 3}}, cache.WithTags("user:42", "orders")))
+	assert.NoError(t, c.Set(ctx, "untagged", 4))
+
+	assert.NoError(t, cache.InvalidateTags(ctx, c, "user:42"))
+	_, err := c.Get(ctx, "profile:42")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	_, err = c.HGet(ctx, "orders:42", "a")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	for _, key := range []string{"profile:43", "untagged"} {
+		_, err = c.Get(ctx, key)
+		assert.NoError(t, err)
+	}
+
+	// Expired entries are untagged by the cleanup.
+	cli, ok := c.(*client[string, int64])
+	assert.True(t, ok)
+	assert.NoError(t, c.Set(ctx, "short", 5, cache.WithTags("user:43"), cache.WithTTL(time.Millisecond)))
+	time.Sleep(5 * time.Millisecond)
+	cli.tags.prune(cli.exists)
+	assert.Equal(t, map[entryKey[string]]struct{}{{key: "profile:43"}: {}}, cli.tags.members["user:43"])
+	assert.Empty(t, cli.tags.members["orders"])
+}
@@ -0,0 +1,19 @@
+package cachelocal
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/cache/cachetest"
+)
+
+func TestClient_Conformance(t *testing.T) {
+	cachetest.Suite[string, int64]{
+		NewStore: func(t *testing.T, loader cache.Loader[string, int64]) cache.Store[string, int64] {
+			return NewClient[string, int64](WithLoader[string, int64](loader))
+		},
+		Key:   strconv.Itoa,
+		Value: func(i int) int64 { return int64(i) * 10 },
+	}.Run(t)
+}
@@ -0,0 +1,132 @@
+package cachelocal
+
+import (
+	"sync"
+)
+
+// EvictionReason tells why an entry was evicted.
+type EvictionReason int
+
+const (
+	// EvictionReasonExpired means the TTL of the entry elapsed.
+	EvictionReasonExpired EvictionReason = iota + 1
+	// EvictionReasonMaxEntries means the entry was evicted to stay within WithMaxEntries.
+	EvictionReasonMaxEntries
+	// EvictionReasonMaxCost means the entry was evicted to stay within WithMaxCost.
+	EvictionReasonMaxCost
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictionReasonExpired:
+		return "expired"
+	case EvictionReasonMaxEntries:
+		return "max_entries"
+	case EvictionReasonMaxCost:
+		return "max_cost"
+	default:
+		return "unknown"
+	}
+}
+
+// CostFunc returns the cost of an entry, e.g. its approximate size in bytes.
+type CostFunc[K comparable, V any] func(key K, value V) int64
+
+// EvictionFunc is called after an entry is evicted. For an evicted hash it is
+// called once per field, with the key of the hash.
+type EvictionFunc[K comparable, V any] func(key K, value V, reason EvictionReason)
+
+// entryKey identifies an entry of the client: hashes and plain values live in
+// separate ttlcache instances, so the same key may be used by both.
+type entryKey[K comparable] struct {
+	key  K
+	hash bool
+}
+
+type victim[K comparable] struct {
+	entryKey[K]
+	reason EvictionReason
+}
+
+// bounds keeps the client within its entry and cost limits. It only tracks
+// keys and costs, the values stay in ttlcache.
+type bounds[K comparable] struct {
+	mu         sync.Mutex
+	policy     policy[entryKey[K]]
+	costs      map[entryKey[K]]int64
+	total      int64
+	maxEntries int
+	maxCost    int64
+}
+
+func newBounds[K comparable](maxEntries int, maxCost int64, p EvictionPolicy) *bounds[K] {
+	return &bounds[K]{
+		policy:     newPolicy[entryKey[K]](p, maxEntries),
+		costs:      make(map[entryKey[K]]int64),
+		maxEntries: maxEntries,
+		maxCost:    maxCost,
+	}
+}
+
+// add records a write and returns the entries to evict to get back within
+// bounds. The written entry itself may be one of them.
+func (b *bounds[K]) add(key entryKey[K], cost int64) []victim[K] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.total += cost - b.costs[key]
+	b.costs[key] = cost
+	b.policy.add(key)
+
+	var victims []victim[K]
+	for {
+		reason := b.exceeded()
+		if reason == 0 {
+			return victims
+		}
+
+		key, ok := b.policy.victim()
+		if !ok {
+			return victims
+		}
+		b.removeLocked(key)
+		victims = append(victims, victim[K]{entryKey: key, reason: reason})
+	}
+}
+
+func (b *bounds[K]) access(key entryKey[K]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.costs[key]; ok {
+		b.policy.access(key)
+	}
+}
+
+func (b *bounds[K]) remove(key entryKey[K]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.removeLocked(key)
+}
+
+func (b *bounds[K]) removeLocked(key entryKey[K]) {
+	cost, ok := b.costs[key]
+	if !ok {
+		return
+	}
+	b.total -= cost
+	delete(b.costs, key)
+	b.policy.remove(key)
+}
+
+func (b *bounds[K]) exceeded() EvictionReason {
+	switch {
+	case b.maxEntries > 0 && len(b.costs) > b.maxEntries:
+		return EvictionReasonMaxEntries
+	case b.maxCost > 0 && b.total > b.maxCost:
+		return EvictionReasonMaxCost
+	default:
+		return 0
+	}
+}
@@ -11,7 +11,15 @@ type Option[K comparable, V any] func(*Options[K, V])
 type Options[K comparable, V any] struct {
 	Loader          cache.Loader[K, V]
 	TTL             time.Duration
+	TTLJitter       float64
 	CleanUpInterval time.Duration
+	NegativeTTL     time.Duration
+	IsNotFound      cache.NotFoundFunc
+	MaxEntries      int
+	MaxCost         int64
+	Cost            CostFunc[K, V]
+	EvictionPolicy  EvictionPolicy
+	OnEviction      EvictionFunc[K, V]
 }
 
 func WithLoader[K comparable, V any](loader cache.Loader[K, V]) Option[K, V] {
@@ -28,16 +36,79 @@ func WithTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
 	}
 }
 
+// WithTTLJitter randomizes every TTL written by the client by up to ±fraction
+// (e.g. 0.1 for 10%), so keys written together don't all expire together.
+func WithTTLJitter[K comparable, V any](fraction float64) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.TTLJitter = fraction
+	}
+}
+
 func WithCleanUpInterval[K comparable, V any](interval time.Duration) Option[K, V] {
 	return func(o *Options[K, V]) {
 		o.CleanUpInterval = interval
 	}
 }
 
+// WithNegativeCache enables negative caching: when the Loader fails with an error
+// for which isNotFound returns true, a tombstone is kept for ttl and Get/BulkGet
+// return cache.ErrorKeyNotFound from it without calling the Loader again.
+// A nil isNotFound only matches cache.ErrorKeyNotFound.
+func WithNegativeCache[K comparable, V any](ttl time.Duration, isNotFound cache.NotFoundFunc) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.NegativeTTL = ttl
+		if isNotFound != nil {
+			o.IsNotFound = isNotFound
+		}
+	}
+}
+
+// WithMaxEntries bounds the number of entries, a hash counting as one entry.
+// Entries are evicted according to the eviction policy.
+func WithMaxEntries[K comparable, V any](maxEntries int) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.MaxEntries = maxEntries
+	}
+}
+
+// WithMaxCost bounds the total cost of the entries, e.g. their size in bytes.
+// The cost of a hash is the sum of the cost of its fields. A nil cost counts
+// every entry and field as 1.
+func WithMaxCost[K comparable, V any](maxCost int64, cost CostFunc[K, V]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.MaxCost = maxCost
+		if cost != nil {
+			o.Cost = cost
+		}
+	}
+}
+
+// WithEvictionPolicy sets the policy used to stay within WithMaxEntries and
+// WithMaxCost. Defaults to PolicyLRU.
+func WithEvictionPolicy[K comparable, V any](policy EvictionPolicy) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.EvictionPolicy = policy
+	}
+}
+
+// WithOnEviction registers a callback for evicted entries. Expired entries are
+// reported asynchronously, entries evicted by the bounds synchronously after
+// the write that evicted them. Deleted entries aren't reported.
+func WithOnEviction[K comparable, V any](fn EvictionFunc[K, V]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.OnEviction = fn
+	}
+}
+
 func defaultOption[K comparable, V any]() *Options[K, V] {
 	return &Options[K, V]{
 		Loader:          nil,
 		TTL:             5 * time.Minute,
 		CleanUpInterval: time.Hour,
+		IsNotFound:      cache.IsErrorKeyNotFound,
+		EvictionPolicy:  PolicyLRU,
+		Cost: func(K, V) int64 {
+			return 1
+		},
 	}
 }
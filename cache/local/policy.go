@@ -0,0 +1,331 @@
+package cachelocal
+
+import (
+	"container/heap"
+	"container/list"
+
+	"github.com/trinhdaiphuc/go-kit/cache/internal/sketch"
+)
+
+// EvictionPolicy chooses which entry is evicted when the client is over its
+// WithMaxEntries or WithMaxCost bound.
+type EvictionPolicy int
+
+const (
+	// PolicyLRU evicts the least recently used entry.
+	PolicyLRU EvictionPolicy = iota
+	// PolicyLFU evicts the least frequently used entry, the least recently used
+	// one on ties.
+	PolicyLFU
+	// PolicyWTinyLFU keeps new entries in a small LRU window and only admits them
+	// to the main segmented LRU if they are used more often than the entry they
+	// would replace, as estimated by a count-min sketch. It resists scans and
+	// one-hit wonders better than LRU.
+	PolicyWTinyLFU
+)
+
+// policy tracks the entries of a bounded client. The caller serializes the calls.
+type policy[K comparable] interface {
+	// add records a new entry, or an access if the entry is already tracked.
+	add(key K)
+	access(key K)
+	remove(key K)
+	// victim returns the next entry to evict. It doesn't remove it.
+	victim() (K, bool)
+}
+
+func newPolicy[K comparable](p EvictionPolicy, capacity int) policy[K] {
+	switch p {
+	case PolicyLFU:
+		return newLFU[K]()
+	case PolicyWTinyLFU:
+		return newWTinyLFU[K](capacity)
+	default:
+		return newLRU[K]()
+	}
+}
+
+// lru is a recency list, most recently used first.
+type lru[K comparable] struct {
+	order    *list.List
+	elements map[K]*list.Element
+}
+
+func newLRU[K comparable]() *lru[K] {
+	return &lru[K]{
+		order:    list.New(),
+		elements: make(map[K]*list.Element),
+	}
+}
+
+func (l *lru[K]) add(key K) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.elements[key] = l.order.PushFront(key)
+}
+
+func (l *lru[K]) access(key K) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.MoveToFront(elem)
+	}
+}
+
+func (l *lru[K]) remove(key K) {
+	if elem, ok := l.elements[key]; ok {
+		l.order.Remove(elem)
+		delete(l.elements, key)
+	}
+}
+
+func (l *lru[K]) victim() (key K, ok bool) {
+	back := l.order.Back()
+	if back == nil {
+		return key, false
+	}
+	key, ok = back.Value.(K)
+	return key, ok
+}
+
+func (l *lru[K]) contains(key K) bool {
+	_, ok := l.elements[key]
+	return ok
+}
+
+func (l *lru[K]) len() int {
+	return len(l.elements)
+}
+
+// lfu is a min-heap ordered by access count, then by last access. The newest
+// entry is never the victim while there are others: it would always have the
+// lowest count and could never stay.
+type lfu[K comparable] struct {
+	entries map[K]*lfuEntry[K]
+	heap    lfuHeap[K]
+	clock   uint64
+	newest  *lfuEntry[K]
+}
+
+type lfuEntry[K comparable] struct {
+	key   K
+	freq  uint64
+	seq   uint64
+	index int
+}
+
+func newLFU[K comparable]() *lfu[K] {
+	return &lfu[K]{entries: make(map[K]*lfuEntry[K])}
+}
+
+func (l *lfu[K]) add(key K) {
+	if _, ok := l.entries[key]; ok {
+		l.access(key)
+		return
+	}
+	l.clock++
+	entry := &lfuEntry[K]{key: key, freq: 1, seq: l.clock}
+	l.entries[key] = entry
+	l.newest = entry
+	heap.Push(&l.heap, entry)
+}
+
+func (l *lfu[K]) access(key K) {
+	entry, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	l.clock++
+	entry.freq++
+	entry.seq = l.clock
+	heap.Fix(&l.heap, entry.index)
+}
+
+func (l *lfu[K]) remove(key K) {
+	entry, ok := l.entries[key]
+	if !ok {
+		return
+	}
+	heap.Remove(&l.heap, entry.index)
+	delete(l.entries, key)
+	if l.newest == entry {
+		l.newest = nil
+	}
+}
+
+func (l *lfu[K]) victim() (key K, ok bool) {
+	switch {
+	case len(l.heap) == 0:
+		return key, false
+	case l.heap[0] != l.newest || len(l.heap) == 1:
+		return l.heap[0].key, true
+	case len(l.heap) == 2 || l.heap.Less(1, 2):
+		// The runner-up of a heap is one of the children of the root.
+		return l.heap[1].key, true
+	default:
+		return l.heap[2].key, true
+	}
+}
+
+type lfuHeap[K comparable] []*lfuEntry[K]
+
+func (h lfuHeap[K]) Len() int { return len(h) }
+
+func (h lfuHeap[K]) Less(i, j int) bool {
+	if h[i].freq != h[j].freq {
+		return h[i].freq < h[j].freq
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h lfuHeap[K]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap[K]) Push(x any) {
+	entry, _ := x.(*lfuEntry[K])
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *lfuHeap[K]) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// wTinyLFU is the W-TinyLFU policy: an LRU window of 1% of the capacity in front
+// of a segmented LRU main space (20% probation, 80% protected). An entry leaving
+// the window is only admitted to a full main space if its estimated frequency is
+// higher than the one of the probation LRU entry; the loser is rejected and
+// becomes the next victim.
+//
+// Without WithMaxEntries the capacity is the number of entries at the first
+// eviction.
+type wTinyLFU[K comparable] struct {
+	sketch     *sketch.Sketch[K]
+	window     *lru[K]
+	probation  *lru[K]
+	protected  *lru[K]
+	rejected   *lru[K]
+	capacity   int
+	additions  int
+	sampleSize int
+}
+
+func newWTinyLFU[K comparable](capacity int) *wTinyLFU[K] {
+	width := capacity
+	if width <= 0 {
+		width = 4096
+	}
+	return &wTinyLFU[K]{
+		sketch:     sketch.New[K](width),
+		window:     newLRU[K](),
+		probation:  newLRU[K](),
+		protected:  newLRU[K](),
+		rejected:   newLRU[K](),
+		capacity:   capacity,
+		sampleSize: 10 * width,
+	}
+}
+
+func (w *wTinyLFU[K]) add(key K) {
+	if w.contains(key) {
+		w.access(key)
+		return
+	}
+	w.increment(key)
+	w.window.add(key)
+	w.drainWindow()
+}
+
+func (w *wTinyLFU[K]) access(key K) {
+	w.increment(key)
+
+	switch {
+	case w.window.contains(key):
+		w.window.access(key)
+	case w.probation.contains(key):
+		w.probation.remove(key)
+		w.protected.add(key)
+		// Keep the protected segment at 80% of the main space.
+		if w.protected.len() > 4*(w.probation.len()+w.protected.len())/5 {
+			if demoted, ok := w.protected.victim(); ok {
+				w.protected.remove(demoted)
+				w.probation.add(demoted)
+			}
+		}
+	case w.protected.contains(key):
+		w.protected.access(key)
+	}
+}
+
+func (w *wTinyLFU[K]) remove(key K) {
+	w.window.remove(key)
+	w.probation.remove(key)
+	w.protected.remove(key)
+	w.rejected.remove(key)
+}
+
+func (w *wTinyLFU[K]) victim() (key K, ok bool) {
+	if w.capacity <= 0 {
+		w.capacity = w.len()
+		w.drainWindow()
+	}
+
+	for _, segment := range []*lru[K]{w.rejected, w.probation, w.protected, w.window} {
+		if key, ok = segment.victim(); ok {
+			return key, true
+		}
+	}
+	return key, false
+}
+
+// drainWindow moves the entries overflowing the window to the main space,
+// running the admission when the main space is full.
+func (w *wTinyLFU[K]) drainWindow() {
+	windowMax := max(1, w.capacity/100)
+	for w.window.len() > windowMax {
+		candidate, _ := w.window.victim()
+		w.window.remove(candidate)
+
+		mainVictim, ok := w.probation.victim()
+		if !ok {
+			mainVictim, ok = w.protected.victim()
+		}
+		if w.capacity <= 0 || !ok || w.probation.len()+w.protected.len() < w.capacity-windowMax {
+			w.probation.add(candidate)
+			continue
+		}
+
+		if w.sketch.Estimate(candidate) > w.sketch.Estimate(mainVictim) {
+			w.probation.remove(mainVictim)
+			w.protected.remove(mainVictim)
+			w.rejected.add(mainVictim)
+			w.probation.add(candidate)
+		} else {
+			w.rejected.add(candidate)
+		}
+	}
+}
+
+func (w *wTinyLFU[K]) contains(key K) bool {
+	return w.window.contains(key) || w.probation.contains(key) || w.protected.contains(key) || w.rejected.contains(key)
+}
+
+func (w *wTinyLFU[K]) len() int {
+	return w.window.len() + w.probation.len() + w.protected.len() + w.rejected.len()
+}
+
+func (w *wTinyLFU[K]) increment(key K) {
+	w.sketch.Increment(key)
+	w.additions++
+	if w.additions >= w.sampleSize {
+		w.sketch.Halve()
+		w.additions = 0
+	}
+}
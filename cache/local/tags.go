@@ -0,0 +1,84 @@
+package cachelocal
+
+import (
+	"sync"
+)
+
+// tagIndex maps the tags of cache.WithTags to the entries written with them,
+// and back, so that untagging an entry doesn't scan every tag.
+type tagIndex[K comparable] struct {
+	mu      sync.Mutex
+	members map[string]map[entryKey[K]]struct{}
+	tags    map[entryKey[K]]map[string]struct{}
+}
+
+func newTagIndex[K comparable]() *tagIndex[K] {
+	return &tagIndex[K]{
+		members: make(map[string]map[entryKey[K]]struct{}),
+		tags:    make(map[entryKey[K]]map[string]struct{}),
+	}
+}
+
+func (t *tagIndex[K]) add(key entryKey[K], tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tags[key] == nil {
+		t.tags[key] = make(map[string]struct{}, len(tags))
+	}
+	for _, tag := range tags {
+		if t.members[tag] == nil {
+			t.members[tag] = make(map[entryKey[K]]struct{})
+		}
+		t.members[tag][key] = struct{}{}
+		t.tags[key][tag] = struct{}{}
+	}
+}
+
+func (t *tagIndex[K]) remove(key entryKey[K]) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.removeLocked(key)
+}
+
+func (t *tagIndex[K]) removeLocked(key entryKey[K]) {
+	for tag := range t.tags[key] {
+		delete(t.members[tag], key)
+		if len(t.members[tag]) == 0 {
+			delete(t.members, tag)
+		}
+	}
+	delete(t.tags, key)
+}
+
+// take untags and returns the entries written with one of the tags.
+func (t *tagIndex[K]) take(tags []string) []entryKey[K] {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var keys []entryKey[K]
+	for _, tag := range tags {
+		for key := range t.members[tag] {
+			keys = append(keys, key)
+			t.removeLocked(key)
+		}
+	}
+	return keys
+}
+
+// prune untags the entries for which exists returns false, e.g. expired ones.
+func (t *tagIndex[K]) prune(exists func(key entryKey[K]) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key := range t.tags {
+		if !exists(key) {
+			t.removeLocked(key)
+		}
+	}
+}
@@ -215,32 +215,42 @@ func (mr *MockStoreMockRecorder[K, V]) Ping(ctx any) *gomock.Call {
 }
 
 // Set mocks base method.
-func (m *MockStore[K, V]) Set(ctx context.Context, key K, value V) error {
+func (m *MockStore[K, V]) Set(ctx context.Context, key K, value V, opts ...cache.WriteOption) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Set", ctx, key, value)
+	varargs := []any{ctx, key, value}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Set", varargs...)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Set indicates an expected call of Set.
-func (mr *MockStoreMockRecorder[K, V]) Set(ctx, key, value any) *gomock.Call {
+func (mr *MockStoreMockRecorder[K, V]) Set(ctx, key, value any, opts ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockStore[K, V])(nil).Set), ctx, key, value)
+	varargs := append([]any{ctx, key, value}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockStore[K, V])(nil).Set), varargs...)
 }
 
 // SetNX mocks base method.
-func (m *MockStore[K, V]) SetNX(ctx context.Context, key K, value V) (bool, error) {
+func (m *MockStore[K, V]) SetNX(ctx context.Context, key K, value V, opts ...cache.WriteOption) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SetNX", ctx, key, value)
+	varargs := []any{ctx, key, value}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "SetNX", varargs...)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // SetNX indicates an expected call of SetNX.
-func (mr *MockStoreMockRecorder[K, V]) SetNX(ctx, key, value any) *gomock.Call {
+func (mr *MockStoreMockRecorder[K, V]) SetNX(ctx, key, value any, opts ...any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNX", reflect.TypeOf((*MockStore[K, V])(nil).SetNX), ctx, key, value)
+	varargs := append([]any{ctx, key, value}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetNX", reflect.TypeOf((*MockStore[K, V])(nil).SetNX), varargs...)
 }
 
 // TTL mocks base method.
@@ -258,6 +268,108 @@ func (mr *MockStoreMockRecorder[K, V]) TTL(ctx, key any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TTL", reflect.TypeOf((*MockStore[K, V])(nil).TTL), ctx, key)
 }
 
+// MockBulkSetter is a mock of BulkSetter interface.
+type MockBulkSetter[K comparable, V any] struct {
+	ctrl     *gomock.Controller
+	recorder *MockBulkSetterMockRecorder[K, V]
+	isgomock struct{}
+}
+
+// MockBulkSetterMockRecorder is the mock recorder for MockBulkSetter.
+type MockBulkSetterMockRecorder[K comparable, V any] struct {
+	mock *MockBulkSetter[K, V]
+}
+
+// NewMockBulkSetter creates a new mock instance.
+func NewMockBulkSetter[K comparable, V any](ctrl *gomock.Controller) *MockBulkSetter[K, V] {
+	mock := &MockBulkSetter[K, V]{ctrl: ctrl}
+	mock.recorder = &MockBulkSetterMockRecorder[K, V]{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockBulkSetter[K, V]) EXPECT() *MockBulkSetterMockRecorder[K, V] {
+	return m.recorder
+}
+
+// BulkSet mocks base method.
+func (m *MockBulkSetter[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, keyVals}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "BulkSet", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BulkSet indicates an expected call of BulkSet.
+func (mr *MockBulkSetterMockRecorder[K, V]) BulkSet(ctx, keyVals any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, keyVals}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BulkSet", reflect.TypeOf((*MockBulkSetter[K, V])(nil).BulkSet), varargs...)
+}
+
+// MockCompareAndSwapper is a mock of CompareAndSwapper interface.
+type MockCompareAndSwapper[K comparable, V any] struct {
+	ctrl     *gomock.Controller
+	recorder *MockCompareAndSwapperMockRecorder[K, V]
+	isgomock struct{}
+}
+
+// MockCompareAndSwapperMockRecorder is the mock recorder for MockCompareAndSwapper.
+type MockCompareAndSwapperMockRecorder[K comparable, V any] struct {
+	mock *MockCompareAndSwapper[K, V]
+}
+
+// NewMockCompareAndSwapper creates a new mock instance.
+func NewMockCompareAndSwapper[K comparable, V any](ctrl *gomock.Controller) *MockCompareAndSwapper[K, V] {
+	mock := &MockCompareAndSwapper[K, V]{ctrl: ctrl}
+	mock.recorder = &MockCompareAndSwapperMockRecorder[K, V]{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCompareAndSwapper[K, V]) EXPECT() *MockCompareAndSwapperMockRecorder[K, V] {
+	return m.recorder
+}
+
+// CompareAndDelete mocks base method.
+func (m *MockCompareAndSwapper[K, V]) CompareAndDelete(ctx context.Context, key K, old V) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompareAndDelete", ctx, key, old)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndDelete indicates an expected call of CompareAndDelete.
+func (mr *MockCompareAndSwapperMockRecorder[K, V]) CompareAndDelete(ctx, key, old any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndDelete", reflect.TypeOf((*MockCompareAndSwapper[K, V])(nil).CompareAndDelete), ctx, key, old)
+}
+
+// CompareAndSet mocks base method.
+func (m *MockCompareAndSwapper[K, V]) CompareAndSet(ctx context.Context, key K, old, value V, opts ...cache.WriteOption) (bool, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, key, old, value}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "CompareAndSet", varargs...)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CompareAndSet indicates an expected call of CompareAndSet.
+func (mr *MockCompareAndSwapperMockRecorder[K, V]) CompareAndSet(ctx, key, old, value any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, key, old, value}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompareAndSet", reflect.TypeOf((*MockCompareAndSwapper[K, V])(nil).CompareAndSet), varargs...)
+}
+
 // MockLoader is a mock of Loader interface.
 type MockLoader[K comparable, V any] struct {
 	ctrl     *gomock.Controller
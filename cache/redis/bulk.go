@@ -0,0 +1,114 @@
+package cacheredis
+
+import (
+	"context"
+	"slices"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/errgroup"
+)
+
+// A bulk operation is split into pipelines of at most ChunkSize keys. Each
+// pipeline holds one command per group of keys of the same cluster slot, so that
+// multi-key commands never fail with CROSSSLOT. The pipelines run in parallel.
+
+// plan splits the indexes of keys into pipelines of commands.
+func (c *redisCache[K, V]) plan(keys []string) [][][]int {
+	var groups [][]int
+	if _, ok := c.client.(*redis.ClusterClient); ok {
+		bySlot := make(map[int]int)
+		for i, key := range keys {
+			s := slot(key)
+			g, ok := bySlot[s]
+			if !ok {
+				g = len(groups)
+				bySlot[s] = g
+				groups = append(groups, nil)
+			}
+			groups[g] = append(groups[g], i)
+		}
+	} else {
+		all := make([]int, len(keys))
+		for i := range keys {
+			all[i] = i
+		}
+		groups = [][]int{all}
+	}
+
+	chunkSize := max(1, c.opts.ChunkSize)
+	var (
+		pipelines [][][]int
+		current   [][]int
+		size      int
+	)
+	for _, group := range groups {
+		for chunk := range slices.Chunk(group, chunkSize) {
+			if size+len(chunk) > chunkSize && len(current) > 0 {
+				pipelines = append(pipelines, current)
+				current, size = nil, 0
+			}
+			current = append(current, chunk)
+			size += len(chunk)
+		}
+	}
+	if len(current) > 0 {
+		pipelines = append(pipelines, current)
+	}
+	return pipelines
+}
+
+// pipelined runs the pipelines planned for keys in parallel. queue adds the
+// command of a group of keys to the pipeline and returns a function reading its
+// result once the pipeline has run.
+func (c *redisCache[K, V]) pipelined(ctx context.Context, keys []string, queue func(pipe redis.Pipeliner, indexes []int) func()) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(1, c.opts.BulkConcurrency))
+
+	for _, pipeline := range c.plan(keys) {
+		g.Go(func() error {
+			results := make([]func(), 0, len(pipeline))
+			_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for _, indexes := range pipeline {
+					results = append(results, queue(pipe, indexes))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, result := range results {
+				result()
+			}
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// mget reads keys with pipelined MGET commands. The values are in the order of
+// keys, nil for the missing ones.
+func (c *redisCache[K, V]) mget(ctx context.Context, keys []string) ([]any, error) {
+	result := make([]any, len(keys))
+	err := c.pipelined(ctx, keys, func(pipe redis.Pipeliner, indexes []int) func() {
+		cmd := pipe.MGet(ctx, pick(keys, indexes)...)
+		return func() {
+			for i, value := range cmd.Val() {
+				result[indexes[i]] = value
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func pick[T any](values []T, indexes []int) []T {
+	picked := make([]T, 0, len(indexes))
+	for _, i := range indexes {
+		picked = append(picked, values[i])
+	}
+	return picked
+}
@@ -0,0 +1,100 @@
+package cacheredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+func Test_slot(t *testing.T) {
+	assert.Equal(t, 12182, slot("foo"))
+	assert.Equal(t, slot("user:1"), slot("{user:1}:profile"))
+	assert.Equal(t, slot("{user:1}:profile"), slot("{user:1}:settings"))
+	// An empty hash tag hashes the whole key.
+	assert.Equal(t, int(crc16("{}foo"))%slotCount, slot("{}foo"))
+}
+
+func Test_redisCache_plan(t *testing.T) {
+	keys := []string{"{a}1", "{b}1", "{a}2", "{a}3", "{b}2"}
+
+	t.Run("standalone", func(t *testing.T) {
+		c := &redisCache[string, int]{client: redis.NewClient(&redis.Options{}), opts: &Options[string, int]{ChunkSize: 2}}
+		assert.Equal(t, [][][]int{{{0, 1}}, {{2, 3}}, {{4}}}, c.plan(keys))
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		c := &redisCache[string, int]{client: redis.NewClusterClient(&redis.ClusterOptions{}), opts: &Options[string, int]{ChunkSize: 3}}
+		assert.Equal(t, [][][]int{{{0, 2, 3}}, {{1, 4}}}, c.plan(keys))
+
+		c.opts.ChunkSize = 10
+		assert.Equal(t, [][][]int{{{0, 2, 3}, {1, 4}}}, c.plan(keys))
+	})
+}
+
+func Test_redisCache_BulkSet(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"))
+	ctx := context.Background()
+
+	mock.ExpectSet("test:a", `{"name":"a","value":1}`, time.Hour).SetVal("OK")
+	mock.ExpectSet("test:b", `{"name":"b","value":2}`, time.Hour).SetVal("OK")
+	err := repo.BulkSet(ctx, []cache.KeyVal[string, *Data]{
+		{Key: "a", Value: &Data{Name: "a", Value: 1}},
+		{Key: "b", Value: &Data{Name: "b", Value: 2}},
+	}, cache.WithTTL(time.Hour))
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_redisCache_BulkGet_Chunked(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client,
+		WithPrefix[string, *Data]("test"),
+		WithChunkSize[string, *Data](2),
+		WithBulkConcurrency[string, *Data](1),
+	)
+	ctx := context.Background()
+
+	mock.ExpectMGet("test:a", "test:b").SetVal([]any{`{"name":"a","value":1}`, `{"name":"b","value":2}`})
+	mock.ExpectMGet("test:c").SetVal([]any{`{"name":"c","value":3}`})
+	got, err := repo.BulkGet(ctx, []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Data{"a": {Name: "a", Value: 1}, "b": {Name: "b", Value: 2}, "c": {Name: "c", Value: 3}}, got)
+
+	mock.ExpectDel("test:a", "test:b").SetVal(2)
+	mock.ExpectDel("test:c").SetVal(1)
+	assert.NoError(t, repo.Delete(ctx, "a", "b", "c"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_redisCache_BulkGet_Missing(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	ctx := context.Background()
+
+	// Without a Loader the missing keys are left out, as the cachetest suite
+	// expects of every store.
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"))
+	mock.ExpectMGet("test:a", "test:b", "test:c").SetVal([]any{`{"name":"a","value":1}`, `{"name":"b","value":2}`, nil})
+	got, err := repo.BulkGet(ctx, []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Data{"a": {Name: "a", Value: 1}, "b": {Name: "b", Value: 2}}, got)
+
+	// So they are when the Loader is skipped.
+	repo = NewRedisCache[string, *Data](client,
+		WithPrefix[string, *Data]("test"),
+		WithLoader[string, *Data](&ttlLoader{}),
+	)
+	mock.ExpectMGet("test:a", "test:c").SetVal([]any{`{"name":"a","value":1}`, nil})
+	got, err = repo.BulkGet(cache.WithoutLoader(ctx), []string{"a", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]*Data{"a": {Name: "a", Value: 1}}, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
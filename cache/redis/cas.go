@@ -0,0 +1,75 @@
+package cacheredis
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+// compareAndSetScript writes ARGV[2] to KEYS[1] if it holds ARGV[1].
+// ARGV[3]: TTL in ms (0: no expiry, -1: keep the TTL).
+var compareAndSetScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+  return 0
+end
+local ttl = tonumber(ARGV[3])
+if ttl > 0 then
+  redis.call('SET', KEYS[1], ARGV[2], 'PX', ttl)
+elseif ttl < 0 then
+  redis.call('SET', KEYS[1], ARGV[2], 'KEEPTTL')
+else
+  redis.call('SET', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+// compareAndDeleteScript deletes KEYS[1] if it holds ARGV[1].
+var compareAndDeleteScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+  return 0
+end
+return redis.call('DEL', KEYS[1])
+`)
+
+// CompareAndSet writes value when key holds old, in a Lua script. The encoded
+// values are compared, so old must encode to the bytes that were written, as
+// the values of a deterministic codec do.
+func (c *redisCache[K, V]) CompareAndSet(ctx context.Context, key K, old, value V, opts ...cache.WriteOption) (bool, error) {
+	oldData, err := c.marshal(old)
+	if err != nil {
+		return false, err
+	}
+	data, err := c.marshal(value)
+	if err != nil {
+		return false, err
+	}
+	if err = c.tag(ctx, []string{c.encodeKey(key)}, opts); err != nil {
+		return false, err
+	}
+	defer c.near.evict(c.encodeKey(key))
+
+	ttl := int64(-1)
+	if expiration := c.expiration(opts); expiration != redis.KeepTTL {
+		ttl = expiration.Milliseconds()
+		if expiration > 0 {
+			ttl = max(ttl, 1)
+		}
+	}
+	n, err := compareAndSetScript.Run(ctx, c.client, []string{c.encodeKey(key)}, oldData, data, ttl).Int()
+	return n == 1, err
+}
+
+// CompareAndDelete deletes key when it holds old, in a Lua script. The encoded
+// values are compared, see CompareAndSet.
+func (c *redisCache[K, V]) CompareAndDelete(ctx context.Context, key K, old V) (bool, error) {
+	oldData, err := c.marshal(old)
+	if err != nil {
+		return false, err
+	}
+	defer c.near.evict(c.encodeKey(key))
+
+	n, err := compareAndDeleteScript.Run(ctx, c.client, []string{c.encodeKey(key)}, oldData).Int()
+	return n == 1, err
+}
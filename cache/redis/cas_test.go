@@ -0,0 +1,41 @@
+package cacheredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+func Test_redisCache_CompareAndSwap(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"), WithTTL[string, *Data](time.Minute))
+	ctx := context.Background()
+	old, value := &Data{Name: "old"}, &Data{Name: "new"}
+
+	mock.ExpectEvalSha(compareAndSetScript.Hash(), []string{"test:key"}, `{"name":"old","value":0}`, `{"name":"new","value":0}`, int64(60000)).SetVal(int64(1))
+	ok, err := repo.CompareAndSet(ctx, "key", old, value)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectEvalSha(compareAndSetScript.Hash(), []string{"test:key"}, `{"name":"old","value":0}`, `{"name":"new","value":0}`, int64(-1)).SetVal(int64(0))
+	ok, err = repo.CompareAndSet(ctx, "key", old, value, cache.WithKeepTTL())
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	mock.ExpectEvalSha(compareAndSetScript.Hash(), []string{"test:key"}, `{"name":"old","value":0}`, `{"name":"new","value":0}`, int64(0)).SetVal(int64(1))
+	ok, err = repo.CompareAndSet(ctx, "key", old, value, cache.WithNoExpiry())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectEvalSha(compareAndDeleteScript.Hash(), []string{"test:key"}, `{"name":"new","value":0}`).SetVal(int64(1))
+	ok, err = repo.CompareAndDelete(ctx, "key", value)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,7 +2,10 @@ package cacheredis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -20,28 +23,55 @@ const (
 	DefaultPort            int32         = 6379
 	DefaultPoolSize        int           = 10
 	DefaultIdleConnections int           = 5
+	DefaultDialTimeout     time.Duration = 5 * time.Second
 	DefaultReadTimeout     time.Duration = 3 * time.Second
 	DefaultWriteTimeout    time.Duration = 3 * time.Second
 	DefaultConnMaxIdleTime time.Duration = 30 * time.Minute
 )
 
-// Config configuration
+// Config describes a connection to Redis: a single node, a Sentinel-managed
+// master (MasterName set, Addresses listing the sentinels) or a cluster. Every
+// Redis consumer of the kit is built from it, see NewClient.
 type Config struct {
-	Addresses       string        `json:"addresses,omitempty" mapstructure:"addresses"`
-	Host            string        `json:"host,omitempty" mapstructure:"host"`
-	Password        string        `json:"password,omitempty" mapstructure:"password"`
-	Prefix          string        `json:"prefix,omitempty" mapstructure:"prefix"`
-	MasterName      string        `json:"master_name,omitempty" mapstructure:"master_name"` // For Sentinel mode
-	ReadTimeout     time.Duration `json:"read_timeout,omitempty" mapstructure:"read_timeout"`
-	WriteTimeout    time.Duration `json:"write_timeout,omitempty" mapstructure:"write_timeout"`
-	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time,omitempty" mapstructure:"conn_max_idle_time"` // Close connections after remaining idle for this duration
-	Port            int32         `json:"port,omitempty" mapstructure:"port"`
-	DB              int           `json:"db,omitempty" mapstructure:"db"`
-	PoolSize        int           `json:"pool_size,omitempty" mapstructure:"pool_size"`
-	IdleConnections int           `json:"idle_connections,omitempty" mapstructure:"idle_connections"`
-	Cluster         bool          `json:"cluster" mapstructure:"cluster"`
-	EnableMonitor   bool          `json:"enable_monitor" mapstructure:"enable_monitor"`
-	EnableTracing   bool          `json:"enable_tracing" mapstructure:"enable_tracing"`
+	Addresses  string `json:"addresses,omitempty" mapstructure:"addresses"`
+	Host       string `json:"host,omitempty" mapstructure:"host"`
+	Username   string `json:"username,omitempty" mapstructure:"username"` // ACL user, Redis 6+
+	Password   string `json:"password,omitempty" mapstructure:"password"`
+	ClientName string `json:"client_name,omitempty" mapstructure:"client_name"`
+	Prefix     string `json:"prefix,omitempty" mapstructure:"prefix"`
+	MasterName string `json:"master_name,omitempty" mapstructure:"master_name"` // For Sentinel mode
+	// SentinelUsername and SentinelPassword authenticate to the sentinels, which
+	// may use other credentials than the master.
+	SentinelUsername string        `json:"sentinel_username,omitempty" mapstructure:"sentinel_username"`
+	SentinelPassword string        `json:"sentinel_password,omitempty" mapstructure:"sentinel_password"`
+	DialTimeout      time.Duration `json:"dial_timeout,omitempty" mapstructure:"dial_timeout"`
+	ReadTimeout      time.Duration `json:"read_timeout,omitempty" mapstructure:"read_timeout"`
+	WriteTimeout     time.Duration `json:"write_timeout,omitempty" mapstructure:"write_timeout"`
+	PoolTimeout      time.Duration `json:"pool_timeout,omitempty" mapstructure:"pool_timeout"`             // Wait for a free connection at most this long, ReadTimeout + 1s by default
+	ConnMaxIdleTime  time.Duration `json:"conn_max_idle_time,omitempty" mapstructure:"conn_max_idle_time"` // Close connections after remaining idle for this duration
+	ConnMaxLifetime  time.Duration `json:"conn_max_lifetime,omitempty" mapstructure:"conn_max_lifetime"`   // Close connections older than this duration, never by default
+	Port             int32         `json:"port,omitempty" mapstructure:"port"`
+	DB               int           `json:"db,omitempty" mapstructure:"db"` // Ignored in cluster mode
+	PoolSize         int           `json:"pool_size,omitempty" mapstructure:"pool_size"`
+	IdleConnections  int           `json:"idle_connections,omitempty" mapstructure:"idle_connections"`
+	MaxRetries       int           `json:"max_retries,omitempty" mapstructure:"max_retries"` // 3 by default, -1 disables the retries
+	// TLS enables TLS. It is implied by TLSCAFile and TLSCertFile.
+	TLS bool `json:"tls" mapstructure:"tls"`
+	// TLSCAFile is a PEM file of the CAs to verify the server with, instead of
+	// the system pool.
+	TLSCAFile string `json:"tls_ca_file,omitempty" mapstructure:"tls_ca_file"`
+	// TLSCertFile and TLSKeyFile are the PEM files of the client certificate,
+	// for mutual TLS.
+	TLSCertFile   string `json:"tls_cert_file,omitempty" mapstructure:"tls_cert_file"`
+	TLSKeyFile    string `json:"tls_key_file,omitempty" mapstructure:"tls_key_file"`
+	TLSServerName string `json:"tls_server_name,omitempty" mapstructure:"tls_server_name"`
+	TLSSkipVerify bool   `json:"tls_skip_verify" mapstructure:"tls_skip_verify"`
+	Cluster       bool   `json:"cluster" mapstructure:"cluster"`
+	EnableMonitor bool   `json:"enable_monitor" mapstructure:"enable_monitor"`
+	EnableTracing bool   `json:"enable_tracing" mapstructure:"enable_tracing"`
+	// TrackingMode is the client-side caching mode of NewTrackingClient,
+	// TrackingDefault when empty, or TrackingBroadcast.
+	TrackingMode string `json:"tracking_mode,omitempty" mapstructure:"tracking_mode"`
 }
 
 // SetDefaults applies default values for unset configuration fields
@@ -55,6 +85,9 @@ func (c *Config) SetDefaults() {
 	if c.IdleConnections == 0 {
 		c.IdleConnections = DefaultIdleConnections
 	}
+	if c.DialTimeout == 0 {
+		c.DialTimeout = DefaultDialTimeout
+	}
 	if c.ReadTimeout == 0 {
 		c.ReadTimeout = DefaultReadTimeout
 	}
@@ -73,6 +106,70 @@ func (c *Config) GetAddresses() []string {
 	return strings.Split(c.Addresses, ",")
 }
 
+// TLSConfig returns the TLS configuration of the connections, nil when TLS is
+// disabled. The CA and the client certificate are read from their files.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if !c.TLS && c.TLSCAFile == "" && c.TLSCertFile == "" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.TLSServerName,
+		InsecureSkipVerify: c.TLSSkipVerify, // nolint: gosec
+	}
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cacheredis: read CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("cacheredis: no certificate found in CA file %s", c.TLSCAFile)
+		}
+	}
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cacheredis: load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// UniversalOptions returns the go-redis options described by the config, for
+// the consumers that build their own client. The defaults are applied first.
+func (c *Config) UniversalOptions() (*redis.UniversalOptions, error) {
+	c.SetDefaults()
+
+	tlsConfig, err := c.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &redis.UniversalOptions{
+		MasterName:       c.MasterName,
+		Addrs:            c.GetAddresses(),
+		ClientName:       c.ClientName,
+		DB:               c.DB,
+		Username:         c.Username,
+		Password:         c.Password,
+		SentinelUsername: c.SentinelUsername,
+		SentinelPassword: c.SentinelPassword,
+		MaxRetries:       c.MaxRetries,
+		DialTimeout:      c.DialTimeout,
+		ReadTimeout:      c.ReadTimeout,
+		WriteTimeout:     c.WriteTimeout,
+		PoolSize:         c.PoolSize,
+		PoolTimeout:      c.PoolTimeout,
+		MinIdleConns:     c.IdleConnections,
+		ConnMaxIdleTime:  c.ConnMaxIdleTime,
+		ConnMaxLifetime:  c.ConnMaxLifetime,
+		TLSConfig:        tlsConfig,
+		Protocol:         3,
+	}, nil
+}
+
 func NewClient(cfg *Config) (redis.UniversalClient, func(), error) {
 	cfg.SetDefaults()
 
@@ -81,44 +178,23 @@ func NewClient(cfg *Config) (redis.UniversalClient, func(), error) {
 		return NewClusterClient(cfg)
 	}
 
-	client := redis.NewUniversalClient(
-		&redis.UniversalOptions{
-			MasterName:      cfg.MasterName,
-			Addrs:           cfg.GetAddresses(),
-			Password:        cfg.Password,
-			PoolSize:        cfg.PoolSize,
-			MinIdleConns:    cfg.IdleConnections,
-			ConnMaxIdleTime: cfg.ConnMaxIdleTime,
-			ReadTimeout:     cfg.ReadTimeout,
-			WriteTimeout:    cfg.WriteTimeout,
-			Protocol:        3,
-		},
-	)
+	opts, err := cfg.UniversalOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	client := redis.NewUniversalClient(opts)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	err := client.Ping(ctx).Err()
+	err = client.Ping(ctx).Err()
 	if err != nil {
 		log.Bg().Error("Ping failed", log.Error(err))
 		return nil, nil, err
 	}
 
-	if cfg.EnableTracing {
-		if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
-			return nil, nil, err
-		}
-	}
-
-	if cfg.EnableMonitor {
-		exp, err := prometheus.New()
-		if err != nil {
-			return nil, nil, err
-		}
-		metricProvider := metric.NewMeterProvider(metric.WithReader(exp))
-		if err := redisotel.InstrumentMetrics(client, redisotel.WithMeterProvider(metricProvider)); err != nil {
-			return nil, nil, err
-		}
+	if err := instrument(client, cfg); err != nil {
+		return nil, nil, err
 	}
 
 	cleanup := func() {
@@ -133,54 +209,55 @@ func NewClient(cfg *Config) (redis.UniversalClient, func(), error) {
 func NewClusterClient(cfg *Config) (redis.UniversalClient, func(), error) {
 	cfg.SetDefaults()
 
-	client := redis.NewClusterClient(
-		&redis.ClusterOptions{
-			Addrs:           cfg.GetAddresses(),
-			Password:        cfg.Password,
-			PoolSize:        cfg.PoolSize,
-			MinIdleConns:    cfg.IdleConnections,
-			ConnMaxIdleTime: cfg.ConnMaxIdleTime,
-			ReadTimeout:     cfg.ReadTimeout,
-			WriteTimeout:    cfg.WriteTimeout,
-			Protocol:        3,
-		},
-	)
+	opts, err := cfg.UniversalOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	client := redis.NewClusterClient(opts.Cluster())
 
 	log.Bg().Info("Connecting to redis cluster", zap.Any("addresses", cfg.GetAddresses()))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	err := client.Ping(ctx).Err()
+	err = client.Ping(ctx).Err()
 	if err != nil {
 		log.Bg().Error("Ping failed", log.Error(err))
 		return nil, nil, err
 	}
 
+	if err := instrument(client, cfg); err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() {
+		err := client.Close()
+		if err != nil {
+			log.Bg().Error("Close redis connection failed", log.Error(err))
+		}
+	}
+
+	log.Bg().Info("Redis client connected")
+	return client, cleanup, nil
+}
+
+// instrument adds the tracing and the metrics enabled by the config.
+func instrument(client redis.UniversalClient, cfg *Config) error {
 	if cfg.EnableTracing {
 		if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
-			return nil, nil, err
+			return err
 		}
 	}
 
 	if cfg.EnableMonitor {
 		exp, err := prometheus.New()
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
 		metricProvider := metric.NewMeterProvider(metric.WithReader(exp))
 		if err := redisotel.InstrumentMetrics(client, redisotel.WithMeterProvider(metricProvider)); err != nil {
-			return nil, nil, err
+			return err
 		}
 	}
-
-	cleanup := func() {
-		err := client.Close()
-		if err != nil {
-			log.Bg().Error("Close redis connection failed", log.Error(err))
-		}
-	}
-
-	log.Bg().Info("Redis client connected")
-	return client, cleanup, nil
+	return nil
 }
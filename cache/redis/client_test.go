@@ -0,0 +1,86 @@
+package cacheredis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig_UniversalOptions(t *testing.T) {
+	cfg := &Config{
+		Addresses:        "sentinel-1:26379,sentinel-2:26379",
+		Username:         "app",
+		Password:         "secret",
+		MasterName:       "mymaster",
+		SentinelPassword: "sentinel-secret",
+		DB:               2,
+		PoolTimeout:      time.Second,
+		MaxRetries:       -1,
+		TLS:              true,
+		TLSServerName:    "redis.internal",
+	}
+	opts, err := cfg.UniversalOptions()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sentinel-1:26379", "sentinel-2:26379"}, opts.Addrs)
+	assert.Equal(t, "app", opts.Username)
+	assert.Equal(t, "sentinel-secret", opts.SentinelPassword)
+	assert.Equal(t, 2, opts.DB)
+	assert.Equal(t, time.Second, opts.PoolTimeout)
+	assert.Equal(t, DefaultDialTimeout, opts.DialTimeout)
+	assert.Equal(t, -1, opts.MaxRetries)
+	assert.Equal(t, "redis.internal", opts.TLSConfig.ServerName)
+
+	// The sentinels are used because of the master name.
+	client := redis.NewUniversalClient(opts)
+	defer client.Close()
+	_, ok := client.(*redis.Client)
+	assert.True(t, ok)
+}
+
+func TestConfig_TLSConfig(t *testing.T) {
+	tlsConfig, err := (&Config{}).TLSConfig()
+	assert.NoError(t, err)
+	assert.Nil(t, tlsConfig)
+
+	_, err = (&Config{TLSCAFile: filepath.Join(t.TempDir(), "missing.pem")}).TLSConfig()
+	assert.ErrorContains(t, err, "read CA file")
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0o600))
+	_, err = (&Config{TLSCAFile: caFile}).TLSConfig()
+	assert.ErrorContains(t, err, "no certificate found")
+
+	_, err = (&Config{TLS: true, TLSCertFile: "missing.crt", TLSKeyFile: "missing.key"}).TLSConfig()
+	assert.ErrorContains(t, err, "load client certificate")
+}
+
+type poolStats redis.PoolStats
+
+func (s *poolStats) PoolStats() *redis.PoolStats {
+	return (*redis.PoolStats)(s)
+}
+
+func TestStatsCollector(t *testing.T) {
+	collector := NewStatsCollector("cache", &poolStats{Hits: 7, TotalConns: 3, IdleConns: 2, WaitDurationNs: int64(1500 * time.Millisecond)})
+
+	assert.Equal(t, 9, testutil.CollectAndCount(collector))
+	expected := `
+# HELP go_redis_stats_connections_blocked_seconds The total time blocked waiting for a connection.
+# TYPE go_redis_stats_connections_blocked_seconds counter
+go_redis_stats_connections_blocked_seconds{redis_name="cache"} 1.5
+# HELP go_redis_stats_connections_hits The total number of times a free connection was found in the pool.
+# TYPE go_redis_stats_connections_hits counter
+go_redis_stats_connections_hits{redis_name="cache"} 7
+# HELP go_redis_stats_connections_total The number of connections in the pool, both in use and idle.
+# TYPE go_redis_stats_connections_total gauge
+go_redis_stats_connections_total{redis_name="cache"} 3
+`
+	assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"go_redis_stats_connections_blocked_seconds", "go_redis_stats_connections_hits", "go_redis_stats_connections_total"))
+}
@@ -0,0 +1,149 @@
+package cacheredis
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the algorithm used to compress the values. A compressed value
+// starts with a header, compressionMagic followed by the algorithm byte, and is
+// decoded by that header whatever compression the store is configured with.
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionSnappy
+	CompressionZstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionSnappy:
+		return "snappy"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("compression(%d)", byte(c))
+	}
+}
+
+// compressionMagic starts the header of a compressed value. Its first byte is
+// never used by MessagePack and can't start UTF-8 text such as JSON, and the
+// whole prefix is unlikely to start any other encoding.
+const compressionMagic = "\xc1gkz"
+
+// compressionHeaderLen is the length of the header of a compressed value.
+const compressionHeaderLen = len(compressionMagic) + 1
+
+// CompressionObserver is called with the size of every value before and after
+// compression, e.g. to report the compression ratio.
+type CompressionObserver func(algorithm Compression, raw, compressed int)
+
+var (
+	gzipWriters = sync.Pool{
+		New: func() any {
+			return gzip.NewWriter(nil)
+		},
+	}
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) {
+		return zstd.NewWriter(nil)
+	})
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil)
+	})
+)
+
+// compress prefixes the compressed data with the header of the algorithm. Data
+// below threshold, or that doesn't shrink, is returned as is.
+func compress(algorithm Compression, threshold int, data []byte) ([]byte, error) {
+	if algorithm == CompressionNone || len(data) < threshold {
+		return data, nil
+	}
+
+	var (
+		compressed []byte
+		err        error
+	)
+	switch algorithm {
+	case CompressionGzip:
+		compressed, err = gzipEncode(data)
+	case CompressionSnappy:
+		dst := make([]byte, compressionHeaderLen+snappy.MaxEncodedLen(len(data)))
+		compressed = dst[:compressionHeaderLen+len(snappy.Encode(dst[compressionHeaderLen:], data))]
+	case CompressionZstd:
+		var encoder *zstd.Encoder
+		if encoder, err = zstdEncoder(); err == nil {
+			compressed = encoder.EncodeAll(data, make([]byte, compressionHeaderLen))
+		}
+	default:
+		return nil, fmt.Errorf("unknown compression %s", algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(compressed) >= len(data) {
+		return data, nil
+	}
+	copy(compressed, compressionMagic)
+	compressed[len(compressionMagic)] = byte(algorithm)
+	return compressed, nil
+}
+
+// decompress reverses compress. Data without a compression header is returned
+// as is.
+func decompress(data []byte) ([]byte, error) {
+	if len(data) < compressionHeaderLen || string(data[:len(compressionMagic)]) != compressionMagic {
+		return data, nil
+	}
+
+	algorithm := Compression(data[len(compressionMagic)])
+	payload := data[compressionHeaderLen:]
+	switch algorithm {
+	case CompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		return io.ReadAll(reader)
+	case CompressionSnappy:
+		return snappy.Decode(nil, payload)
+	case CompressionZstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(payload, nil)
+	default:
+		return nil, fmt.Errorf("unknown compression %s", algorithm)
+	}
+}
+
+func gzipEncode(data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(data)/2))
+	buf.Write(make([]byte, compressionHeaderLen))
+
+	writer, _ := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(writer)
+
+	writer.Reset(buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
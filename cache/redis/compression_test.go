@@ -0,0 +1,78 @@
+package cacheredis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_compress(t *testing.T) {
+	data := []byte(`{"name":"` + strings.Repeat("John Doe ", 100) + `"}`)
+
+	for _, algorithm := range []Compression{CompressionGzip, CompressionSnappy, CompressionZstd} {
+		t.Run(algorithm.String(), func(t *testing.T) {
+			compressed, err := compress(algorithm, 64, data)
+			assert.NoError(t, err)
+			assert.Equal(t, compressionMagic+string(rune(algorithm)), string(compressed[:compressionHeaderLen]))
+			assert.Less(t, len(compressed), len(data))
+
+			decompressed, err := decompress(compressed)
+			assert.NoError(t, err)
+			assert.Equal(t, data, decompressed)
+		})
+	}
+
+	// Below the threshold and legacy values are stored and read as is.
+	small := []byte(`{"name":"John"}`)
+	compressed, err := compress(CompressionZstd, 64, small)
+	assert.NoError(t, err)
+	assert.Equal(t, small, compressed)
+	decompressed, err := decompress(small)
+	assert.NoError(t, err)
+	assert.Equal(t, small, decompressed)
+
+	// Payloads starting with a codec byte, e.g. a MessagePack fixint, aren't
+	// mistaken for compressed values.
+	for _, raw := range [][]byte{{0x01}, {0x02, 0x03}, {0xc1}, []byte(compressionMagic)} {
+		decompressed, err = decompress(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, raw, decompressed)
+	}
+
+	_, err = decompress([]byte(compressionMagic + "\x7fdata"))
+	assert.ErrorContains(t, err, "unknown compression")
+}
+
+func Test_redisCache_Compression(t *testing.T) {
+	var ratio float64
+	store := NewRedisCache[string, *Data](redis.NewClient(&redis.Options{}),
+		WithCompression[string, *Data](CompressionSnappy, 64),
+		WithCompressionObserver[string, *Data](func(algorithm Compression, raw, compressed int) {
+			ratio = float64(compressed) / float64(raw)
+		}),
+	)
+	c, ok := store.(*redisCache[string, *Data])
+	assert.True(t, ok)
+
+	value := &Data{Name: strings.Repeat("John Doe ", 100), Value: 100}
+	data, err := c.marshal(value)
+	assert.NoError(t, err)
+	assert.Less(t, ratio, 0.5)
+
+	var got *Data
+	assert.NoError(t, c.unmarshal(data, &got))
+	assert.Equal(t, value, got)
+
+	// A value written before compression was enabled.
+	assert.NoError(t, c.unmarshal(`{"name":"John Doe","value":100}`, &got))
+	assert.Equal(t, &Data{Name: "John Doe", Value: 100}, got)
+
+	// The compressed value is still read once compression is disabled.
+	plain, ok := NewRedisCache[string, *Data](redis.NewClient(&redis.Options{})).(*redisCache[string, *Data])
+	assert.True(t, ok)
+	got = nil
+	assert.NoError(t, plain.unmarshal(data, &got))
+	assert.Equal(t, value, got)
+}
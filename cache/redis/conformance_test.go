@@ -0,0 +1,25 @@
+package cacheredis
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/cache/cachetest"
+	"github.com/trinhdaiphuc/go-kit/internal/redistest"
+)
+
+func TestRedisCache_Conformance(t *testing.T) {
+	addr := redistest.Addr(t)
+	cachetest.Suite[string, int64]{
+		NewStore: func(t *testing.T, loader cache.Loader[string, int64]) cache.Store[string, int64] {
+			// The cases share the server, each one gets its own keys.
+			client := redis.NewClient(&redis.Options{Addr: addr})
+			return NewRedisCache[string, int64](client, WithPrefix[string, int64](t.Name()), WithLoader[string, int64](loader))
+		},
+		Key:   strconv.Itoa,
+		Value: func(i int) int64 { return int64(i) * 10 },
+	}.Run(t)
+}
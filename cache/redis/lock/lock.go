@@ -2,25 +2,63 @@ package redislock
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/go-redsync/redsync/v4"
 	"github.com/go-redsync/redsync/v4/redis/goredis/v9"
 	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+var (
+	// ErrNotAcquired is returned when the lock is held by someone else after all
+	// the tries. It wraps the redsync error.
+	ErrNotAcquired = errors.New("lock not acquired")
+	// ErrLockLost means the watchdog couldn't extend the lease before it expired:
+	// another holder may have acquired the lock since.
+	ErrLockLost = errors.New("lock lost")
+	// ErrAlreadyLocked is returned when locking a LockMutex that is already
+	// held, or being acquired, through the same LockMutex. Unlock it first.
+	ErrAlreadyLocked = errors.New("lock already held by this mutex")
 )
 
 //go:generate mockgen -destination=./mocks/$GOFILE -source=$GOFILE -package=redislock
 type LockMutex interface {
+	// TryLockContext makes a single attempt to acquire the lock. It fails with
+	// ErrAlreadyLocked while the lock is held through this LockMutex.
 	TryLockContext(ctx context.Context) error
+	// LockContext retries to acquire the lock with the configured backoff until
+	// it succeeds, the tries are exhausted or ctx is done. Only ErrNotAcquired
+	// is retried; any other error is returned right away.
+	LockContext(ctx context.Context) error
 	Unlock() (bool, error)
+	// Token returns the fencing token of the current hold, 0 without
+	// WithFencing or when the lock isn't held. Tokens increase with every
+	// acquisition, so a downstream store can reject writes carrying a token
+	// lower than the last one it has seen.
+	Token() int64
+	// Lost is closed when the watchdog fails to extend the lease in time. It is
+	// never closed without WithWatchdog.
+	Lost() <-chan struct{}
 }
 
 type RedLock interface {
+	// GetLock returns the lock of key, stored at "<prefix>:<key>:<suffix>". An
+	// empty prefix or suffix is left out with its separator.
 	GetLock(key string, expiry time.Duration) LockMutex
+	// WithLock runs fn while holding the lock of key and always releases it.
+	// The context of fn is canceled with ErrLockLost if the lease is lost, and
+	// WithLock then returns ErrLockLost unless fn failed.
+	WithLock(ctx context.Context, key string, expiry time.Duration, fn func(ctx context.Context, token int64) error) error
 }
 
 type redisRedLock struct {
 	opts      *Options
+	client    redis.UniversalClient
 	redisLock *redsync.Redsync
 }
 
@@ -34,14 +72,60 @@ func NewRedLock(redisCli redis.UniversalClient, options ...Option) RedLock {
 	}
 	return &redisRedLock{
 		opts:      opts,
+		client:    redisCli,
 		redisLock: redisLock,
 	}
 }
 
 func (r *redisRedLock) GetLock(key string, expiry time.Duration) LockMutex {
-	return r.redisLock.NewMutex(r.buildKey(key), redsync.WithExpiry(expiry))
+	name := r.buildKey(key)
+	return &mutex{
+		mutex:  r.redisLock.NewMutex(name, redsync.WithExpiry(expiry), redsync.WithTries(1)),
+		client: r.client,
+		opts:   r.opts,
+		name:   name,
+		expiry: expiry,
+	}
 }
 
+func (r *redisRedLock) WithLock(ctx context.Context, key string, expiry time.Duration, fn func(ctx context.Context, token int64) error) error {
+	m := r.GetLock(key, expiry)
+	if err := m.LockContext(ctx); err != nil {
+		return err
+	}
+
+	defer func() {
+		if ok, err := m.Unlock(); !ok || err != nil {
+			log.For(ctx).Error("Unlock failed", zap.String("key", key), zap.Error(err))
+		}
+	}()
+
+	lockCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	go func() {
+		select {
+		case <-m.Lost():
+			cancel(ErrLockLost)
+		case <-lockCtx.Done():
+		}
+	}()
+
+	err := fn(lockCtx, m.Token())
+	if err == nil && errors.Is(context.Cause(lockCtx), ErrLockLost) {
+		return ErrLockLost
+	}
+	return err
+}
+
+// buildKey joins the prefix, key and suffix with ":", skipping the empty parts,
+// so that no key starts or ends with a separator.
 func (r *redisRedLock) buildKey(key string) string {
-	return r.opts.prefix + ":" + key + ":" + r.opts.suffix
+	parts := make([]string, 0, 3)
+	for _, part := range []string{r.opts.prefix, key, r.opts.suffix} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ":")
 }
@@ -0,0 +1,43 @@
+package redislock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_redisRedLock_buildKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		want    string
+	}{
+		{name: "default", want: "key:_lock"},
+		{name: "prefix", options: []Option{WithPrefix("app")}, want: "app:key:_lock"},
+		{name: "suffix", options: []Option{WithPrefix("app"), WithSuffix("lock")}, want: "app:key:lock"},
+		{name: "separators are trimmed", options: []Option{WithPrefix("app:"), WithSuffix(":lock")}, want: "app:key:lock"},
+		{name: "empty suffix", options: []Option{WithPrefix("app"), WithSuffix("")}, want: "app:key"},
+		{name: "separator suffix", options: []Option{WithPrefix("::"), WithSuffix(":")}, want: "key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := newDefaultOption()
+			for _, o := range tt.options {
+				o(opts)
+			}
+			r := &redisRedLock{opts: opts}
+			assert.Equal(t, tt.want, r.buildKey("key"))
+		})
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 100*time.Millisecond)
+
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Millisecond, 3: 40 * time.Millisecond, 10: 100 * time.Millisecond} {
+		delay := backoff(attempt)
+		assert.GreaterOrEqual(t, delay, want/2)
+		assert.LessOrEqual(t, delay, want)
+	}
+}
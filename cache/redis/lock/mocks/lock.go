@@ -42,6 +42,48 @@ func (m *MockLockMutex) EXPECT() *MockLockMutexMockRecorder {
 	return m.recorder
 }
 
+// LockContext mocks base method.
+func (m *MockLockMutex) LockContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockContext indicates an expected call of LockContext.
+func (mr *MockLockMutexMockRecorder) LockContext(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockContext", reflect.TypeOf((*MockLockMutex)(nil).LockContext), ctx)
+}
+
+// Lost mocks base method.
+func (m *MockLockMutex) Lost() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lost")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Lost indicates an expected call of Lost.
+func (mr *MockLockMutexMockRecorder) Lost() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lost", reflect.TypeOf((*MockLockMutex)(nil).Lost))
+}
+
+// Token mocks base method.
+func (m *MockLockMutex) Token() int64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Token")
+	ret0, _ := ret[0].(int64)
+	return ret0
+}
+
+// Token indicates an expected call of Token.
+func (mr *MockLockMutexMockRecorder) Token() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Token", reflect.TypeOf((*MockLockMutex)(nil).Token))
+}
+
 // TryLockContext mocks base method.
 func (m *MockLockMutex) TryLockContext(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -108,3 +150,17 @@ func (mr *MockRedLockMockRecorder) GetLock(key, expiry any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLock", reflect.TypeOf((*MockRedLock)(nil).GetLock), key, expiry)
 }
+
+// WithLock mocks base method.
+func (m *MockRedLock) WithLock(ctx context.Context, key string, expiry time.Duration, fn func(context.Context, int64) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithLock", ctx, key, expiry, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithLock indicates an expected call of WithLock.
+func (mr *MockRedLockMockRecorder) WithLock(ctx, key, expiry, fn any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithLock", reflect.TypeOf((*MockRedLock)(nil).WithLock), ctx, key, expiry, fn)
+}
@@ -0,0 +1,183 @@
+package redislock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redsync/redsync/v4"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// mutex is a redsync mutex with retries, a lease watchdog and fencing tokens.
+// The tokens come from a counter next to the lock key that is incremented after
+// every acquisition. The token is only handed out if the lease is still valid
+// once the counter answered, so the counter was incremented while the lock was
+// held and tokens grow in the order of the holds.
+type mutex struct {
+	mutex  *redsync.Mutex
+	client redis.UniversalClient
+	opts   *Options
+	name   string
+	expiry time.Duration
+
+	mu sync.Mutex
+	// held is set from the start of an acquisition until Unlock.
+	held  bool
+	token int64
+	lost  chan struct{}
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func (m *mutex) TryLockContext(ctx context.Context) error {
+	m.mu.Lock()
+	if m.held {
+		m.mu.Unlock()
+		return ErrAlreadyLocked
+	}
+	m.held = true
+	m.mu.Unlock()
+
+	if err := m.acquire(ctx); err != nil {
+		m.mu.Lock()
+		m.held = false
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// acquire takes the lock and starts the watchdog. The caller has set held.
+func (m *mutex) acquire(ctx context.Context) error {
+	if err := m.mutex.TryLockContext(ctx); err != nil {
+		var taken *redsync.ErrTaken
+		if errors.As(err, &taken) || errors.Is(err, redsync.ErrFailed) {
+			return fmt.Errorf("%w: %w", ErrNotAcquired, err)
+		}
+		return err
+	}
+
+	token, err := m.fence(ctx)
+	if err != nil {
+		if _, errUnlock := m.mutex.Unlock(); errUnlock != nil {
+			log.For(ctx).Error("Unlock failed", zap.String("key", m.name), zap.Error(errUnlock))
+		}
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.token = token
+	m.lost = make(chan struct{})
+	if m.opts.watchdog {
+		m.stop, m.done = make(chan struct{}), make(chan struct{})
+		go m.watchdog(m.lost, m.stop, m.done)
+	}
+	return nil
+}
+
+func (m *mutex) LockContext(ctx context.Context) error {
+	var timer *time.Timer
+	for attempt := 1; ; attempt++ {
+		err := m.TryLockContext(ctx)
+		if !errors.Is(err, ErrNotAcquired) || (m.opts.tries > 0 && attempt >= m.opts.tries) {
+			return err
+		}
+
+		delay := m.opts.backoff(attempt)
+		if timer == nil {
+			timer = time.NewTimer(delay)
+			defer timer.Stop()
+		} else {
+			timer.Reset(delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// Unlock stops the watchdog and releases the lock.
+func (m *mutex) Unlock() (bool, error) {
+	m.mu.Lock()
+	if m.stop != nil {
+		close(m.stop)
+		<-m.done
+		m.stop, m.done = nil, nil
+	}
+	m.token = 0
+	m.held = false
+	m.mu.Unlock()
+
+	return m.mutex.Unlock()
+}
+
+func (m *mutex) Token() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.token
+}
+
+// Lost returns a channel that is closed when the current lease is lost. Before
+// the first acquisition it returns nil, which blocks forever.
+func (m *mutex) Lost() <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lost
+}
+
+func (m *mutex) fence(ctx context.Context) (int64, error) {
+	if !m.opts.fencing {
+		return 0, nil
+	}
+
+	token, err := m.client.Incr(ctx, m.name+":fence").Result()
+	if err != nil {
+		return 0, err
+	}
+	if !time.Now().Before(m.mutex.Until()) {
+		return 0, ErrLockLost
+	}
+	return token, nil
+}
+
+// watchdog extends the lease every third of the expiry. A failed extension is
+// retried on the next tick; the lock is lost once the lease has expired.
+func (m *mutex) watchdog(lost, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(max(m.expiry/3, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ok, err := m.mutex.Extend()
+			if ok {
+				continue
+			}
+			if time.Now().Before(m.mutex.Until()) {
+				log.Bg().Warn("Extend lock failed, retrying", zap.String("key", m.name), zap.Error(err))
+				continue
+			}
+
+			log.Bg().Error("Lock lost", zap.String("key", m.name), zap.Error(err))
+			close(lost)
+			return
+		}
+	}
+}
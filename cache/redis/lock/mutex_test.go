@@ -0,0 +1,158 @@
+package redislock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trinhdaiphuc/go-kit/internal/redistest"
+)
+
+func fixedBackoff(delay time.Duration) BackoffFunc {
+	return func(int) time.Duration { return delay }
+}
+
+func TestMutex_LockContext(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	locks := NewRedLock(client, WithPrefix("test"), WithRetry(0, fixedBackoff(10*time.Millisecond)))
+
+	holder := locks.GetLock("key", time.Minute)
+	require.NoError(t, holder.TryLockContext(ctx))
+	assert.ErrorIs(t, holder.TryLockContext(ctx), ErrAlreadyLocked)
+	assert.ErrorIs(t, holder.LockContext(ctx), ErrAlreadyLocked)
+
+	waiter := locks.GetLock("key", time.Minute)
+	assert.ErrorIs(t, waiter.TryLockContext(ctx), ErrNotAcquired)
+
+	// The tries are bounded by WithRetry, or else by the context.
+	limited := NewRedLock(client, WithPrefix("test"), WithRetry(3, fixedBackoff(time.Millisecond)))
+	assert.ErrorIs(t, limited.GetLock("key", time.Minute).LockContext(ctx), ErrNotAcquired)
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, waiter.LockContext(short), context.DeadlineExceeded)
+
+	// LockContext blocks until the holder releases the lock.
+	released := make(chan struct{})
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		close(released)
+		_, _ = holder.Unlock()
+	}()
+	require.NoError(t, waiter.LockContext(ctx))
+	select {
+	case <-released:
+	default:
+		t.Fatal("acquired the lock before it was released")
+	}
+
+	ok, err := waiter.Unlock()
+	assert.True(t, ok)
+	assert.NoError(t, err)
+	assert.Zero(t, client.Exists(ctx, "test:key:_lock").Val())
+}
+
+func TestMutex_Watchdog(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+
+	t.Run("extends the lease until unlocked", func(t *testing.T) {
+		m := NewRedLock(client, WithPrefix("test"), WithWatchdog()).GetLock("renewed", 300*time.Millisecond)
+		require.NoError(t, m.TryLockContext(ctx))
+
+		time.Sleep(time.Second)
+		assert.Positive(t, client.PTTL(ctx, "test:renewed:_lock").Val())
+		select {
+		case <-m.Lost():
+			t.Fatal("lease lost while the watchdog was running")
+		default:
+		}
+
+		ok, err := m.Unlock()
+		assert.True(t, ok)
+		assert.NoError(t, err)
+
+		// The watchdog is stopped: the key isn't recreated by an extension.
+		time.Sleep(300 * time.Millisecond)
+		assert.Zero(t, client.Exists(ctx, "test:renewed:_lock").Val())
+	})
+
+	t.Run("reports the lost lease", func(t *testing.T) {
+		m := NewRedLock(client, WithPrefix("test"), WithWatchdog()).GetLock("lost", 300*time.Millisecond)
+		require.NoError(t, m.TryLockContext(ctx))
+
+		require.NoError(t, client.Del(ctx, "test:lost:_lock").Err())
+		select {
+		case <-m.Lost():
+		case <-time.After(2 * time.Second):
+			t.Fatal("lost lease not reported")
+		}
+		_, _ = m.Unlock()
+	})
+
+	t.Run("is opt-in", func(t *testing.T) {
+		m := NewRedLock(client, WithPrefix("test")).GetLock("expiring", 200*time.Millisecond)
+		require.NoError(t, m.TryLockContext(ctx))
+
+		assert.Eventually(t, func() bool {
+			return client.Exists(ctx, "test:expiring:_lock").Val() == 0
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+}
+
+func TestMutex_Token(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	locks := NewRedLock(client, WithPrefix("test"), WithFencing())
+
+	var last int64
+	for range 3 {
+		m := locks.GetLock("key", time.Minute)
+		require.NoError(t, m.TryLockContext(ctx))
+		assert.Greater(t, m.Token(), last, "tokens grow with every hold")
+		last = m.Token()
+
+		_, err := m.Unlock()
+		assert.NoError(t, err)
+		assert.Zero(t, m.Token())
+	}
+
+	m := NewRedLock(client, WithPrefix("test")).GetLock("other", time.Minute)
+	require.NoError(t, m.TryLockContext(ctx))
+	assert.Zero(t, m.Token(), "no token without WithFencing")
+}
+
+func TestRedLock_WithLock(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	locks := NewRedLock(client, WithPrefix("test"), WithFencing(), WithWatchdog())
+	boom := errors.New("boom")
+
+	err := locks.WithLock(ctx, "key", time.Minute, func(ctx context.Context, token int64) error {
+		assert.Positive(t, token)
+		assert.Equal(t, int64(1), client.Exists(ctx, "test:key:_lock").Val())
+		return boom
+	})
+	assert.ErrorIs(t, err, boom)
+	assert.Zero(t, client.Exists(ctx, "test:key:_lock").Val(), "released on error")
+
+	assert.PanicsWithValue(t, "boom", func() {
+		_ = locks.WithLock(ctx, "key", time.Minute, func(ctx context.Context, token int64) error {
+			panic("boom")
+		})
+	})
+	assert.Zero(t, client.Exists(ctx, "test:key:_lock").Val(), "released on panic")
+
+	// The context of fn is canceled when the lease is lost.
+	err = locks.WithLock(ctx, "key", 300*time.Millisecond, func(lockCtx context.Context, token int64) error {
+		require.NoError(t, client.Del(ctx, "test:key:_lock").Err())
+		<-lockCtx.Done()
+		assert.ErrorIs(t, context.Cause(lockCtx), ErrLockLost)
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrLockLost)
+}
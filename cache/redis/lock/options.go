@@ -1,27 +1,88 @@
 package redislock
 
+import (
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
 type Options struct {
-	prefix string
-	suffix string
+	prefix   string
+	suffix   string
+	tries    int
+	backoff  BackoffFunc
+	watchdog bool
+	fencing  bool
 }
 
 type Option func(*Options)
 
+// BackoffFunc returns the delay before the next try of LockContext, attempt
+// starting at 1.
+type BackoffFunc func(attempt int) time.Duration
+
+// WithPrefix sets the prefix of the lock keys, see RedLock.GetLock. Leading and
+// trailing ":" are trimmed, the separator is added by the lock.
 func WithPrefix(prefix string) Option {
 	return func(o *Options) {
-		o.prefix = prefix
+		o.prefix = strings.Trim(prefix, ":")
 	}
 }
 
+// WithSuffix sets the suffix of the lock keys, "_lock" by default. Leading and
+// trailing ":" are trimmed.
 func WithSuffix(suffix string) Option {
 	return func(o *Options) {
-		o.suffix = suffix
+		o.suffix = strings.Trim(suffix, ":")
+	}
+}
+
+// WithRetry sets how LockContext retries: at most tries attempts, 0 meaning
+// until the context is done, waiting backoff between them. A nil backoff keeps
+// the default exponential backoff.
+func WithRetry(tries int, backoff BackoffFunc) Option {
+	return func(o *Options) {
+		o.tries = tries
+		if backoff != nil {
+			o.backoff = backoff
+		}
+	}
+}
+
+// WithWatchdog extends the lease of a held lock every third of its expiry until
+// it is released, see LockMutex.Lost. Without it the lock expires after its
+// expiry even if the holder is still running.
+func WithWatchdog() Option {
+	return func(o *Options) {
+		o.watchdog = true
+	}
+}
+
+// WithFencing issues a fencing token on every acquisition, see LockMutex.Token.
+// It costs one more round trip per acquisition.
+func WithFencing() Option {
+	return func(o *Options) {
+		o.fencing = true
+	}
+}
+
+// ExponentialBackoff doubles the delay from base up to maxDelay, with up to 50%
+// random jitter so that waiters don't retry in lockstep.
+func ExponentialBackoff(base, maxDelay time.Duration) BackoffFunc {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < maxDelay; i++ {
+			delay *= 2
+		}
+		delay = min(delay, maxDelay)
+		return delay/2 + time.Duration(rand.Int64N(int64(delay/2)+1)) // nolint: gosec
 	}
 }
 
 func newDefaultOption() *Options {
 	return &Options{
-		prefix: "",
-		suffix: "_lock",
+		prefix:  "",
+		suffix:  "_lock",
+		backoff: ExponentialBackoff(50*time.Millisecond, time.Second),
 	}
 }
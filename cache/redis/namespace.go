@@ -0,0 +1,110 @@
+package cacheredis
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// With WithNamespace every key embeds the value of a counter stored in Redis.
+// BumpNamespace increments it, so all the current keys become unreachable at
+// once and expire on their own. Each store reads the counter when it is created
+// and then every refresh interval, so the other pods switch within an interval.
+
+// namespaceLoadTimeout bounds how long NewRedisCache waits for the namespace
+// counter. Past it the store starts in namespace 0 and switches to the current
+// namespace as soon as the counter is read; until then it retries every
+// namespaceRetryInterval rather than every refresh interval.
+const (
+	namespaceLoadTimeout   = time.Second
+	namespaceRetryInterval = time.Second
+)
+
+// BumpNamespace increments the namespace counter and switches this store to the
+// new namespace right away. It returns the new namespace.
+func (c *redisCache[K, V]) BumpNamespace(ctx context.Context) (int64, error) {
+	if c.opts.NamespaceRefresh <= 0 {
+		return 0, &cache.UnsupportedError{Op: "bump namespace", Reason: "namespace versioning is disabled, see WithNamespace"}
+	}
+
+	namespace, err := c.client.Incr(ctx, c.namespaceKey()).Result()
+	if err != nil {
+		return 0, err
+	}
+	c.namespace.Store(namespace)
+	return namespace, nil
+}
+
+// refreshNamespace reads the namespace counter and reports whether it succeeded.
+func (c *redisCache[K, V]) refreshNamespace(ctx context.Context) bool {
+	namespace, err := c.client.Get(ctx, c.namespaceKey()).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			c.namespace.Store(0)
+			return true
+		}
+		// Keep the current namespace, the next refresh may succeed.
+		log.For(ctx).Error("Refresh cache namespace failed", zap.String("key", c.namespaceKey()), zap.Error(err))
+		return false
+	}
+	c.namespace.Store(namespace)
+	return true
+}
+
+// watchNamespace reads the namespace counter, closes loaded, then refreshes the
+// counter until the store is closed.
+func (c *redisCache[K, V]) watchNamespace(loaded chan<- struct{}) {
+	ok := c.refreshNamespace(context.Background())
+	close(loaded)
+
+	interval := c.opts.NamespaceRefresh
+	if !ok {
+		interval = min(interval, namespaceRetryInterval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if c.refreshNamespace(context.Background()) && interval != c.opts.NamespaceRefresh {
+				interval = c.opts.NamespaceRefresh
+				ticker.Reset(interval)
+			}
+		}
+	}
+}
+
+func (c *redisCache[K, V]) namespaceKey() string {
+	return joinKey(c.opts.Prefix, "namespace")
+}
+
+// keyPrefix is the part of the keys before the encoded key: the prefix, then
+// the schema version and the namespace when they are enabled.
+func (c *redisCache[K, V]) keyPrefix() string {
+	prefix := c.opts.Prefix
+	if c.opts.SchemaVersion != "" {
+		prefix = joinKey(prefix, "v"+c.opts.SchemaVersion)
+	}
+	if c.opts.NamespaceRefresh > 0 {
+		prefix = joinKey(prefix, "n"+strconv.FormatInt(c.namespace.Load(), 10))
+	}
+	return prefix
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + ":" + key
+}
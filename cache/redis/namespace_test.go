@@ -0,0 +1,93 @@
+package cacheredis
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+func Test_redisCache_Namespace(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	ctx := context.Background()
+
+	mock.ExpectGet("test:namespace").SetVal("3")
+	repo := NewRedisCache[string, *Data](client,
+		WithPrefix[string, *Data]("test"),
+		WithSchemaVersion[string, *Data]("2"),
+		WithNamespace[string, *Data](time.Hour),
+		WithTTL[string, *Data](time.Minute),
+	)
+	defer repo.Close()
+
+	mock.ExpectSet("test:v2:n3:key", `{"name":"a","value":1}`, time.Minute).SetVal("OK")
+	assert.NoError(t, repo.Set(ctx, "key", &Data{Name: "a", Value: 1}))
+
+	mock.ExpectIncr("test:namespace").SetVal(4)
+	namespace, err := repo.BumpNamespace(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), namespace)
+
+	mock.ExpectGet("test:v2:n4:key").RedisNil()
+	_, err = repo.Get(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = NewRedisCache[string, *Data](client).BumpNamespace(ctx)
+	assert.True(t, errors.Is(err, errors.ErrUnsupported))
+}
+
+func Test_redisCache_NamespaceUnreachable(t *testing.T) {
+	// A server that accepts connections and never answers.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	start := time.Now()
+	repo := NewRedisCache[string, *Data](redis.NewClient(&redis.Options{Addr: listener.Addr().String(), ReadTimeout: time.Minute}),
+		WithPrefix[string, *Data]("test"),
+		WithNamespace[string, *Data](time.Hour),
+	)
+	assert.Less(t, time.Since(start), 2*namespaceLoadTimeout)
+
+	c, ok := repo.(*redisCache[string, *Data])
+	assert.True(t, ok)
+	assert.Equal(t, "test:n0", c.keyPrefix())
+
+	repo.Close()
+	repo.Close()
+}
+
+func Test_redisCache_UnmarshalErrorIsMiss(t *testing.T) {
+	repo, mock := newRedisClientMock[string, *Data](&loaderSuccess{})
+	ctx := context.Background()
+
+	mock.ExpectGet("test:key").SetVal(`{"name":["old","shape"]}`)
+	value, err := repo.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &Data{Name: "John Doe", Value: 100}, value)
+
+	mock.ExpectHGetAll("test:hash").SetVal(map[string]string{"field1": "not json"})
+	values, err := repo.HGetAll(ctx, "hash")
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,127 @@
+package cacheredis
+
+import (
+	"sync"
+	"time"
+)
+
+type nearEntry[V any] struct {
+	value   V
+	expires time.Time
+	// token is set while the value is read from Redis, see reserve.
+	token uint64
+}
+
+// nearCache is the in-process copy of the values read by Get, evicted by the
+// invalidations of the Tracker. The methods of a nil nearCache do nothing.
+//
+// A value read from Redis may be invalidated before it is cached: Get reserves
+// the key before the read and fills it after, and an invalidation in between
+// drops the reservation, so that the fill is skipped.
+type nearCache[V any] struct {
+	mu      sync.Mutex
+	entries map[string]*nearEntry[V]
+	size    int
+	ttl     time.Duration
+	tokens  uint64
+}
+
+func newNearCache[V any](size int, ttl time.Duration) *nearCache[V] {
+	return &nearCache[V]{
+		entries: make(map[string]*nearEntry[V]),
+		size:    max(size, 1),
+		ttl:     ttl,
+	}
+}
+
+func (n *nearCache[V]) get(key string) (value V, ok bool) {
+	if n == nil {
+		return value, false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	e, ok := n.entries[key]
+	if !ok || e.token != 0 || !time.Now().Before(e.expires) {
+		return value, false
+	}
+	return e.value, true
+}
+
+// reserve marks key as read from Redis, and returns the token to fill it with.
+func (n *nearCache[V]) reserve(key string) uint64 {
+	if n == nil {
+		return 0
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.entries[key]; !ok && len(n.entries) >= n.size {
+		// Any entry will do, the map iteration order is random.
+		for victim := range n.entries {
+			delete(n.entries, victim)
+			break
+		}
+	}
+	n.tokens++
+	n.entries[key] = &nearEntry[V]{token: n.tokens}
+	return n.tokens
+}
+
+// fill caches the value read for the reservation of token, unless it was
+// invalidated since.
+func (n *nearCache[V]) fill(key string, token uint64, value V) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if e, ok := n.entries[key]; ok && e.token == token {
+		n.entries[key] = &nearEntry[V]{value: value, expires: time.Now().Add(n.ttl)}
+	}
+}
+
+// cancel drops the reservation of token.
+func (n *nearCache[V]) cancel(key string, token uint64) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if e, ok := n.entries[key]; ok && e.token == token {
+		delete(n.entries, key)
+	}
+}
+
+func (n *nearCache[V]) evict(keys ...string) {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, key := range keys {
+		delete(n.entries, key)
+	}
+}
+
+func (n *nearCache[V]) clear() {
+	if n == nil {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	clear(n.entries)
+}
+
+// invalidate handles the invalidations of the Tracker, nil keys for all.
+func (n *nearCache[V]) invalidate(keys []string) {
+	if keys == nil {
+		n.clear()
+		return
+	}
+	n.evict(keys...)
+}
@@ -26,15 +26,38 @@ type Options[K comparable, V any] struct {
 	UnmarshalValue Unmarshaler
 	Loader         cache.Loader[K, V]
 	TTL            time.Duration
+	TTLJitter      float64
+	NegativeTTL    time.Duration
+	IsNotFound     cache.NotFoundFunc
+	// Compression of the marshalled values, see WithCompression.
+	Compression          Compression
+	CompressionThreshold int
+	CompressionObserver  CompressionObserver
+	// ChunkSize and BulkConcurrency control how BulkGet, BulkSet and Delete
+	// split their keys, see WithChunkSize.
+	ChunkSize       int
+	BulkConcurrency int
+	// SchemaVersion and NamespaceRefresh are embedded in the keys, see
+	// WithSchemaVersion and WithNamespace.
+	SchemaVersion    string
+	NamespaceRefresh time.Duration
+	// Tracker, NearCacheSize and NearCacheTTL enable the near cache, see
+	// WithNearCache.
+	Tracker       *Tracker
+	NearCacheSize int
+	NearCacheTTL  time.Duration
 }
 
 func newDefaultOption[K comparable, V any]() *Options[K, V] {
 	return &Options[K, V]{
-		KeyEncoder:     defaultKeyEncoder,
-		KeyDecoder:     defaultKeyDecoder,
-		MarshalValue:   json.Marshal,
-		UnmarshalValue: json.Unmarshal,
-		TTL:            5 * time.Minute,
+		KeyEncoder:      defaultKeyEncoder,
+		KeyDecoder:      defaultKeyDecoder,
+		MarshalValue:    json.Marshal,
+		UnmarshalValue:  json.Unmarshal,
+		TTL:             5 * time.Minute,
+		IsNotFound:      cache.IsErrorKeyNotFound,
+		ChunkSize:       500,
+		BulkConcurrency: 8,
 	}
 }
 
@@ -62,6 +85,9 @@ func WithKeyDecoder[K comparable, V any](decoder KeyDecoder) Option[K, V] {
 	}
 }
 
+// WithLoader sets the Loader called on a miss. The store writes the loaded
+// values, with the TTL they are given, only when the Loader implements
+// cache.TTLLoader; other Loaders write them through the store they are handed.
 func WithLoader[K comparable, V any](loader cache.Loader[K, V]) Option[K, V] {
 	return func(o *Options[K, V]) {
 		if loader != nil {
@@ -82,6 +108,98 @@ func WithTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
 	}
 }
 
+// WithTTLJitter randomizes every TTL written by the store by up to ±fraction
+// (e.g. 0.1 for 10%), so keys written together don't all expire together.
+func WithTTLJitter[K comparable, V any](fraction float64) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.TTLJitter = fraction
+	}
+}
+
+// WithNegativeCache enables negative caching: when the Loader fails with an error
+// for which isNotFound returns true, a tombstone is stored for ttl and Get/BulkGet
+// return cache.ErrorKeyNotFound from it without calling the Loader again.
+// A nil isNotFound only matches cache.ErrorKeyNotFound. The keys left out by
+// Loader.BulkLoad count as failed with cache.ErrorKeyNotFound, so they are only
+// tombstoned if isNotFound matches it.
+func WithNegativeCache[K comparable, V any](ttl time.Duration, isNotFound cache.NotFoundFunc) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.NegativeTTL = ttl
+		if isNotFound != nil {
+			o.IsNotFound = isNotFound
+		}
+	}
+}
+
+// WithCompression compresses the marshalled values of at least threshold bytes.
+// Smaller values are stored as is. Values are decoded by their header whatever
+// the store is configured with, so the values written before compression was
+// enabled, or with another algorithm, still decode, and compression can be
+// disabled again at any time.
+func WithCompression[K comparable, V any](algorithm Compression, threshold int) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Compression = algorithm
+		o.CompressionThreshold = threshold
+	}
+}
+
+// WithCompressionObserver reports the size of every compressed value before and
+// after compression, see cacheinstrumented.CompressionObserver.
+func WithCompressionObserver[K comparable, V any](observer CompressionObserver) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.CompressionObserver = observer
+	}
+}
+
+// WithChunkSize sets the maximum number of keys sent in one pipeline by BulkGet,
+// BulkSet and Delete. Larger key lists are split into several pipelines.
+func WithChunkSize[K comparable, V any](size int) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.ChunkSize = size
+	}
+}
+
+// WithBulkConcurrency sets how many pipelines of a bulk operation run in parallel.
+func WithBulkConcurrency[K comparable, V any](concurrency int) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.BulkConcurrency = concurrency
+	}
+}
+
+// WithSchemaVersion embeds version in every key (prefix:v<version>:key). Bump it
+// when the shape of V changes, so that a deploy doesn't read the old entries.
+func WithSchemaVersion[K comparable, V any](version string) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.SchemaVersion = version
+	}
+}
+
+// WithNamespace embeds a namespace counter stored in Redis in every key
+// (prefix:n<namespace>:key), see BumpNamespace. The store reads the counter
+// every refresh interval, which bounds how long the other pods keep using the
+// previous namespace after a bump. NewRedisCache waits up to a second for the
+// counter; past it the store starts in namespace 0 and switches as soon as the
+// counter can be read.
+func WithNamespace[K comparable, V any](refresh time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.NamespaceRefresh = refresh
+	}
+}
+
+// WithNearCache keeps up to size values read by Get in process, for up to ttl,
+// on a client of NewTrackingClient. Redis tracks the keys read by the client,
+// and the tracker evicts the values as soon as Redis invalidates them. The
+// writes of the store evict their keys right away. The other reads always go
+// to Redis. With TrackingBroadcast, the prefix of the store must be under the
+// Config.Prefix of the client, or else the near cache is disabled.
+func WithNearCache[K comparable, V any](tracker *Tracker, size int, ttl time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Tracker = tracker
+		o.NearCacheSize = size
+		o.NearCacheTTL = ttl
+	}
+}
+
 func defaultKeyEncoder(key any) string {
 	return fmt.Sprint(key)
 }
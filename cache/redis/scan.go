@@ -0,0 +1,262 @@
+package cacheredis
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// The iterators walk the keys of the store with SCAN, MATCH restricted to the
+// current key prefix and COUNT set to ChunkSize. On Redis Cluster every master
+// is scanned in turn. SCAN may return a key more than once and a key written
+// during the iteration may be missed. The iterators stop at the first error,
+// which they yield with zero values.
+
+// Scan iterates over the keys of the store matching pattern, a glob applied to
+// the encoded keys ("" for every key). Keys that don't decode into K are
+// skipped.
+func (c *redisCache[K, V]) Scan(ctx context.Context, pattern string) iter.Seq2[K, error] {
+	return func(yield func(K, error) bool) {
+		for page, err := range c.scanKeys(ctx, pattern, "") {
+			if err != nil {
+				var zero K
+				yield(zero, err)
+				return
+			}
+
+			for _, key := range page {
+				k, ok := c.decodeKey(key)
+				if !ok {
+					continue
+				}
+				if !yield(k, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ScanValues iterates over the values of the keys matching pattern, reading
+// them page by page with MGET. Only the keys holding a value are visited, the
+// hashes written by HSet are not. Tombstones are skipped, so are the values
+// that don't unmarshal into V.
+func (c *redisCache[K, V]) ScanValues(ctx context.Context, pattern string) iter.Seq2[cache.KeyVal[K, V], error] {
+	return func(yield func(cache.KeyVal[K, V], error) bool) {
+		for page, err := range c.scanKeys(ctx, pattern, "string") {
+			if err != nil {
+				yield(cache.KeyVal[K, V]{}, err)
+				return
+			}
+
+			values, err := c.mget(ctx, page)
+			if err != nil {
+				yield(cache.KeyVal[K, V]{}, err)
+				return
+			}
+			if !c.yieldValues(ctx, page, values, yield) {
+				return
+			}
+		}
+	}
+}
+
+// HScan iterates over the fields of the hash key matching pattern, a glob
+// applied to the encoded fields ("" for every field). It doesn't call the
+// Loader. Fields that don't decode into K and values that don't unmarshal into
+// V are skipped.
+func (c *redisCache[K, V]) HScan(ctx context.Context, key K, pattern string) iter.Seq2[cache.KeyVal[K, V], error] {
+	return func(yield func(cache.KeyVal[K, V], error) bool) {
+		if pattern == "" {
+			pattern = "*"
+		}
+
+		hashKey := c.encodeKey(key)
+		var cursor uint64
+		for {
+			page, next, err := c.client.HScan(ctx, hashKey, cursor, pattern, int64(c.opts.ChunkSize)).Result()
+			if err != nil {
+				yield(cache.KeyVal[K, V]{}, err)
+				return
+			}
+
+			for i := 0; i+1 < len(page); i += 2 {
+				field, ok := c.opts.KeyDecoder(page[i]).(K)
+				if !ok {
+					continue
+				}
+
+				var value V
+				if err = c.unmarshal(page[i+1], &value); err != nil {
+					log.For(ctx).Warn("Unmarshal error, skipping the field", zap.String("key", hashKey), zap.Error(err))
+					continue
+				}
+				if !yield(cache.KeyVal[K, V]{Key: field, Value: value}, nil) {
+					return
+				}
+			}
+
+			if cursor = next; cursor == 0 {
+				return
+			}
+		}
+	}
+}
+
+// DeleteByPattern unlinks the keys matching pattern page by page and returns
+// how many were deleted. The memory is reclaimed in the background by Redis.
+// Keys written during the call may survive it. An empty pattern, meaning every
+// key of the store, is only accepted when the keys have a prefix, so that it
+// can't wipe a whole database.
+func (c *redisCache[K, V]) DeleteByPattern(ctx context.Context, pattern string) (int64, error) {
+	if pattern == "" && c.keyPrefix() == "" {
+		return 0, errors.New("cacheredis: DeleteByPattern requires a pattern or a key prefix")
+	}
+
+	var deleted atomic.Int64
+	for page, err := range c.scanKeys(ctx, pattern, "") {
+		if err != nil {
+			return deleted.Load(), err
+		}
+
+		err = c.pipelined(ctx, page, func(pipe redis.Pipeliner, indexes []int) func() {
+			cmd := pipe.Unlink(ctx, pick(page, indexes)...)
+			return func() {
+				deleted.Add(cmd.Val())
+			}
+		})
+		if err != nil {
+			return deleted.Load(), err
+		}
+	}
+	return deleted.Load(), nil
+}
+
+// scanKeys yields the pages of keys returned by SCAN on every node, without the
+// tag sets and the namespace counter. keyType restricts the keys to a Redis
+// type, "" for any.
+func (c *redisCache[K, V]) scanKeys(ctx context.Context, pattern, keyType string) iter.Seq2[[]string, error] {
+	return func(yield func([]string, error) bool) {
+		nodes, err := c.nodes(ctx)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+
+		if pattern == "" {
+			pattern = "*"
+		}
+		match := joinKey(escapeGlob(c.keyPrefix()), pattern)
+		count := int64(c.opts.ChunkSize)
+
+		for _, node := range nodes {
+			var cursor uint64
+			for {
+				var cmd *redis.ScanCmd
+				if keyType != "" {
+					cmd = node.ScanType(ctx, cursor, match, count, keyType)
+				} else {
+					cmd = node.Scan(ctx, cursor, match, count)
+				}
+				keys, next, err := cmd.Result()
+				if err != nil {
+					yield(nil, err)
+					return
+				}
+
+				keys = slices.DeleteFunc(keys, c.internalKey)
+				if len(keys) > 0 && !yield(keys, nil) {
+					return
+				}
+
+				if cursor = next; cursor == 0 {
+					break
+				}
+			}
+		}
+	}
+}
+
+// nodes returns the clients to scan: every master of a Redis Cluster, the
+// client itself otherwise.
+func (c *redisCache[K, V]) nodes(ctx context.Context) ([]redis.Cmdable, error) {
+	cluster, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return []redis.Cmdable{c.client}, nil
+	}
+
+	var (
+		mu      sync.Mutex
+		masters []*redis.Client
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		mu.Lock()
+		defer mu.Unlock()
+		masters = append(masters, client)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(masters, func(a, b *redis.Client) int {
+		return strings.Compare(a.Options().Addr, b.Options().Addr)
+	})
+	nodes := make([]redis.Cmdable, 0, len(masters))
+	for _, master := range masters {
+		nodes = append(nodes, master)
+	}
+	return nodes, nil
+}
+
+func (c *redisCache[K, V]) yieldValues(ctx context.Context, keys []string, values []any, yield func(cache.KeyVal[K, V], error) bool) bool {
+	for i, data := range values {
+		s, ok := data.(string)
+		if !ok || s == tombstone {
+			continue
+		}
+		key, ok := c.decodeKey(keys[i])
+		if !ok {
+			continue
+		}
+
+		var value V
+		if err := c.unmarshal(s, &value); err != nil {
+			log.For(ctx).Warn("Unmarshal error, skipping the key", zap.String("key", keys[i]), zap.Error(err))
+			continue
+		}
+		if !yield(cache.KeyVal[K, V]{Key: key, Value: value}, nil) {
+			return false
+		}
+	}
+	return true
+}
+
+// internalKey reports whether key is a tag set or the namespace counter, which
+// live next to the keys when no schema version or namespace is set.
+func (c *redisCache[K, V]) internalKey(key string) bool {
+	return strings.HasPrefix(key, c.encodeTag("")) || key == c.namespaceKey()
+}
+
+// escapeGlob escapes the characters of s that are special in a MATCH pattern.
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
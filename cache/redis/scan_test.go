@@ -0,0 +1,112 @@
+package cacheredis
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+func Test_redisCache_Scan(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("te*st"), WithChunkSize[string, *Data](2))
+	ctx := context.Background()
+
+	mock.ExpectScan(0, `te\*st:user:*`, 2).SetVal([]string{"te*st:user:1", "te*st:__tag__:user"}, 7)
+	mock.ExpectScan(7, `te\*st:user:*`, 2).SetVal([]string{"te*st:user:2"}, 0)
+	var keys []string
+	for key, err := range repo.Scan(ctx, "user:*") {
+		assert.NoError(t, err)
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []string{"user:1", "user:2"}, keys)
+
+	// Only the tag sets are left out, not the keys that look like them.
+	mock.ExpectScan(0, `te\*st:tag:*`, 2).SetVal([]string{"te*st:tag:x", "te*st:__tag__:x"}, 0)
+	keys = nil
+	for key, err := range repo.Scan(ctx, "tag:*") {
+		assert.NoError(t, err)
+		keys = append(keys, key)
+	}
+	assert.Equal(t, []string{"tag:x"}, keys)
+
+	// Breaking out of the loop stops the scan.
+	mock.ExpectScan(0, `te\*st:*`, 2).SetVal([]string{"te*st:a", "te*st:b"}, 3)
+	for key, err := range repo.Scan(ctx, "") {
+		assert.NoError(t, err)
+		assert.Equal(t, "a", key)
+		break
+	}
+
+	mock.ExpectScan(0, `te\*st:*`, 2).SetErr(errors.New("scan failed"))
+	for _, err := range repo.Scan(ctx, "") {
+		assert.EqualError(t, err, "scan failed")
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_redisCache_ScanValues(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"))
+	ctx := context.Background()
+
+	mock.ExpectScanType(0, "test:*", 500, "string").SetVal([]string{"test:a", "test:b", "test:c", "test:d"}, 0)
+	mock.ExpectMGet("test:a", "test:b", "test:c", "test:d").SetVal([]any{`{"name":"a","value":1}`, tombstone, nil, "not json"})
+	var kvs []cache.KeyVal[string, *Data]
+	for kv, err := range repo.ScanValues(ctx, "") {
+		assert.NoError(t, err)
+		kvs = append(kvs, kv)
+	}
+	assert.Equal(t, []cache.KeyVal[string, *Data]{{Key: "a", Value: &Data{Name: "a", Value: 1}}}, kvs)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_redisCache_HScan(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"), WithChunkSize[string, *Data](10))
+	ctx := context.Background()
+
+	mock.ExpectHScan("test:hash", 0, "f*", 10).SetVal([]string{"f1", `{"name":"one","value":1}`, "f2", "not json"}, 5)
+	mock.ExpectHScan("test:hash", 5, "f*", 10).SetVal([]string{"f3", `{"name":"three","value":3}`}, 0)
+	var kvs []cache.KeyVal[string, *Data]
+	for kv, err := range repo.HScan(ctx, "hash", "f*") {
+		assert.NoError(t, err)
+		kvs = append(kvs, kv)
+	}
+	assert.Equal(t, []cache.KeyVal[string, *Data]{
+		{Key: "f1", Value: &Data{Name: "one", Value: 1}},
+		{Key: "f3", Value: &Data{Name: "three", Value: 3}},
+	}, kvs)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_redisCache_DeleteByPattern(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"))
+	ctx := context.Background()
+
+	mock.ExpectScan(0, "test:session:*", 500).SetVal([]string{"test:session:1", "test:session:2"}, 4)
+	mock.ExpectUnlink("test:session:1", "test:session:2").SetVal(2)
+	mock.ExpectScan(4, "test:session:*", 500).SetVal([]string{"test:session:3"}, 0)
+	mock.ExpectUnlink("test:session:3").SetVal(1)
+	deleted, err := repo.DeleteByPattern(ctx, "session:*")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	// Without a prefix, an empty pattern would match every key of the database.
+	_, err = NewRedisCache[string, *Data](client).DeleteByPattern(ctx, "")
+	assert.ErrorContains(t, err, "requires a pattern or a key prefix")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_escapeGlob(t *testing.T) {
+	assert.Equal(t, `a\*b\?c\[d\]e\\f:g`, escapeGlob(`a*b?c[d]e\f:g`))
+}
@@ -0,0 +1,35 @@
+package cacheredis
+
+import (
+	"strings"
+)
+
+// slotCount is the number of hash slots of a Redis Cluster.
+const slotCount = 16384
+
+// slot returns the cluster hash slot of key: CRC16 of the key, or of its hash
+// tag if it has a non-empty one ("{user:1}:profile" hashes "user:1").
+func slot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % slotCount
+}
+
+// crc16 is the CRC16-CCITT (XMODEM) checksum used by Redis Cluster.
+func crc16(key string) uint16 {
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,160 @@
+package cacheredis
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+// StatsGetter is an interface that gets redis.PoolStats.
+// It's implemented by every redis.UniversalClient.
+type StatsGetter interface {
+	PoolStats() *redis.PoolStats
+}
+
+// StatsCollector implements the prometheus.Collector interface.
+type StatsCollector struct {
+	sg StatsGetter
+
+	// descriptions of exported metrics
+	hitsDesc           *prometheus.Desc
+	missesDesc         *prometheus.Desc
+	timeoutsDesc       *prometheus.Desc
+	waitedForDesc      *prometheus.Desc
+	blockedSecondsDesc *prometheus.Desc
+	totalDesc          *prometheus.Desc
+	idleDesc           *prometheus.Desc
+	staleDesc          *prometheus.Desc
+	pendingDesc        *prometheus.Desc
+}
+
+const (
+	statsNamespace = "go_redis_stats"
+	statsSubsystem = "connections"
+)
+
+// NewStatsCollector creates a new StatsCollector of the pool of the client
+// named name, e.g. prometheus.MustRegister(NewStatsCollector("cache", client)).
+func NewStatsCollector(name string, sg StatsGetter) *StatsCollector {
+	labels := prometheus.Labels{"redis_name": name}
+	return &StatsCollector{
+		sg: sg,
+		hitsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "hits"),
+			"The total number of times a free connection was found in the pool.",
+			nil,
+			labels,
+		),
+		missesDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "misses"),
+			"The total number of times a free connection was not found in the pool.",
+			nil,
+			labels,
+		),
+		timeoutsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "timeouts"),
+			"The total number of times waiting for a connection timed out.",
+			nil,
+			labels,
+		),
+		waitedForDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "waited_for"),
+			"The total number of connections waited for.",
+			nil,
+			labels,
+		),
+		blockedSecondsDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "blocked_seconds"),
+			"The total time blocked waiting for a connection.",
+			nil,
+			labels,
+		),
+		totalDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "total"),
+			"The number of connections in the pool, both in use and idle.",
+			nil,
+			labels,
+		),
+		idleDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "idle"),
+			"The number of idle connections in the pool.",
+			nil,
+			labels,
+		),
+		staleDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "stale"),
+			"The total number of stale connections removed from the pool.",
+			nil,
+			labels,
+		),
+		pendingDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(statsNamespace, statsSubsystem, "pending_requests"),
+			"The number of requests waiting for a connection.",
+			nil,
+			labels,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (c StatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hitsDesc
+	ch <- c.missesDesc
+	ch <- c.timeoutsDesc
+	ch <- c.waitedForDesc
+	ch <- c.blockedSecondsDesc
+	ch <- c.totalDesc
+	ch <- c.idleDesc
+	ch <- c.staleDesc
+	ch <- c.pendingDesc
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c StatsCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.sg.PoolStats()
+
+	ch <- prometheus.MustNewConstMetric(
+		c.hitsDesc,
+		prometheus.CounterValue,
+		float64(stats.Hits),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.missesDesc,
+		prometheus.CounterValue,
+		float64(stats.Misses),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.timeoutsDesc,
+		prometheus.CounterValue,
+		float64(stats.Timeouts),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.waitedForDesc,
+		prometheus.CounterValue,
+		float64(stats.WaitCount),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.blockedSecondsDesc,
+		prometheus.CounterValue,
+		float64(stats.WaitDurationNs)/1e9,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.totalDesc,
+		prometheus.GaugeValue,
+		float64(stats.TotalConns),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.idleDesc,
+		prometheus.GaugeValue,
+		float64(stats.IdleConns),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.staleDesc,
+		prometheus.CounterValue,
+		float64(stats.StaleConns),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.pendingDesc,
+		prometheus.GaugeValue,
+		float64(stats.PendingRequests),
+	)
+}
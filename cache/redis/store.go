@@ -3,7 +3,10 @@ package cacheredis
 import (
 	"context"
 	"errors"
+	"iter"
 	"maps"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -13,35 +16,110 @@ import (
 	"github.com/trinhdaiphuc/go-kit/log"
 )
 
+// tombstone is stored in place of a value for keys that don't exist at the source.
+// The leading NUL byte can't start a JSON or protobuf payload.
+const tombstone = "\x00tombstone"
+
+// RedisCache is the cache.Store returned by NewRedisCache, with the operations
+// that only make sense on Redis.
+type RedisCache[K comparable, V any] interface {
+	cache.Store[K, V]
+	cache.HashSetter[K, V]
+	cache.BulkSetter[K, V]
+	cache.TagInvalidator
+	cache.CompareAndSwapper[K, V]
+	// TaggedKeys returns the keys written with one of the tags.
+	TaggedKeys(ctx context.Context, tags ...string) ([]K, error)
+	// BumpNamespace switches every store sharing the prefix to new keys, see WithNamespace.
+	BumpNamespace(ctx context.Context) (int64, error)
+	// Scan iterates over the keys of the store matching a glob pattern.
+	Scan(ctx context.Context, pattern string) iter.Seq2[K, error]
+	// ScanValues iterates over the keys of the store matching a glob pattern and their values.
+	ScanValues(ctx context.Context, pattern string) iter.Seq2[cache.KeyVal[K, V], error]
+	// HScan iterates over the fields of a hash matching a glob pattern.
+	HScan(ctx context.Context, key K, pattern string) iter.Seq2[cache.KeyVal[K, V], error]
+	// DeleteByPattern unlinks the keys of the store matching a glob pattern. An
+	// empty pattern is rejected on a store without a key prefix.
+	DeleteByPattern(ctx context.Context, pattern string) (int64, error)
+}
+
 type redisCache[K comparable, V any] struct {
-	client redis.UniversalClient
-	opts   *Options[K, V]
+	client    redis.UniversalClient
+	opts      *Options[K, V]
+	namespace atomic.Int64
+	done      chan struct{}
+	closeOnce sync.Once
+	near      *nearCache[V]
+	untrack   func()
 }
 
-func NewRedisCache[K comparable, V any](cli redis.UniversalClient, options ...Option[K, V]) cache.Store[K, V] {
+func NewRedisCache[K comparable, V any](cli redis.UniversalClient, options ...Option[K, V]) RedisCache[K, V] {
 	opts := newDefaultOption[K, V]()
 	for _, o := range options {
 		o(opts)
 	}
 
-	return &redisCache[K, V]{
+	c := &redisCache[K, V]{
 		client: cli,
 		opts:   opts,
+		done:   make(chan struct{}),
+	}
+
+	if opts.NamespaceRefresh > 0 {
+		loaded := make(chan struct{})
+		go c.watchNamespace(loaded)
+		// Don't hang the constructor on an unreachable Redis.
+		select {
+		case <-loaded:
+		case <-time.After(namespaceLoadTimeout):
+			log.Bg().Warn("Load cache namespace timed out", zap.String("key", c.namespaceKey()))
+		}
+	}
+
+	if opts.Tracker != nil {
+		if opts.Tracker.covers(opts.Prefix) {
+			c.near = newNearCache[V](opts.NearCacheSize, opts.NearCacheTTL)
+			c.untrack = opts.Tracker.register(c.near.invalidate)
+		} else {
+			// The values would never be invalidated.
+			log.Bg().Error("Near cache disabled, the keys of the store aren't broadcast by the tracker",
+				zap.String("prefix", opts.Prefix), zap.String("tracking_prefix", opts.Tracker.prefix))
+		}
 	}
+
+	return c
 }
 
 func (c *redisCache[K, V]) Get(ctx context.Context, key K) (value V, err error) {
-	data, err := c.client.Get(ctx, c.encodeKey(key)).Result()
+	encoded := c.encodeKey(key)
+	if value, ok := c.near.get(encoded); ok {
+		return value, nil
+	}
+	token := c.near.reserve(encoded)
+
+	data, err := c.client.Get(ctx, encoded).Result()
 	if err != nil {
+		c.near.cancel(encoded, token)
 		if errors.Is(err, redis.Nil) {
 			return c.load(ctx, key)
 		}
 		return value, err
 	}
 
-	err = c.unmarshal(data, &value)
+	if data == tombstone {
+		c.near.cancel(encoded, token)
+		return value, cache.ErrorKeyNotFound
+	}
 
-	return
+	if err = c.unmarshal(data, &value); err != nil {
+		c.near.cancel(encoded, token)
+		// An entry of another shape of V is a miss, the loader overwrites it.
+		log.For(ctx).Warn("Unmarshal error, reloading the key", zap.Error(err))
+		return c.load(ctx, key)
+	}
+
+	c.near.fill(encoded, token, value)
+	return value, nil
 }
 
 func (c *redisCache[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, error) {
@@ -54,31 +132,40 @@ func (c *redisCache[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, erro
 		keyVals = append(keyVals, c.encodeKey(key))
 	}
 
-	result, err := c.client.MGet(ctx, keyVals...).Result()
+	result, err := c.mget(ctx, keyVals)
 	if err != nil {
 		return nil, err
 	}
 
 	rs := make(map[K]V, len(result))
+	tombstones := make(map[K]struct{})
 	for i, data := range result {
 		if data == nil {
 			continue
 		}
 
+		if data == tombstone {
+			tombstones[keys[i]] = struct{}{}
+			continue
+		}
+
+		// Entries that don't unmarshal are loaded again with the missing keys.
 		var value V
 		err = c.unmarshal(data.(string), &value)
 		if err != nil {
-			log.Bg().Error("Unmarshal error", zap.Error(err))
+			log.For(ctx).Warn("Unmarshal error, reloading the key", zap.Error(err))
 			continue
 		}
-		rs[c.decodeHashKey(keyVals[i])] = value
+		rs[keys[i]] = value
 	}
 
-	if len(rs) != len(keys) {
+	if len(rs)+len(tombstones) != len(keys) {
 		// If some keys are not found, we will try to load them
-		missingKeys := make([]K, 0, len(keys)-len(rs))
+		missingKeys := make([]K, 0, len(keys)-len(rs)-len(tombstones))
 		for _, key := range keys {
-			if _, found := rs[key]; !found {
+			_, found := rs[key]
+			_, negative := tombstones[key]
+			if !found && !negative {
 				missingKeys = append(missingKeys, key)
 			}
 		}
@@ -96,20 +183,27 @@ func (c *redisCache[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, erro
 }
 
 func (c *redisCache[K, V]) load(ctx context.Context, key K) (value V, err error) {
-	if c.opts == nil || c.opts.Loader == nil {
+	if c.opts == nil || c.opts.Loader == nil || cache.LoaderSkipped(ctx) {
 		return value, cache.ErrorKeyNotFound
 	}
 
 	value, err = c.opts.Loader.Load(ctx, c, key)
 	if err != nil {
+		if c.isNotFound(err) {
+			c.setTombstones(ctx, key)
+			return value, cache.NotFoundError(err)
+		}
 		return value, err
 	}
 
+	if cache.ChoosesTTL(c.opts.Loader) {
+		c.setLoaded(ctx, map[K]V{key: value})
+	}
 	return value, nil
 }
 
 func (c *redisCache[K, V]) loadAll(ctx context.Context, key K) (map[K]V, error) {
-	if c.opts == nil || c.opts.Loader == nil {
+	if c.opts == nil || c.opts.Loader == nil || cache.LoaderSkipped(ctx) {
 		return nil, cache.ErrorKeyNotFound
 	}
 
@@ -121,56 +215,211 @@ func (c *redisCache[K, V]) loadAll(ctx context.Context, key K) (map[K]V, error)
 	return value, nil
 }
 
+// bulkLoad loads the keys BulkGet missed. Without a Loader they are left out of
+// the result, like the keys the Loader doesn't return.
 func (c *redisCache[K, V]) bulkLoad(ctx context.Context, keys []K) (map[K]V, error) {
-	if c.opts == nil || c.opts.Loader == nil {
-		return nil, cache.ErrorKeyNotFound
+	if c.opts == nil || c.opts.Loader == nil || cache.LoaderSkipped(ctx) {
+		return nil, nil
 	}
 
 	value, err := c.opts.Loader.BulkLoad(ctx, c, keys)
 	if err != nil {
+		if c.isNotFound(err) {
+			c.setTombstones(ctx, keys...)
+			return nil, nil
+		}
 		return value, err
 	}
 
+	if c.isNotFound(cache.ErrorKeyNotFound) {
+		// Keys the loader didn't return failed with cache.ErrorKeyNotFound.
+		notFoundKeys := make([]K, 0, len(keys))
+		for _, key := range keys {
+			if _, ok := value[key]; !ok {
+				notFoundKeys = append(notFoundKeys, key)
+			}
+		}
+		c.setTombstones(ctx, notFoundKeys...)
+	}
+
+	if cache.ChoosesTTL(c.opts.Loader) {
+		c.setLoaded(ctx, value)
+	}
 	return value, nil
 }
 
-func (c *redisCache[K, V]) Set(ctx context.Context, key K, value V) error {
+// expiration resolves the per-call write options into the expiration argument of
+// the Redis command: redis.KeepTTL, 0 for no expiry, or the jittered TTL.
+func (c *redisCache[K, V]) expiration(opts []cache.WriteOption) time.Duration {
+	o := cache.NewWriteOptions(c.opts.TTL, opts...)
+	switch {
+	case o.KeepTTL:
+		return redis.KeepTTL
+	case o.NoExpiry:
+		return 0
+	}
+	return cache.Jitter(o.TTL, c.opts.TTLJitter)
+}
+
+func (c *redisCache[K, V]) isNotFound(err error) bool {
+	return c.opts.NegativeTTL > 0 && c.opts.IsNotFound != nil && c.opts.IsNotFound(err)
+}
+
+// setLoaded writes the values loaded by a cache.TTLLoader, with the TTL it
+// chooses. Failing to do so only costs another Loader call, so errors are
+// logged and not returned.
+func (c *redisCache[K, V]) setLoaded(ctx context.Context, values map[K]V) {
+	keyVals := make([]cache.KeyVal[K, V], 0, len(values))
+	for key, value := range values {
+		if ttl := cache.LoadTTL(c.opts.Loader, key, value); ttl > 0 {
+			if err := c.Set(ctx, key, value, cache.WithTTL(ttl)); err != nil {
+				log.For(ctx).Error("Set loaded value failed", zap.Error(err))
+			}
+			continue
+		}
+		keyVals = append(keyVals, cache.KeyVal[K, V]{Key: key, Value: value})
+	}
+
+	if len(keyVals) == 0 {
+		return
+	}
+	if err := c.BulkSet(ctx, keyVals); err != nil {
+		log.For(ctx).Error("Set loaded values failed", zap.Error(err))
+	}
+}
+
+// setTombstones records that the keys don't exist at the source. A key written
+// since it was loaded keeps its value. Failing to do so only costs another
+// Loader call, so errors are logged and not returned.
+func (c *redisCache[K, V]) setTombstones(ctx context.Context, keys ...K) {
+	if len(keys) == 0 {
+		return
+	}
+
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.SetNX(ctx, c.encodeKey(key), tombstone, c.opts.NegativeTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		log.For(ctx).Error("Set tombstone failed", zap.Error(err))
+	}
+}
+
+func (c *redisCache[K, V]) Set(ctx context.Context, key K, value V, opts ...cache.WriteOption) error {
 	data, err := c.marshal(value)
 	if err != nil {
 		return err
 	}
-	return c.client.Set(ctx, c.encodeKey(key), data, c.opts.TTL).Err()
+	if err = c.tag(ctx, []string{c.encodeKey(key)}, opts); err != nil {
+		return err
+	}
+	defer c.near.evict(c.encodeKey(key))
+	return c.client.Set(ctx, c.encodeKey(key), data, c.expiration(opts)).Err()
 }
 
-func (c *redisCache[K, V]) SetNX(ctx context.Context, key K, value V) (bool, error) {
+func (c *redisCache[K, V]) SetNX(ctx context.Context, key K, value V, opts ...cache.WriteOption) (bool, error) {
 	data, err := c.marshal(value)
 	if err != nil {
 		return false, err
 	}
-	return c.client.SetNX(ctx, c.encodeKey(key), data, c.opts.TTL).Result()
+	if err = c.tag(ctx, []string{c.encodeKey(key)}, opts); err != nil {
+		return false, err
+	}
+	defer c.near.evict(c.encodeKey(key))
+	return c.client.SetNX(ctx, c.encodeKey(key), data, c.expiration(opts)).Result()
+}
+
+// BulkSet writes all values in pipelined SET commands, with the same write
+// options for every key.
+func (c *redisCache[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	if len(keyVals) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(keyVals))
+	values := make([]string, 0, len(keyVals))
+	for _, keyVal := range keyVals {
+		data, err := c.marshal(keyVal.Value)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, c.encodeKey(keyVal.Key))
+		values = append(values, data)
+	}
+	if err := c.tag(ctx, keys, opts); err != nil {
+		return err
+	}
+	defer c.near.evict(keys...)
+
+	return c.pipelined(ctx, keys, func(pipe redis.Pipeliner, indexes []int) func() {
+		for _, i := range indexes {
+			// Each key gets its own jitter.
+			pipe.Set(ctx, keys[i], values[i], c.expiration(opts))
+		}
+		return func() {}
+	})
 }
 
 func (c *redisCache[K, V]) Delete(ctx context.Context, keys ...K) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
 	keyVals := make([]string, 0, len(keys))
 	for _, key := range keys {
 		keyVals = append(keyVals, c.encodeKey(key))
 	}
-	return c.client.Del(ctx, keyVals...).Err()
+	defer c.near.evict(keyVals...)
+
+	return c.pipelined(ctx, keyVals, func(pipe redis.Pipeliner, indexes []int) func() {
+		pipe.Del(ctx, pick(keyVals, indexes)...)
+		return func() {}
+	})
 }
 
 func (c *redisCache[K, V]) Incr(ctx context.Context, key K, value int64) (int64, error) {
+	defer c.near.evict(c.encodeKey(key))
 	return c.client.IncrBy(ctx, c.encodeKey(key), value).Result()
 }
 
+// Expire sets the TTL of a key or a hash. A non-positive TTL deletes it. A
+// missing key is cache.ErrorKeyNotFound where Redis replies 0, see cache.Store.
 func (c *redisCache[K, V]) Expire(ctx context.Context, key K, expireTime time.Duration) error {
-	return c.client.Expire(ctx, c.encodeKey(key), expireTime).Err()
+	defer c.near.evict(c.encodeKey(key))
+	ok, err := c.client.Expire(ctx, c.encodeKey(key), expireTime).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return cache.ErrorKeyNotFound
+	}
+	return nil
 }
 
+// TTL returns the remaining TTL of a key or a hash, or -1 when it has no expiry.
+// A missing key is cache.ErrorKeyNotFound where Redis replies -2, see
+// cache.Store.
 func (c *redisCache[K, V]) TTL(ctx context.Context, key K) (time.Duration, error) {
-	return c.client.TTL(ctx, c.encodeKey(key)).Result()
+	ttl, err := c.client.TTL(ctx, c.encodeKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// TTL replies -2 for a missing key.
+	if ttl == -2 {
+		return 0, cache.ErrorKeyNotFound
+	}
+	return ttl, nil
 }
 
 func (c *redisCache[K, V]) HSet(ctx context.Context, key K, keyVals ...cache.KeyVal[K, V]) error {
+	return c.HSetWithOptions(ctx, key, keyVals)
+}
+
+// HSetWithOptions sets the fields of the hash. The TTL applies to the whole
+// hash; with cache.WithKeepTTL the current TTL of the hash is left untouched.
+func (c *redisCache[K, V]) HSetWithOptions(ctx context.Context, key K, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
 	values := make([]any, 0, len(keyVals)*2)
 	for _, keyVal := range keyVals {
 		data, err := c.marshal(keyVal.Value)
@@ -180,26 +429,44 @@ func (c *redisCache[K, V]) HSet(ctx context.Context, key K, keyVals ...cache.Key
 		values = append(values, c.opts.KeyEncoder(keyVal.Key), data)
 	}
 
-	return c.client.HSet(ctx, c.encodeKey(key), values...).Err()
+	hashKey := c.encodeKey(key)
+	if err := c.tag(ctx, []string{hashKey}, opts); err != nil {
+		return err
+	}
+
+	o := cache.NewWriteOptions(c.opts.TTL, opts...)
+	if o.KeepTTL || (!o.NoExpiry && o.TTL <= 0) {
+		return c.client.HSet(ctx, hashKey, values...).Err()
+	}
+
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, hashKey, values...)
+		if o.NoExpiry {
+			pipe.Persist(ctx, hashKey)
+		} else {
+			pipe.Expire(ctx, hashKey, cache.Jitter(o.TTL, c.opts.TTLJitter))
+		}
+		return nil
+	})
+	return err
 }
 
 func (c *redisCache[K, V]) HGet(ctx context.Context, key, field K) (value V, err error) {
 	data, err := c.client.HGet(ctx, c.encodeKey(key), c.opts.KeyEncoder(field)).Result()
-	if err == nil {
-		err = c.unmarshal(data, &value)
-		return
-	}
-
-	if !errors.Is(err, redis.Nil) {
+	switch {
+	case err == nil:
+		if err = c.unmarshal(data, &value); err == nil {
+			return value, nil
+		}
+		log.For(ctx).Warn("Unmarshal error, reloading the hash", zap.Error(err))
+	case !errors.Is(err, redis.Nil):
 		return value, err
-	}
-
-	// If the hash exists but the field does not exist, we will try to load all fields
-	if c.client.HLen(ctx, c.encodeKey(key)).Val() > 0 {
+	case c.client.HLen(ctx, c.encodeKey(key)).Val() > 0:
+		// The hash exists but the field doesn't.
 		return value, cache.ErrorKeyNotFound
 	}
 
-	allValues, err := c.loadAll(ctx, field)
+	allValues, err := c.loadAll(ctx, key)
 	if err != nil {
 		return value, err
 	}
@@ -227,7 +494,8 @@ func (c *redisCache[K, V]) HGetAll(ctx context.Context, key K) (map[K]V, error)
 		var data V
 		err := c.unmarshal(value, &data)
 		if err != nil {
-			return nil, err
+			log.For(ctx).Warn("Unmarshal error, reloading the hash", zap.Error(err))
+			return c.loadAll(ctx, key)
 		}
 		rs[c.decodeHashKey(keyStr)] = data
 	}
@@ -247,13 +515,21 @@ func (c *redisCache[K, V]) Ping(ctx context.Context) error {
 	return c.client.Ping(ctx).Err()
 }
 
+// Close stops the namespace refresh and closes the client. It is safe to call
+// more than once.
 func (c *redisCache[K, V]) Close() {
-	err := c.client.Close()
-	if err != nil {
-		log.Bg().Error("Error closing redis client", zap.Error(err))
-		return
-	}
-	log.Bg().Info("redis client closed")
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if c.untrack != nil {
+			c.untrack()
+		}
+		err := c.client.Close()
+		if err != nil {
+			log.Bg().Error("Error closing redis client", zap.Error(err))
+			return
+		}
+		log.Bg().Info("redis client closed")
+	})
 }
 
 func (c *redisCache[K, V]) marshal(value V) (string, error) {
@@ -262,20 +538,35 @@ func (c *redisCache[K, V]) marshal(value V) (string, error) {
 		return "", err
 	}
 
-	return string(data), nil
+	if c.opts.Compression == CompressionNone {
+		return string(data), nil
+	}
+
+	compressed, err := compress(c.opts.Compression, c.opts.CompressionThreshold, data)
+	if err != nil {
+		return "", err
+	}
+	if c.opts.CompressionObserver != nil && len(data) >= c.opts.CompressionThreshold {
+		c.opts.CompressionObserver(c.opts.Compression, len(data), len(compressed))
+	}
+
+	return string(compressed), nil
 }
 
 func (c *redisCache[K, V]) unmarshal(data string, value *V) (err error) {
-	err = c.opts.UnmarshalValue([]byte(data), value)
+	// Compressed values are read by their header even with compression
+	// disabled, so that it can be turned off without waiting for them to expire.
+	raw, err := decompress([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	err = c.opts.UnmarshalValue(raw, value)
 	return
 }
 
 func (c *redisCache[K, V]) encodeKey(key K) string {
-	if c.opts.Prefix == "" {
-		return c.opts.KeyEncoder(key)
-	}
-
-	return c.opts.Prefix + ":" + c.opts.KeyEncoder(key)
+	return joinKey(c.keyPrefix(), c.opts.KeyEncoder(key))
 }
 
 func (c *redisCache[K, V]) decodeHashKey(key string) (result K) {
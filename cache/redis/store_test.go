@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/trinhdaiphuc/go-kit/cache"
@@ -94,6 +95,7 @@ func Test_redisCache_Get(t *testing.T) {
 			},
 			mock: func(mock redismock.ClientMock) {
 				mock.ExpectGet("test:key").RedisNil()
+				mock.ExpectSet("test:key", `{"name":"John Doe","value":100}`, 0).SetVal("OK")
 			},
 			want:    value,
 			wantErr: assert.NoError,
@@ -431,6 +433,20 @@ func Test_redisCache_Expire(t *testing.T) {
 			},
 			wantErr: assert.Error,
 		},
+		{
+			name: "Expire missing key",
+			args: args{
+				ctx:        context.Background(),
+				key:        "key",
+				expireTime: 10 * time.Second,
+			},
+			mock: func(mock redismock.ClientMock) {
+				mock.ExpectExpire("test:key", 10*time.Second).SetVal(false)
+			},
+			wantErr: func(t assert.TestingT, err error, i ...any) bool {
+				return assert.ErrorIs(t, err, cache.ErrorKeyNotFound, i...)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -443,6 +459,27 @@ func Test_redisCache_Expire(t *testing.T) {
 	}
 }
 
+func Test_redisCache_TTL(t *testing.T) {
+	repo, mock := newRedisClientMock[string, *Data](nil)
+	ctx := context.Background()
+
+	mock.ExpectTTL("test:key").SetVal(time.Minute)
+	ttl, err := repo.TTL(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Minute, ttl)
+
+	mock.ExpectTTL("test:persistent").SetVal(-1)
+	ttl, err = repo.TTL(ctx, "persistent")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(-1), ttl)
+
+	mock.ExpectTTL("test:missing").SetVal(-2)
+	_, err = repo.TTL(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func Test_redisCache_HSet(t *testing.T) {
 	type args struct {
 		ctx     context.Context
@@ -792,3 +829,189 @@ func Test_redisCache_Close(t *testing.T) {
 		})
 	}
 }
+
+type loaderNotFound struct{}
+
+func (l *loaderNotFound) Load(ctx context.Context, c cache.Store[string, *Data], key string) (*Data, error) {
+	return nil, cache.ErrorKeyNotFound
+}
+
+func (l *loaderNotFound) LoadAll(ctx context.Context, c cache.Store[string, *Data], key string) (map[string]*Data, error) {
+	return nil, cache.ErrorKeyNotFound
+}
+
+func (l *loaderNotFound) BulkLoad(ctx context.Context, c cache.Store[string, *Data], keys []string) (map[string]*Data, error) {
+	return map[string]*Data{}, nil
+}
+
+func Test_redisCache_NegativeCache(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client,
+		WithLoader[string, *Data](&loaderNotFound{}),
+		WithPrefix[string, *Data]("test"),
+		WithNegativeCache[string, *Data](time.Minute, nil),
+	)
+	ctx := context.Background()
+
+	// The first miss calls the loader and records a tombstone.
+	mock.ExpectGet("test:key").RedisNil()
+	mock.ExpectSetNX("test:key", tombstone, time.Minute).SetVal(true)
+	_, err := repo.Get(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+
+	// The tombstone answers without calling the loader.
+	mock.ExpectGet("test:key").SetVal(tombstone)
+	_, err = repo.Get(ctx, "key")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+
+	// BulkGet skips tombstones and records new ones for keys the loader didn't return.
+	mock.ExpectMGet("test:key", "test:other").SetVal([]any{tombstone, nil})
+	mock.ExpectSetNX("test:other", tombstone, time.Minute).SetVal(true)
+	got, err := repo.BulkGet(ctx, []string{"key", "other"})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	// The keys left out by BulkLoad are only tombstoned if the predicate matches
+	// cache.ErrorKeyNotFound.
+	repo = NewRedisCache[string, *Data](client,
+		WithLoader[string, *Data](&loaderNotFound{}),
+		WithPrefix[string, *Data]("test"),
+		WithNegativeCache[string, *Data](time.Minute, func(err error) bool { return false }),
+	)
+	mock.ExpectMGet("test:other").SetVal([]any{nil})
+	got, err = repo.BulkGet(ctx, []string{"other"})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_redisCache_WriteOptions(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client,
+		WithPrefix[string, *Data]("test"),
+		WithTTL[string, *Data](time.Minute),
+	)
+	ctx := context.Background()
+	value := &Data{Name: "foo"}
+
+	mock.ExpectSet("test:key", `{"name":"foo","value":0}`, time.Hour).SetVal("OK")
+	assert.NoError(t, repo.Set(ctx, "key", value, cache.WithTTL(time.Hour)))
+
+	mock.ExpectSet("test:key", `{"name":"foo","value":0}`, redis.KeepTTL).SetVal("OK")
+	assert.NoError(t, repo.Set(ctx, "key", value, cache.WithKeepTTL()))
+
+	mock.ExpectSetNX("test:key", `{"name":"foo","value":0}`, 0).SetVal(true)
+	ok, err := repo.SetNX(ctx, "key", value, cache.WithNoExpiry())
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectTxPipeline()
+	mock.ExpectHSet("test:hash", "field", `{"name":"foo","value":0}`).SetVal(1)
+	mock.ExpectExpire("test:hash", time.Hour).SetVal(true)
+	mock.ExpectTxPipelineExec()
+	assert.NoError(t, cache.HSetWithOptions(ctx, repo, "hash", []cache.KeyVal[string, *Data]{{Key: "field", Value: value}}, cache.WithTTL(time.Hour)))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// ttlLoader loads every key and chooses a short TTL for the "short" key.
+type ttlLoader struct{}
+
+func (l *ttlLoader) Load(ctx context.Context, c cache.Store[string, *Data], key string) (*Data, error) {
+	return &Data{Name: key}, nil
+}
+
+func (l *ttlLoader) LoadAll(ctx context.Context, c cache.Store[string, *Data], key string) (map[string]*Data, error) {
+	return nil, cache.ErrorKeyNotFound
+}
+
+func (l *ttlLoader) BulkLoad(ctx context.Context, c cache.Store[string, *Data], keys []string) (map[string]*Data, error) {
+	values := make(map[string]*Data, len(keys))
+	for _, key := range keys {
+		values[key] = &Data{Name: key}
+	}
+	return values, nil
+}
+
+func (l *ttlLoader) LoadTTL(key string, value *Data) time.Duration {
+	if key == "short" {
+		return 10 * time.Second
+	}
+	return 0
+}
+
+// wrappingLoader decorates a Loader the way the cacheloader wrappers do.
+type wrappingLoader struct {
+	cache.WrappedLoader[string, *Data]
+}
+
+func (l *wrappingLoader) Load(ctx context.Context, c cache.Store[string, *Data], key string) (*Data, error) {
+	return l.Loader.Load(ctx, c, key)
+}
+
+func (l *wrappingLoader) LoadAll(ctx context.Context, c cache.Store[string, *Data], key string) (map[string]*Data, error) {
+	return l.Loader.LoadAll(ctx, c, key)
+}
+
+func (l *wrappingLoader) BulkLoad(ctx context.Context, c cache.Store[string, *Data], keys []string) (map[string]*Data, error) {
+	return l.Loader.BulkLoad(ctx, c, keys)
+}
+
+func Test_redisCache_LoadTTL(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	loader := &wrappingLoader{WrappedLoader: cache.WrappedLoader[string, *Data]{Loader: &ttlLoader{}}}
+	repo := NewRedisCache[string, *Data](client,
+		WithLoader[string, *Data](loader),
+		WithPrefix[string, *Data]("test"),
+		WithTTL[string, *Data](time.Minute),
+	)
+	ctx := context.Background()
+
+	// The loaded values are written with the TTL chosen by the loader, through
+	// the wrapper.
+	mock.ExpectGet("test:short").RedisNil()
+	mock.ExpectSet("test:short", `{"name":"short","value":0}`, 10*time.Second).SetVal("OK")
+	_, err := repo.Get(ctx, "short")
+	assert.NoError(t, err)
+
+	// The store-wide TTL applies when the loader doesn't choose one.
+	mock.ExpectMGet("test:short", "test:long").SetVal([]any{nil, nil})
+	mock.ExpectSet("test:short", `{"name":"short","value":0}`, 10*time.Second).SetVal("OK")
+	mock.ExpectSet("test:long", `{"name":"long","value":0}`, time.Minute).SetVal("OK")
+	values, err := repo.BulkGet(ctx, []string{"short", "long"})
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// writingLoader writes the values it loads itself, without an expiry.
+type writingLoader struct {
+	loaderSuccess
+}
+
+func (l *writingLoader) Load(ctx context.Context, c cache.Store[string, *Data], key string) (*Data, error) {
+	value, _ := l.loaderSuccess.Load(ctx, c, key)
+	return value, c.Set(ctx, key, value, cache.WithNoExpiry())
+}
+
+func Test_redisCache_LoaderWrite(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client,
+		WithLoader[string, *Data](&wrappingLoader{WrappedLoader: cache.WrappedLoader[string, *Data]{Loader: &writingLoader{}}}),
+		WithPrefix[string, *Data]("test"),
+		WithTTL[string, *Data](time.Minute),
+	)
+	ctx := context.Background()
+
+	// The store doesn't write again the value the Loader wrote, nor replace its
+	// options.
+	mock.ExpectGet("test:key").RedisNil()
+	mock.ExpectSet("test:key", `{"name":"John Doe","value":100}`, 0).SetVal("OK")
+	value, err := repo.Get(ctx, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &Data{Name: "John Doe", Value: 100}, value)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,229 @@
+package cacheredis
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+// Each tag is a sorted set of the Redis keys written with it, scored by their
+// expiry in milliseconds (+inf for no expiry). Every write prunes the members
+// that have expired and moves the expiry of the tag set to the one of its
+// longest-lived member, so tag sets go away with their keys.
+
+// tagScript adds members to a tag set.
+// KEYS[1]: tag set, ARGV[1]: TTL of the members in ms (0: no expiry),
+// ARGV[2]: "1" to keep the expiry of the members already tagged, ARGV[3..]: members.
+var tagScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local ttl = tonumber(ARGV[1])
+local score = '+inf'
+if ttl > 0 then
+  score = string.format('%d', now + ttl)
+end
+for i = 3, #ARGV do
+  if ARGV[2] == '1' then
+    redis.call('ZADD', KEYS[1], 'NX', score, ARGV[i])
+  else
+    redis.call('ZADD', KEYS[1], score, ARGV[i])
+  end
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if #last == 0 then
+  return 0
+end
+if last[2] == 'inf' then
+  redis.call('PERSIST', KEYS[1])
+else
+  redis.call('PEXPIREAT', KEYS[1], last[2])
+end
+return 1
+`)
+
+// invalidateScript deletes the members of the tag sets KEYS and the tag sets
+// themselves, and returns the number of deleted members. The members aren't
+// declared in KEYS, which only a standalone Redis, or one behind sentinels,
+// tolerates: the script must never run on a cluster, see InvalidateTags.
+var invalidateScript = redis.NewScript(`
+local deleted = 0
+for _, tag in ipairs(KEYS) do
+  local members = redis.call('ZRANGE', tag, 0, -1)
+  for i = 1, #members, 1000 do
+    deleted = deleted + redis.call('DEL', unpack(members, i, math.min(i + 999, #members)))
+  end
+  redis.call('DEL', tag)
+end
+return deleted
+`)
+
+// tag attaches the tags of the write options to keys. It runs before the write
+// itself: a failed write leaves a dangling member, which only costs a useless
+// DEL, while a key written without its tags would survive an invalidation.
+func (c *redisCache[K, V]) tag(ctx context.Context, keys []string, opts []cache.WriteOption) error {
+	o := cache.NewWriteOptions(c.opts.TTL, opts...)
+	if len(o.Tags) == 0 || len(keys) == 0 {
+		return nil
+	}
+
+	var ttl time.Duration
+	if !o.KeepTTL && !o.NoExpiry && o.TTL > 0 {
+		// The jitter of each key is unknown here, use its upper bound.
+		ttl = o.TTL + time.Duration(float64(o.TTL)*min(max(c.opts.TTLJitter, 0), 1))
+	}
+	keep := "0"
+	if o.KeepTTL {
+		keep = "1"
+	}
+
+	args := make([]any, 0, len(keys)+2)
+	args = append(args, ttl.Milliseconds(), keep)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	for _, tag := range slices.Compact(slices.Sorted(slices.Values(o.Tags))) {
+		if err := tagScript.Run(ctx, c.client, []string{c.encodeTag(tag)}, args...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InvalidateTags deletes every key written with one of the tags. It is atomic on
+// a standalone or sentinel Redis, i.e. with a *redis.Client. With any other
+// client, e.g. on Redis Cluster, the keys of a tag may live on several nodes,
+// so the members are read first, then deleted slot by slot and removed from
+// their tag set: a key tagged in between is kept.
+func (c *redisCache[K, V]) InvalidateTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tagKeys = append(tagKeys, c.encodeTag(tag))
+	}
+
+	// The script touches undeclared keys, only a single node can run it.
+	if _, ok := c.client.(*redis.Client); ok {
+		return invalidateScript.Run(ctx, c.client, tagKeys).Err()
+	}
+
+	members, err := c.tagMembers(ctx, tagKeys)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, m := range members {
+		keys = append(keys, m...)
+	}
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+	if len(keys) > 0 {
+		err = c.pipelined(ctx, keys, func(pipe redis.Pipeliner, indexes []int) func() {
+			pipe.Del(ctx, pick(keys, indexes)...)
+			return func() {}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, tagKey := range tagKeys {
+			if len(members[i]) == 0 {
+				continue
+			}
+			removed := make([]any, 0, len(members[i]))
+			for _, member := range members[i] {
+				removed = append(removed, member)
+			}
+			pipe.ZRem(ctx, tagKey, removed...)
+		}
+		return nil
+	})
+	return err
+}
+
+// TaggedKeys returns the keys written with one of the tags, decoded with the
+// KeyDecoder. Keys that don't decode into K are skipped. cachetiered uses it to
+// evict the L1 copies of the keys it invalidates.
+func (c *redisCache[K, V]) TaggedKeys(ctx context.Context, tags ...string) ([]K, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	tagKeys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tagKeys = append(tagKeys, c.encodeTag(tag))
+	}
+
+	members, err := c.tagMembers(ctx, tagKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []K
+	seen := make(map[string]struct{})
+	for _, m := range members {
+		for _, member := range m {
+			if _, ok := seen[member]; ok {
+				continue
+			}
+			seen[member] = struct{}{}
+
+			if key, ok := c.decodeKey(member); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (c *redisCache[K, V]) tagMembers(ctx context.Context, tagKeys []string) ([][]string, error) {
+	cmds := make([]*redis.StringSliceCmd, len(tagKeys))
+	_, err := c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, tagKey := range tagKeys {
+			cmds[i] = pipe.ZRange(ctx, tagKey, 0, -1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([][]string, len(cmds))
+	for i, cmd := range cmds {
+		members[i] = cmd.Val()
+	}
+	return members, nil
+}
+
+// tagSegment starts the keys of the tag sets. The keys are encoded after the
+// prefix too, so the segment is one a KeyEncoder isn't expected to produce: a
+// key "tag:x" would otherwise be the tag set of "x".
+const tagSegment = "__tag__:"
+
+func (c *redisCache[K, V]) encodeTag(tag string) string {
+	return joinKey(c.opts.Prefix, tagSegment+tag)
+}
+
+func (c *redisCache[K, V]) decodeKey(key string) (result K, ok bool) {
+	if prefix := c.keyPrefix(); prefix != "" {
+		key, ok = strings.CutPrefix(key, prefix+":")
+		if !ok {
+			return result, false
+		}
+	}
+
+	result, ok = c.opts.KeyDecoder(key).(K)
+	return result, ok
+}
@@ -0,0 +1,57 @@
+package cacheredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+func Test_redisCache_Tags(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"), WithTTL[string, *Data](time.Minute))
+	ctx := context.Background()
+
+	// The tags are attached before the value is written.
+	mock.ExpectEvalSha(tagScript.Hash(), []string{"test:__tag__:user:42"}, int64(60000), "0", "test:profile:42").SetVal(int64(1))
+	mock.ExpectSet("test:profile:42", `{"name":"John Doe","value":42}`, time.Minute).SetVal("OK")
+	err := repo.Set(ctx, "profile:42", &Data{Name: "John Doe", Value: 42}, cache.WithTags("user:42"))
+	assert.NoError(t, err)
+
+	mock.ExpectEvalSha(tagScript.Hash(), []string{"test:__tag__:user:42"}, int64(0), "1", "test:orders:42").SetVal(int64(1))
+	mock.ExpectSet("test:orders:42", `{"name":"orders","value":3}`, -1).SetVal("OK")
+	err = repo.Set(ctx, "orders:42", &Data{Name: "orders", Value: 3}, cache.WithTags("user:42"), cache.WithKeepTTL())
+	assert.NoError(t, err)
+
+	mock.ExpectEvalSha(invalidateScript.Hash(), []string{"test:__tag__:user:42", "test:__tag__:user:43"}).SetVal(int64(2))
+	assert.NoError(t, repo.InvalidateTags(ctx, "user:42", "user:43"))
+
+	lister, ok := repo.(interface {
+		TaggedKeys(ctx context.Context, tags ...string) ([]string, error)
+	})
+	assert.True(t, ok)
+	mock.ExpectZRange("test:__tag__:user:42", 0, -1).SetVal([]string{"test:profile:42", "test:orders:42", "other:1"})
+	keys, err := lister.TaggedKeys(ctx, "user:42")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"profile:42", "orders:42"}, keys)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func Test_redisCache_InvalidateTags_Cluster(t *testing.T) {
+	client, mock := redismock.NewClusterMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"))
+	ctx := context.Background()
+
+	// The script isn't run on a cluster: the members are read, then deleted.
+	mock.ExpectZRange("test:__tag__:user:42", 0, -1).SetVal([]string{"test:profile:42"})
+	mock.ExpectDel("test:profile:42").SetVal(1)
+	mock.ExpectZRem("test:__tag__:user:42", "test:profile:42").SetVal(1)
+	assert.NoError(t, cache.InvalidateTags(ctx, repo, "user:42"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
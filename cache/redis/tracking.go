@@ -0,0 +1,265 @@
+package cacheredis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/redis/go-redis/v9/push"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// Client-side caching modes, see NewTrackingClient.
+const (
+	// TrackingDefault makes Redis remember the keys read by the client, and
+	// invalidate them when they change.
+	TrackingDefault = "default"
+	// TrackingBroadcast makes Redis invalidate every key under Config.Prefix,
+	// read or not: it costs Redis no memory, but more invalidations.
+	TrackingBroadcast = "bcast"
+)
+
+// invalidateChannel is the channel of the invalidations sent to RESP2 clients.
+const invalidateChannel = "__redis__:invalidate"
+
+// Tracker receives the invalidations of the keys tracked by Redis for a
+// NewTrackingClient, and evicts them from the near caches of the stores, see
+// WithNearCache.
+//
+// The invalidations arrive on a connection of their own. When it reconnects,
+// the invalidations sent meanwhile are lost and the near caches are cleared. In
+// TrackingDefault mode, the connections of the client opened before keep
+// redirecting to the lost connection: each invalidation of a key they read
+// clears the near caches again, until they are closed.
+type Tracker struct {
+	mode   string
+	prefix string
+
+	redirect atomic.Int64
+	connects atomic.Int64
+
+	mu     sync.RWMutex
+	caches map[int]func(keys []string)
+	nextID int
+
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+func newTracker(mode, prefix string) *Tracker {
+	return &Tracker{
+		mode:   mode,
+		prefix: prefix,
+		caches: make(map[int]func(keys []string)),
+		done:   make(chan struct{}),
+	}
+}
+
+// NewTrackingClient is NewClient with server-assisted client-side caching: the
+// connections enable CLIENT TRACKING, and the returned Tracker evicts the
+// values of the near caches that Redis invalidates. The cluster mode isn't
+// supported.
+func NewTrackingClient(cfg *Config) (redis.UniversalClient, *Tracker, func(), error) {
+	cfg.SetDefaults()
+	if cfg.Cluster {
+		return nil, nil, nil, errors.New("cacheredis: client tracking isn't supported in cluster mode")
+	}
+	mode := cfg.TrackingMode
+	if mode == "" {
+		mode = TrackingDefault
+	}
+	if mode != TrackingDefault && mode != TrackingBroadcast {
+		return nil, nil, nil, fmt.Errorf("cacheredis: unknown tracking mode %q", mode)
+	}
+
+	t := newTracker(mode, cfg.Prefix)
+	processor := redis.NewPushNotificationProcessor()
+	if err := processor.RegisterHandler("invalidate", trackerHandler{t}, true); err != nil {
+		return nil, nil, nil, err
+	}
+	clientOpts, err := cfg.UniversalOptions()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	opts := *clientOpts
+	if t.mode == TrackingDefault {
+		clientProcessor := redis.NewPushNotificationProcessor()
+		if err := clientProcessor.RegisterHandler("tracking-redir-broken", trackerHandler{t}, true); err != nil {
+			return nil, nil, nil, err
+		}
+		clientOpts.OnConnect = t.onConnect
+		clientOpts.PushNotificationProcessor = clientProcessor
+	}
+
+	// The invalidations arrive on a pub/sub connection, which blocks reading.
+	opts.PoolSize = 1
+	opts.MinIdleConns = 0
+	opts.OnConnect = t.onInvalidationConnect
+	opts.PushNotificationProcessor = processor
+	invalidations := redis.NewUniversalClient(&opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	t.pubsub = invalidations.Subscribe(ctx, invalidateChannel)
+	if _, err := t.pubsub.Receive(ctx); err != nil {
+		log.Bg().Error("Subscribe invalidations failed", log.Error(err))
+		_ = t.pubsub.Close()
+		_ = invalidations.Close()
+		return nil, nil, nil, err
+	}
+	go t.run()
+
+	// The connections redirect to the invalidation connection, which is
+	// connected by now.
+	client := redis.NewUniversalClient(clientOpts)
+
+	cleanup := func() {
+		close(t.done)
+		for _, closer := range []interface{ Close() error }{t.pubsub, invalidations, client} {
+			if err := closer.Close(); err != nil {
+				log.Bg().Error("Close redis connection failed", log.Error(err))
+			}
+		}
+	}
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Bg().Error("Ping failed", log.Error(err))
+		cleanup()
+		return nil, nil, nil, err
+	}
+	if err := instrument(client, cfg); err != nil {
+		cleanup()
+		return nil, nil, nil, err
+	}
+	return client, t, cleanup, nil
+}
+
+// covers reports whether Redis invalidates the keys of a store with the key
+// prefix for the tracker. In TrackingBroadcast mode, only the keys under
+// Config.Prefix are.
+func (t *Tracker) covers(prefix string) bool {
+	if t.mode != TrackingBroadcast || t.prefix == "" {
+		return true
+	}
+	return prefix == t.prefix || strings.HasPrefix(prefix, t.prefix+":")
+}
+
+// register adds the invalidate function of a near cache, and returns the
+// function removing it.
+func (t *Tracker) register(invalidate func(keys []string)) func() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	id := t.nextID
+	t.nextID++
+	t.caches[id] = invalidate
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.caches, id)
+	}
+}
+
+// invalidate evicts keys from the near caches, nil keys for all.
+func (t *Tracker) invalidate(keys []string) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, invalidate := range t.caches {
+		invalidate(keys)
+	}
+}
+
+// onInvalidationConnect enables the broadcast on the invalidation connection,
+// or records its id for the other connections to redirect to.
+func (t *Tracker) onInvalidationConnect(ctx context.Context, cn *redis.Conn) error {
+	if t.mode == TrackingBroadcast {
+		args := []any{"CLIENT", "TRACKING", "ON", "BCAST"}
+		if t.prefix != "" {
+			args = append(args, "PREFIX", t.prefix+":")
+		}
+		if err := cn.Do(ctx, args...).Err(); err != nil {
+			return err
+		}
+	} else {
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+		t.redirect.Store(id)
+	}
+
+	if t.connects.Add(1) > 1 {
+		log.For(ctx).Warn("Invalidation connection reconnected, clearing the near caches")
+		t.invalidate(nil)
+	}
+	return nil
+}
+
+// onConnect enables the tracking of the keys read by a connection.
+func (t *Tracker) onConnect(ctx context.Context, cn *redis.Conn) error {
+	return cn.Do(ctx, "CLIENT", "TRACKING", "ON", "REDIRECT", t.redirect.Load()).Err()
+}
+
+// run reads the invalidation connection. RESP3 invalidations are pushed to
+// trackerHandler while reading, RESP2 ones arrive as messages.
+func (t *Tracker) run() {
+	ctx := context.Background()
+	for {
+		msg, err := t.pubsub.ReceiveMessage(ctx)
+		if err != nil {
+			select {
+			case <-t.done:
+				return
+			default:
+			}
+			if errors.Is(err, redis.ErrClosed) {
+				return
+			}
+			log.Bg().Warn("Receive invalidations failed", zap.Error(err))
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		if msg.Channel == invalidateChannel {
+			// A flush invalidates everything, with a null payload.
+			keys := msg.PayloadSlice
+			if keys == nil && msg.Payload != "" {
+				keys = []string{msg.Payload}
+			}
+			t.invalidate(keys)
+		}
+	}
+}
+
+type trackerHandler struct {
+	t *Tracker
+}
+
+func (h trackerHandler) HandlePushNotification(ctx context.Context, _ push.NotificationHandlerContext, notification []any) error {
+	switch notification[0] {
+	case "invalidate":
+		// A flush invalidates everything, with a null list of keys.
+		var keys []string
+		if len(notification) > 1 {
+			if list, ok := notification[1].([]any); ok {
+				keys = make([]string, 0, len(list))
+				for _, key := range list {
+					if key, ok := key.(string); ok {
+						keys = append(keys, key)
+					}
+				}
+			}
+		}
+		h.t.invalidate(keys)
+	case "tracking-redir-broken":
+		h.t.invalidate(nil)
+	}
+	return nil
+}
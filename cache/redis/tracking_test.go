@@ -0,0 +1,224 @@
+package cacheredis
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/redis/go-redis/v9"
+	"github.com/redis/go-redis/v9/push"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trinhdaiphuc/go-kit/internal/redistest"
+)
+
+func Test_redisCache_NearCache(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	tracker := newTracker(TrackingDefault, "test")
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("test"),
+		WithNearCache[string, *Data](tracker, 10, time.Minute))
+	ctx := context.Background()
+	handler := trackerHandler{tracker}
+
+	// The second read is served in process.
+	mock.ExpectGet("test:a").SetVal(`{"name":"a","value":1}`)
+	for range 2 {
+		got, err := repo.Get(ctx, "a")
+		assert.NoError(t, err)
+		assert.Equal(t, &Data{Name: "a", Value: 1}, got)
+	}
+
+	// Until Redis invalidates the key.
+	assert.NoError(t, handler.HandlePushNotification(ctx, push.NotificationHandlerContext{}, []any{"invalidate", []any{"test:a"}}))
+	mock.ExpectGet("test:a").SetVal(`{"name":"a","value":2}`)
+	got, err := repo.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, got.Value)
+
+	// The writes of the store evict their keys.
+	mock.ExpectSet("test:a", `{"name":"a","value":3}`, 5*time.Minute).SetVal("OK")
+	assert.NoError(t, repo.Set(ctx, "a", &Data{Name: "a", Value: 3}))
+	mock.ExpectGet("test:a").SetVal(`{"name":"a","value":3}`)
+	got, err = repo.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, got.Value)
+
+	// A flush invalidates everything.
+	assert.NoError(t, handler.HandlePushNotification(ctx, push.NotificationHandlerContext{}, []any{"invalidate", nil}))
+	mock.ExpectGet("test:a").SetVal(`{"name":"a","value":4}`)
+	got, err = repo.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, got.Value)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	repo.Close()
+	assert.Empty(t, tracker.caches)
+}
+
+func Test_nearCache(t *testing.T) {
+	near := newNearCache[int](2, time.Minute)
+
+	// A value invalidated while it is read isn't cached.
+	token := near.reserve("a")
+	near.invalidate([]string{"a"})
+	near.fill("a", token, 1)
+	_, ok := near.get("a")
+	assert.False(t, ok)
+
+	// Nor is a value read before a newer read.
+	first := near.reserve("a")
+	second := near.reserve("a")
+	near.fill("a", second, 2)
+	near.fill("a", first, 1)
+	value, ok := near.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, value)
+
+	near.fill("b", near.reserve("b"), 3)
+	near.fill("c", near.reserve("c"), 4)
+	assert.Len(t, near.entries, 2)
+
+	var disabled *nearCache[int]
+	disabled.fill("a", disabled.reserve("a"), 1)
+	_, ok = disabled.get("a")
+	assert.False(t, ok)
+}
+
+func TestTracker_covers(t *testing.T) {
+	assert.True(t, newTracker(TrackingDefault, "app").covers("other"), "the read keys are tracked")
+	assert.True(t, newTracker(TrackingBroadcast, "").covers("other"), "every key is broadcast")
+
+	bcast := newTracker(TrackingBroadcast, "app")
+	assert.True(t, bcast.covers("app"))
+	assert.True(t, bcast.covers("app:users"))
+	assert.False(t, bcast.covers("apps"))
+	assert.False(t, bcast.covers(""))
+
+	// The store of uncovered keys has no near cache.
+	client, mock := redismock.NewClientMock()
+	repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("other"),
+		WithNearCache[string, *Data](bcast, 10, time.Minute))
+	for range 2 {
+		mock.ExpectGet("other:a").SetVal(`{"name":"a","value":1}`)
+		_, err := repo.Get(context.Background(), "a")
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.Empty(t, bcast.caches)
+}
+
+func TestConfig_SetDefaults_TrackingMode(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetDefaults()
+	assert.Empty(t, cfg.TrackingMode, "only NewTrackingClient has a tracking mode")
+}
+
+// trackingConfig returns the Config of a throwaway Redis server.
+func trackingConfig(t *testing.T, mode, prefix string) *Config {
+	host, port, err := net.SplitHostPort(redistest.Addr(t))
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return &Config{Host: host, Port: int32(p), TrackingMode: mode, Prefix: prefix}
+}
+
+// watch registers a near cache on the tracker, and returns the invalidations
+// it receives.
+func watch(t *testing.T, tracker *Tracker) <-chan []string {
+	invalidations := make(chan []string, 100)
+	t.Cleanup(tracker.register(func(keys []string) {
+		invalidations <- keys
+	}))
+	return invalidations
+}
+
+func receive(t *testing.T, invalidations <-chan []string) []string {
+	t.Helper()
+	select {
+	case keys := <-invalidations:
+		return keys
+	case <-time.After(5 * time.Second):
+		t.Fatal("no invalidation")
+		return nil
+	}
+}
+
+func TestNewTrackingClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("default", func(t *testing.T) {
+		cfg := trackingConfig(t, "", "")
+		client, tracker, cleanup, err := NewTrackingClient(cfg)
+		require.NoError(t, err)
+		t.Cleanup(cleanup)
+		assert.Equal(t, TrackingDefault, tracker.mode)
+		assert.Empty(t, cfg.TrackingMode, "the config is left as it is")
+		other := redis.NewClient(&redis.Options{Addr: cfg.GetAddresses()[0]})
+		t.Cleanup(func() { _ = other.Close() })
+
+		// The connections of the client redirect their invalidations to the
+		// invalidation connection.
+		redirect := tracker.redirect.Load()
+		require.Positive(t, redirect)
+		info, err := client.Do(ctx, "CLIENT", "TRACKINGINFO").Result()
+		require.NoError(t, err)
+		assert.Equal(t, redirect, info.(map[any]any)["redirect"])
+
+		invalidations := watch(t, tracker)
+		repo := NewRedisCache[string, *Data](client, WithPrefix[string, *Data]("app"),
+			WithNearCache[string, *Data](tracker, 10, time.Minute))
+		require.NoError(t, other.Set(ctx, "app:a", `{"name":"a","value":1}`, 0).Err())
+		got, err := repo.Get(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, 1, got.Value)
+
+		// A write of another client invalidates the key read.
+		require.NoError(t, other.Set(ctx, "app:a", `{"name":"a","value":2}`, 0).Err())
+		assert.Equal(t, []string{"app:a"}, receive(t, invalidations))
+		got, err = repo.Get(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, 2, got.Value)
+
+		// The invalidations of RESP2 arrive as messages of the channel.
+		require.NoError(t, other.Publish(ctx, invalidateChannel, "app:b").Err())
+		assert.Equal(t, []string{"app:b"}, receive(t, invalidations))
+
+		// A reconnected invalidation connection clears the near caches, and the
+		// new connections redirect to it.
+		require.NoError(t, other.ClientKillByFilter(ctx, "ID", strconv.FormatInt(redirect, 10)).Err())
+		assert.Nil(t, receive(t, invalidations))
+		assert.Eventually(t, func() bool {
+			return tracker.redirect.Load() != redirect
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("broadcast", func(t *testing.T) {
+		cfg := trackingConfig(t, TrackingBroadcast, "app")
+		_, tracker, cleanup, err := NewTrackingClient(cfg)
+		require.NoError(t, err)
+		t.Cleanup(cleanup)
+		other := redis.NewClient(&redis.Options{Addr: cfg.GetAddresses()[0]})
+		t.Cleanup(func() { _ = other.Close() })
+		invalidations := watch(t, tracker)
+
+		// The keys under the prefix are invalidated without being read.
+		require.NoError(t, other.Set(ctx, "other:a", "1", 0).Err())
+		require.NoError(t, other.Set(ctx, "app:a", "1", 0).Err())
+		assert.Equal(t, []string{"app:a"}, receive(t, invalidations))
+
+		// A flush invalidates everything.
+		require.NoError(t, other.FlushDB(ctx).Err())
+		assert.Nil(t, receive(t, invalidations))
+	})
+
+	t.Run("rejects the cluster mode and unknown modes", func(t *testing.T) {
+		_, _, _, err := NewTrackingClient(&Config{Cluster: true})
+		assert.Error(t, err)
+		_, _, _, err = NewTrackingClient(&Config{TrackingMode: "optin"})
+		assert.ErrorContains(t, err, "unknown tracking mode")
+	})
+}
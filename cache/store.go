@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
@@ -14,12 +15,17 @@ type KeyVal[K comparable, V any] struct {
 //go:generate mockgen -destination=./mocks/$GOFILE -source=$GOFILE -package=cachemock
 type Store[K comparable, V any] interface {
 	Get(ctx context.Context, key K) (V, error)
-	Set(ctx context.Context, key K, value V) error
-	SetNX(ctx context.Context, key K, value V) (bool, error)
+	Set(ctx context.Context, key K, value V, opts ...WriteOption) error
+	SetNX(ctx context.Context, key K, value V, opts ...WriteOption) (bool, error)
 	BulkGet(ctx context.Context, keys []K) (map[K]V, error)
 	Delete(ctx context.Context, keys ...K) error
 	Incr(ctx context.Context, key K, value int64) (int64, error)
+	// Expire sets the TTL of a key or a hash, a non-positive TTL deletes it. It
+	// returns ErrorKeyNotFound for a missing key.
 	Expire(ctx context.Context, key K, expireTime time.Duration) error
+	// TTL returns the remaining TTL of a key or a hash, -1 when it has no
+	// expiry. It returns ErrorKeyNotFound for a missing key, not the -2 of
+	// Redis.
 	TTL(ctx context.Context, key K) (time.Duration, error)
 	HSet(ctx context.Context, key K, keyVals ...KeyVal[K, V]) error
 	HGet(ctx context.Context, key, field K) (V, error)
@@ -29,6 +35,54 @@ type Store[K comparable, V any] interface {
 	Close()
 }
 
+// BulkSetter is an optional interface of a Store that writes many values at
+// once, e.g. in a single pipeline.
+type BulkSetter[K comparable, V any] interface {
+	BulkSet(ctx context.Context, keyVals []KeyVal[K, V], opts ...WriteOption) error
+}
+
+// BulkSet writes the values through BulkSetter when store implements it, and
+// with one Store.Set per value otherwise, stopping at the first error.
+func BulkSet[K comparable, V any](ctx context.Context, store Store[K, V], keyVals []KeyVal[K, V], opts ...WriteOption) error {
+	if s, ok := store.(BulkSetter[K, V]); ok {
+		return s.BulkSet(ctx, keyVals, opts...)
+	}
+	for _, keyVal := range keyVals {
+		if err := store.Set(ctx, keyVal.Key, keyVal.Value, opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CompareAndSwapper is an optional interface of a Store that writes or deletes
+// a key only while it still holds an expected value, in one atomic step.
+type CompareAndSwapper[K comparable, V any] interface {
+	// CompareAndSet writes value when key holds old, and reports whether it did.
+	CompareAndSet(ctx context.Context, key K, old, value V, opts ...WriteOption) (bool, error)
+	// CompareAndDelete deletes key when it holds old, and reports whether it did.
+	CompareAndDelete(ctx context.Context, key K, old V) (bool, error)
+}
+
+// CompareAndSet writes value when key holds old, through CompareAndSwapper. A
+// store that doesn't implement it fails with an UnsupportedError: a Get then a
+// Set isn't atomic, the caller decides whether that will do.
+func CompareAndSet[K comparable, V any](ctx context.Context, store Store[K, V], key K, old, value V, opts ...WriteOption) (bool, error) {
+	if s, ok := store.(CompareAndSwapper[K, V]); ok {
+		return s.CompareAndSet(ctx, key, old, value, opts...)
+	}
+	return false, &UnsupportedError{Op: "compare and set", Reason: "the store doesn't implement cache.CompareAndSwapper"}
+}
+
+// CompareAndDelete deletes key when it holds old, through CompareAndSwapper. A
+// store that doesn't implement it fails with an UnsupportedError.
+func CompareAndDelete[K comparable, V any](ctx context.Context, store Store[K, V], key K, old V) (bool, error) {
+	if s, ok := store.(CompareAndSwapper[K, V]); ok {
+		return s.CompareAndDelete(ctx, key, old)
+	}
+	return false, &UnsupportedError{Op: "compare and delete", Reason: "the store doesn't implement cache.CompareAndSwapper"}
+}
+
 // Loader is an interface that handles missing data loading.
 type Loader[K comparable, V any] interface {
 	// Load should execute a custom item retrieval logic and
@@ -48,15 +102,33 @@ type Loader[K comparable, V any] interface {
 	// BulkLoad computes or retrieves the values corresponding to keys.
 	// This method is called by Cache.BulkGet.
 	//
-	// If the returned map doesn't contain all requested keys, then the entries it does
-	// contain will be cached, and Cache.BulkGet will return the partial results. If the returned map
-	// contains extra keys not present in keys then all returned entries will be cached, but
-	// only the entries for keys, will be returned from Cache.BulkGet.
+	// If the returned map doesn't contain all requested keys, then Cache.BulkGet
+	// will return the partial results. If the returned map contains extra keys
+	// not present in keys, only the entries for keys will be returned from
+	// Cache.BulkGet.
 	//
-	// WARNING: loading must not attempt to update any mappings of this cache directly.
+	// Like Load, the method is allowed to write the values it loads into the
+	// cache instance, with the write options it chooses, and the store keeps
+	// that write. The values of a TTLLoader are written by the store instead,
+	// see TTLLoader.
 	BulkLoad(ctx context.Context, c Store[K, V], keys []K) (map[K]V, error)
 }
 
+type withoutLoaderKey struct{}
+
+// WithoutLoader returns a context whose reads don't call the Loader of the
+// store: a missing key fails with ErrorKeyNotFound. Loaders use it to read the
+// store they load for, e.g. once they hold a lock, without loading again.
+func WithoutLoader(ctx context.Context) context.Context {
+	return context.WithValue(ctx, withoutLoaderKey{}, struct{}{})
+}
+
+// LoaderSkipped reports whether ctx comes from WithoutLoader. Stores check it
+// before calling their Loader.
+func LoaderSkipped(ctx context.Context) bool {
+	return ctx.Value(withoutLoaderKey{}) != nil
+}
+
 // LoaderFunc type is an adapter that allows the use of ordinary
 // functions as data loaders.
 type LoaderFunc[K comparable, V any] func(ctx context.Context, c Store[K, V], key K) (value V, err error)
@@ -92,7 +164,41 @@ func (l BulkLoaderFunc[K, V]) BulkLoad(ctx context.Context, c Store[K, V], keys
 	return l(ctx, c, keys)
 }
 
+// NotFoundFunc reports whether an error returned by a Loader means that the key
+// doesn't exist at the source (e.g. gorm.ErrRecordNotFound), so that a tombstone
+// can be cached for it.
+type NotFoundFunc func(err error) bool
+
 var (
 	ErrorKeyNotFound    = errors.New("key not found")
 	ErrorFailedSetCache = errors.New("failed to set cache")
 )
+
+// IsErrorKeyNotFound is the default NotFoundFunc.
+func IsErrorKeyNotFound(err error) bool {
+	return errors.Is(err, ErrorKeyNotFound)
+}
+
+// NotFoundError wraps a Loader error that was classified as "not found" so that
+// it matches both ErrorKeyNotFound and the original error.
+func NotFoundError(err error) error {
+	if err == nil || errors.Is(err, ErrorKeyNotFound) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrorKeyNotFound, err)
+}
+
+// UnsupportedError is returned by a Store for an operation it can't perform,
+// e.g. Incr on non-integer values. It matches errors.ErrUnsupported.
+type UnsupportedError struct {
+	Op     string
+	Reason string
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s is not supported: %s", e.Op, e.Reason)
+}
+
+func (e *UnsupportedError) Unwrap() error {
+	return errors.ErrUnsupported
+}
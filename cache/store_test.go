@@ -0,0 +1,43 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachemock "github.com/trinhdaiphuc/go-kit/cache/mocks"
+)
+
+func TestBulkSet(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	keyVals := []cache.KeyVal[string, int]{{Key: "a", Value: 1}, {Key: "b", Value: 2}, {Key: "c", Value: 3}}
+
+	// A store without BulkSet gets one Set per value, up to the first error.
+	store := cachemock.NewMockStore[string, int](ctrl)
+	gomock.InOrder(
+		store.EXPECT().Set(ctx, "a", 1, gomock.Any()).Return(nil),
+		store.EXPECT().Set(ctx, "b", 2, gomock.Any()).Return(errors.New("boom")),
+	)
+	assert.EqualError(t, cache.BulkSet[string, int](ctx, store, keyVals, cache.WithTTL(0)), "boom")
+
+	bulk := struct {
+		*cachemock.MockStore[string, int]
+		*cachemock.MockBulkSetter[string, int]
+	}{cachemock.NewMockStore[string, int](ctrl), cachemock.NewMockBulkSetter[string, int](ctrl)}
+	bulk.MockBulkSetter.EXPECT().BulkSet(ctx, keyVals).Return(nil)
+	assert.NoError(t, cache.BulkSet[string, int](ctx, bulk, keyVals))
+}
+
+func TestInvalidateTags(t *testing.T) {
+	store := cachemock.NewMockStore[string, int](gomock.NewController(t))
+
+	var unsupported *cache.UnsupportedError
+	err := cache.InvalidateTags[string, int](context.Background(), store, "a")
+	assert.ErrorAs(t, err, &unsupported)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
@@ -0,0 +1,89 @@
+package cachetiered
+
+import (
+	"context"
+
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// invalidation is the message broadcast on the invalidation channel. Keys are
+// JSON encoded so they can be decoded back into K without a KeyDecoder.
+type invalidation[K comparable] struct {
+	Source string `json:"source"`
+	Keys   []K    `json:"keys"`
+}
+
+// invalidator publishes the keys mutated by this replica and evicts the keys
+// mutated by the other replicas from the local tier.
+type invalidator[K comparable] struct {
+	client  redis.UniversalClient
+	pubsub  *redis.PubSub
+	channel string
+	source  string
+	evict   func(keys ...K)
+	done    chan struct{}
+}
+
+func newInvalidator[K comparable](cli redis.UniversalClient, channel string, evict func(keys ...K)) *invalidator[K] {
+	i := &invalidator[K]{
+		client:  cli,
+		pubsub:  cli.Subscribe(context.Background(), channel),
+		channel: channel,
+		source:  uuid.New().String(),
+		evict:   evict,
+		done:    make(chan struct{}),
+	}
+
+	go i.listen()
+
+	return i
+}
+
+// publish broadcasts the mutated keys. A failure is only logged: the write has
+// already succeeded and the other replicas converge once their L1 entry expires.
+func (i *invalidator[K]) publish(ctx context.Context, keys ...K) {
+	if len(keys) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(invalidation[K]{Source: i.source, Keys: keys})
+	if err != nil {
+		log.For(ctx).Error("Marshal invalidation failed", zap.Error(err))
+		return
+	}
+
+	if err = i.client.Publish(ctx, i.channel, payload).Err(); err != nil {
+		log.For(ctx).Error("Publish invalidation failed", zap.String("channel", i.channel), zap.Error(err))
+	}
+}
+
+func (i *invalidator[K]) listen() {
+	defer close(i.done)
+
+	// The channel is closed when the PubSub is closed.
+	for msg := range i.pubsub.Channel() {
+		var message invalidation[K]
+		if err := json.Unmarshal([]byte(msg.Payload), &message); err != nil {
+			log.Bg().Error("Unmarshal invalidation failed", zap.String("channel", msg.Channel), zap.Error(err))
+			continue
+		}
+
+		if message.Source == i.source {
+			continue
+		}
+
+		i.evict(message.Keys...)
+	}
+}
+
+func (i *invalidator[K]) close() {
+	if err := i.pubsub.Close(); err != nil {
+		log.Bg().Error("Close invalidation subscription failed", zap.Error(err))
+	}
+	<-i.done
+}
@@ -0,0 +1,28 @@
+package cachetiered
+
+import (
+	"context"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+// remoteLoader loads the misses of the Redis tier with the Loader of the tiered
+// store, so that the Loader is handed the tiered store. The Redis tier writes
+// the values loaded by a cache.TTLLoader, and the tiered store copies them into
+// L1; other Loaders write through the tiered store.
+type remoteLoader[K comparable, V any] struct {
+	cache.WrappedLoader[K, V]
+	c *tieredCache[K, V]
+}
+
+func (l *remoteLoader[K, V]) Load(ctx context.Context, _ cache.Store[K, V], key K) (V, error) {
+	return l.Loader.Load(ctx, l.c, key)
+}
+
+func (l *remoteLoader[K, V]) LoadAll(ctx context.Context, _ cache.Store[K, V], key K) (map[K]V, error) {
+	return l.Loader.LoadAll(ctx, l.c, key)
+}
+
+func (l *remoteLoader[K, V]) BulkLoad(ctx context.Context, _ cache.Store[K, V], keys []K) (map[K]V, error) {
+	return l.Loader.BulkLoad(ctx, l.c, keys)
+}
@@ -0,0 +1,92 @@
+package cachetiered
+
+import (
+	"time"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
+	cacheredis "github.com/trinhdaiphuc/go-kit/cache/redis"
+)
+
+type Option[K comparable, V any] func(*Options[K, V])
+
+type Options[K comparable, V any] struct {
+	Prefix       string
+	Channel      string
+	Loader       cache.Loader[K, V]
+	LocalTTL     time.Duration
+	RedisTTL     time.Duration
+	LocalOptions []cachelocal.Option[K, V]
+	RedisOptions []cacheredis.Option[K, V]
+}
+
+func newDefaultOption[K comparable, V any]() *Options[K, V] {
+	return &Options[K, V]{
+		LocalTTL: time.Minute,
+		RedisTTL: 5 * time.Minute,
+	}
+}
+
+func WithLoader[K comparable, V any](loader cache.Loader[K, V]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		if loader != nil {
+			o.Loader = loader
+		}
+	}
+}
+
+// WithPrefix sets the prefix of the Redis keys. It is also used to build the
+// default invalidation channel.
+func WithPrefix[K comparable, V any](prefix string) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Prefix = prefix
+	}
+}
+
+// WithChannel sets the pub/sub channel used to broadcast invalidations to the
+// other replicas. Defaults to "<prefix>:invalidate".
+func WithChannel[K comparable, V any](channel string) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.Channel = channel
+	}
+}
+
+// WithLocalTTL sets the TTL of the in-process (L1) tier. Keep it short: it bounds
+// how long a replica can serve a stale value if an invalidation message is lost.
+func WithLocalTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.LocalTTL = ttl
+	}
+}
+
+// WithRedisTTL sets the TTL of the Redis (L2) tier.
+func WithRedisTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.RedisTTL = ttl
+	}
+}
+
+// WithLocalOptions passes extra options to the underlying cachelocal client.
+func WithLocalOptions[K comparable, V any](opts ...cachelocal.Option[K, V]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.LocalOptions = append(o.LocalOptions, opts...)
+	}
+}
+
+// WithRedisOptions passes extra options to the underlying cacheredis store,
+// e.g. a custom marshaller or key encoder.
+func WithRedisOptions[K comparable, V any](opts ...cacheredis.Option[K, V]) Option[K, V] {
+	return func(o *Options[K, V]) {
+		o.RedisOptions = append(o.RedisOptions, opts...)
+	}
+}
+
+func (o *Options[K, V]) channel() string {
+	if o.Channel != "" {
+		return o.Channel
+	}
+	if o.Prefix == "" {
+		return "cache:invalidate"
+	}
+	return o.Prefix + ":invalidate"
+}
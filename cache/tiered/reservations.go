@@ -0,0 +1,61 @@
+package cachetiered
+
+import "sync"
+
+// reservations guards the L1 fills of the values read from Redis. A value read
+// from Redis may be invalidated before it is copied into L1: the read reserves
+// the key before and fills it after, and an invalidation or a write in between
+// drops the reservation, so that the stale value isn't put back into L1.
+type reservations[K comparable] struct {
+	mu     sync.Mutex
+	tokens map[K]uint64
+	next   uint64
+}
+
+func newReservations[K comparable]() *reservations[K] {
+	return &reservations[K]{tokens: make(map[K]uint64)}
+}
+
+// reserve marks key as read from Redis, and returns the token to fill it with.
+func (r *reservations[K]) reserve(key K) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.next++
+	r.tokens[key] = r.next
+	return r.next
+}
+
+// fill runs set if the reservation of token still holds, and drops it.
+func (r *reservations[K]) fill(key K, token uint64, set func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tokens[key] != token {
+		return
+	}
+	delete(r.tokens, key)
+	set()
+}
+
+// cancel drops the reservation of token.
+func (r *reservations[K]) cancel(key K, token uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tokens[key] == token {
+		delete(r.tokens, key)
+	}
+}
+
+// evict drops the reservations of the keys and runs update, which changes their
+// L1 copies, before any pending fill can.
+func (r *reservations[K]) evict(keys []K, update func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		delete(r.tokens, key)
+	}
+	update()
+}
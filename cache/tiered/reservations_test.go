@@ -0,0 +1,41 @@
+package cachetiered
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservations(t *testing.T) {
+	r := newReservations[string]()
+	filled := 0
+	fill := func() { filled++ }
+
+	token := r.reserve("a")
+	r.fill("a", token, fill)
+	assert.Equal(t, 1, filled)
+
+	// A reservation is only filled once.
+	r.fill("a", token, fill)
+	assert.Equal(t, 1, filled)
+
+	// An invalidation between the read and the fill drops the stale value.
+	token = r.reserve("a")
+	evicted := false
+	r.evict([]string{"a"}, func() { evicted = true })
+	assert.True(t, evicted)
+	r.fill("a", token, fill)
+	assert.Equal(t, 1, filled)
+
+	// Only the latest read of a key fills it.
+	first := r.reserve("a")
+	second := r.reserve("a")
+	r.fill("a", first, fill)
+	assert.Equal(t, 1, filled)
+	r.cancel("a", first)
+	r.fill("a", second, fill)
+	assert.Equal(t, 2, filled)
+
+	r.cancel("b", r.reserve("b"))
+	assert.Empty(t, r.tokens)
+}
@@ -0,0 +1,314 @@
+package cachetiered
+
+import (
+	"context"
+	"maps"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
+	cacheredis "github.com/trinhdaiphuc/go-kit/cache/redis"
+)
+
+// tieredCache is a two-tier cache.Store: an in-process cachelocal client (L1)
+// in front of a Redis store (L2). Reads go L1 -> L2 -> Loader, writes go to both
+// tiers and every mutation is broadcast to the other replicas so they can drop
+// their L1 copy.
+type tieredCache[K comparable, V any] struct {
+	local        cache.Store[K, V]
+	remote       cacheredis.RedisCache[K, V]
+	invalidator  *invalidator[K]
+	reservations *reservations[K]
+	opts         *Options[K, V]
+}
+
+// NewTieredCache creates a two-tier cache on top of the given Redis client.
+// The Loader is only called when both tiers miss and receives the tiered store.
+// The values loaded by a cache.TTLLoader are written to both tiers, other
+// Loaders write them through the tiered store. Without a Loader, BulkGet leaves
+// out the keys that are in neither tier, like cacheredis.
+func NewTieredCache[K comparable, V any](cli redis.UniversalClient, options ...Option[K, V]) cache.Store[K, V] {
+	opts := newDefaultOption[K, V]()
+	for _, o := range options {
+		o(opts)
+	}
+
+	c := &tieredCache[K, V]{
+		reservations: newReservations[K](),
+		opts:         opts,
+	}
+
+	localOpts := make([]cachelocal.Option[K, V], 0, len(opts.LocalOptions)+1)
+	localOpts = append(localOpts, opts.LocalOptions...)
+	localOpts = append(localOpts, cachelocal.WithTTL[K, V](opts.LocalTTL))
+
+	redisOpts := make([]cacheredis.Option[K, V], 0, len(opts.RedisOptions)+3)
+	redisOpts = append(redisOpts, opts.RedisOptions...)
+	redisOpts = append(redisOpts, cacheredis.WithPrefix[K, V](opts.Prefix), cacheredis.WithTTL[K, V](opts.RedisTTL))
+	if opts.Loader != nil {
+		redisOpts = append(redisOpts, cacheredis.WithLoader[K, V](&remoteLoader[K, V]{WrappedLoader: cache.WrappedLoader[K, V]{Loader: opts.Loader}, c: c}))
+	}
+
+	c.local = cachelocal.NewClient[K, V](localOpts...)
+	c.remote = cacheredis.NewRedisCache[K, V](cli, redisOpts...)
+	c.invalidator = newInvalidator[K](cli, opts.channel(), c.evictLocal)
+
+	return c
+}
+
+func (c *tieredCache[K, V]) Get(ctx context.Context, key K) (value V, err error) {
+	value, err = c.local.Get(ctx, key)
+	if err == nil {
+		return value, nil
+	}
+
+	token := c.reservations.reserve(key)
+	value, err = c.remote.Get(ctx, key)
+	if err != nil {
+		c.reservations.cancel(key, token)
+		return value, err
+	}
+
+	c.fillLocal(ctx, key, token, value)
+	return value, nil
+}
+
+func (c *tieredCache[K, V]) BulkGet(ctx context.Context, keys []K) (map[K]V, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	rs, err := c.local.BulkGet(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if rs == nil {
+		rs = make(map[K]V, len(keys))
+	}
+
+	missingKeys := missing(keys, rs)
+	if len(missingKeys) == 0 {
+		return rs, nil
+	}
+
+	tokens := make([]uint64, len(missingKeys))
+	for i, key := range missingKeys {
+		tokens[i] = c.reservations.reserve(key)
+	}
+
+	remoteValues, err := c.remote.BulkGet(ctx, missingKeys)
+	for i, key := range missingKeys {
+		value, ok := remoteValues[key]
+		if err != nil || !ok {
+			c.reservations.cancel(key, tokens[i])
+			continue
+		}
+		c.fillLocal(ctx, key, tokens[i], value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(rs, remoteValues)
+
+	return rs, nil
+}
+
+// Set writes the value to both tiers. The write options apply to Redis; the L1
+// copy never outlives the local TTL.
+func (c *tieredCache[K, V]) Set(ctx context.Context, key K, value V, opts ...cache.WriteOption) error {
+	if err := c.remote.Set(ctx, key, value, opts...); err != nil {
+		return err
+	}
+
+	c.invalidator.publish(ctx, key)
+	return c.updateLocal([]K{key}, func() error {
+		return c.local.Set(ctx, key, value, c.localOptions(opts)...)
+	})
+}
+
+func (c *tieredCache[K, V]) SetNX(ctx context.Context, key K, value V, opts ...cache.WriteOption) (bool, error) {
+	ok, err := c.remote.SetNX(ctx, key, value, opts...)
+	if err != nil || !ok {
+		return ok, err
+	}
+
+	c.invalidator.publish(ctx, key)
+	return true, c.updateLocal([]K{key}, func() error {
+		return c.local.Set(ctx, key, value, c.localOptions(opts)...)
+	})
+}
+
+func (c *tieredCache[K, V]) BulkSet(ctx context.Context, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	if len(keyVals) == 0 {
+		return nil
+	}
+	if err := c.remote.BulkSet(ctx, keyVals, opts...); err != nil {
+		return err
+	}
+
+	keys := make([]K, 0, len(keyVals))
+	for _, keyVal := range keyVals {
+		keys = append(keys, keyVal.Key)
+	}
+	c.invalidator.publish(ctx, keys...)
+	return c.updateLocal(keys, func() error {
+		return cache.BulkSet(ctx, c.local, keyVals, c.localOptions(opts)...)
+	})
+}
+
+func (c *tieredCache[K, V]) Delete(ctx context.Context, keys ...K) error {
+	if err := c.remote.Delete(ctx, keys...); err != nil {
+		return err
+	}
+
+	c.invalidator.publish(ctx, keys...)
+	return c.updateLocal(keys, func() error {
+		return c.local.Delete(ctx, keys...)
+	})
+}
+
+// Incr is executed on Redis only, the L1 copy is dropped everywhere.
+func (c *tieredCache[K, V]) Incr(ctx context.Context, key K, value int64) (int64, error) {
+	result, err := c.remote.Incr(ctx, key, value)
+	if err != nil {
+		return result, err
+	}
+
+	c.evictLocal(key)
+	c.invalidator.publish(ctx, key)
+	return result, nil
+}
+
+func (c *tieredCache[K, V]) Expire(ctx context.Context, key K, expireTime time.Duration) error {
+	if err := c.remote.Expire(ctx, key, expireTime); err != nil {
+		return err
+	}
+
+	c.evictLocal(key)
+	c.invalidator.publish(ctx, key)
+	return nil
+}
+
+func (c *tieredCache[K, V]) TTL(ctx context.Context, key K) (time.Duration, error) {
+	return c.remote.TTL(ctx, key)
+}
+
+// HSet, HGet, HGetAll and HDel only use the Redis tier: hashes are usually
+// written field by field, so an L1 copy would often be partial.
+func (c *tieredCache[K, V]) HSet(ctx context.Context, key K, keyVals ...cache.KeyVal[K, V]) error {
+	return c.HSetWithOptions(ctx, key, keyVals)
+}
+
+func (c *tieredCache[K, V]) HSetWithOptions(ctx context.Context, key K, keyVals []cache.KeyVal[K, V], opts ...cache.WriteOption) error {
+	if err := cache.HSetWithOptions(ctx, c.remote, key, keyVals, opts...); err != nil {
+		return err
+	}
+
+	c.invalidator.publish(ctx, key)
+	return nil
+}
+
+func (c *tieredCache[K, V]) HGet(ctx context.Context, key, field K) (V, error) {
+	return c.remote.HGet(ctx, key, field)
+}
+
+func (c *tieredCache[K, V]) HGetAll(ctx context.Context, key K) (map[K]V, error) {
+	return c.remote.HGetAll(ctx, key)
+}
+
+func (c *tieredCache[K, V]) HDel(ctx context.Context, key K, fields ...K) error {
+	if err := c.remote.HDel(ctx, key, fields...); err != nil {
+		return err
+	}
+
+	c.invalidator.publish(ctx, key)
+	return nil
+}
+
+// InvalidateTags deletes the tagged keys from Redis and evicts their L1 copies
+// everywhere. The keys are read from the Redis tag sets first, since the L1
+// copies filled by a read don't know their tags; with keys other than strings
+// this requires a cacheredis.WithKeyDecoder in the Redis options.
+func (c *tieredCache[K, V]) InvalidateTags(ctx context.Context, tags ...string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys, err := c.remote.TaggedKeys(ctx, tags...)
+	if err != nil {
+		return err
+	}
+
+	if err = c.remote.InvalidateTags(ctx, tags...); err != nil {
+		return err
+	}
+
+	c.invalidator.publish(ctx, keys...)
+	c.evictLocal(keys...)
+	return cache.InvalidateTags(ctx, c.local, tags...)
+}
+
+func (c *tieredCache[K, V]) Ping(ctx context.Context) error {
+	return c.remote.Ping(ctx)
+}
+
+func (c *tieredCache[K, V]) Close() {
+	c.invalidator.close()
+	c.local.Close()
+	c.remote.Close()
+}
+
+// localTTL returns the write options of an L1 write: ttl if it is shorter than
+// the local TTL, the local TTL otherwise.
+func (c *tieredCache[K, V]) localTTL(ttl time.Duration) []cache.WriteOption {
+	if ttl <= 0 || ttl >= c.opts.LocalTTL {
+		return nil
+	}
+	return []cache.WriteOption{cache.WithTTL(ttl)}
+}
+
+// localOptions returns the write options of the L1 copy of a write: its TTL,
+// capped by localTTL, and its tags.
+func (c *tieredCache[K, V]) localOptions(opts []cache.WriteOption) []cache.WriteOption {
+	o := cache.NewWriteOptions(0, opts...)
+	localOpts := c.localTTL(o.TTL)
+	if len(o.Tags) > 0 {
+		localOpts = append(localOpts, cache.WithTags(o.Tags...))
+	}
+	return localOpts
+}
+
+// fillLocal copies a value read from Redis into L1, unless the key was
+// invalidated or written since it was reserved.
+func (c *tieredCache[K, V]) fillLocal(ctx context.Context, key K, token uint64, value V) {
+	c.reservations.fill(key, token, func() {
+		_ = c.local.Set(ctx, key, value, c.localTTL(cache.LoadTTL(c.opts.Loader, key, value))...)
+	})
+}
+
+// updateLocal changes the L1 copies of the keys with update, after dropping the
+// pending fills of the keys.
+func (c *tieredCache[K, V]) updateLocal(keys []K, update func() error) (err error) {
+	c.reservations.evict(keys, func() {
+		err = update()
+	})
+	return err
+}
+
+func (c *tieredCache[K, V]) evictLocal(keys ...K) {
+	_ = c.updateLocal(keys, func() error {
+		return c.local.Delete(context.Background(), keys...)
+	})
+}
+
+func missing[K comparable, V any](keys []K, found map[K]V) []K {
+	missingKeys := make([]K, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := found[key]; !ok {
+			missingKeys = append(missingKeys, key)
+		}
+	}
+	return missingKeys
+}
@@ -0,0 +1,185 @@
+package cachetiered
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/internal/redistest"
+)
+
+// sourceLoader loads the values of a map and records the keys it was asked for.
+type sourceLoader struct {
+	source map[string]int
+
+	mu    sync.Mutex
+	loads [][]string
+}
+
+func (l *sourceLoader) Load(ctx context.Context, c cache.Store[string, int], key string) (int, error) {
+	l.record(key)
+	value, ok := l.source[key]
+	if !ok {
+		return 0, cache.ErrorKeyNotFound
+	}
+	return value, nil
+}
+
+func (l *sourceLoader) LoadAll(ctx context.Context, c cache.Store[string, int], key string) (map[string]int, error) {
+	return nil, cache.ErrorKeyNotFound
+}
+
+func (l *sourceLoader) BulkLoad(ctx context.Context, c cache.Store[string, int], keys []string) (map[string]int, error) {
+	l.record(keys...)
+	values := make(map[string]int, len(keys))
+	for _, key := range keys {
+		if value, ok := l.source[key]; ok {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+func (l *sourceLoader) record(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.loads = append(l.loads, slices.Sorted(slices.Values(keys)))
+}
+
+func (l *sourceLoader) calls() [][]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.loads)
+}
+
+func newTestCache(t *testing.T, client redis.UniversalClient, opts ...Option[string, int]) cache.Store[string, int] {
+	subscribers := func() int64 {
+		return client.PubSubNumSub(context.Background(), "test:invalidate").Val()["test:invalidate"]
+	}
+	before := subscribers()
+
+	c := NewTieredCache[string, int](client, append([]Option[string, int]{WithPrefix[string, int]("test")}, opts...)...)
+	t.Cleanup(c.Close)
+
+	// Wait for the invalidation subscription, so that no message is missed.
+	require.Eventually(t, func() bool {
+		return subscribers() > before
+	}, 5*time.Second, 10*time.Millisecond)
+	return c
+}
+
+func TestTieredCache_Get(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	loader := &sourceLoader{source: map[string]int{"a": 1}}
+	c := newTestCache(t, client, WithLoader[string, int](loader))
+
+	// Both tiers miss: the value is loaded and written to Redis.
+	value, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, [][]string{{"a"}}, loader.calls())
+	assert.Equal(t, int64(1), client.Exists(ctx, "test:a").Val())
+
+	// L1 hit: Redis isn't read.
+	assert.NoError(t, client.Del(ctx, "test:a").Err())
+	value, err = c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.Len(t, loader.calls(), 1)
+
+	// L2 hit: another replica reads Redis without loading.
+	other := newTestCache(t, client, WithLoader[string, int](loader))
+	assert.NoError(t, c.Set(ctx, "b", 2))
+	value, err = other.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.Len(t, loader.calls(), 1)
+
+	_, err = c.Get(ctx, "missing")
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+}
+
+func TestTieredCache_Invalidation(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	writer := newTestCache(t, client)
+	reader := newTestCache(t, client)
+
+	assert.NoError(t, writer.Set(ctx, "a", 1))
+	value, err := reader.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	// The reader drops its L1 copy when the writer broadcasts the write.
+	assert.NoError(t, writer.Set(ctx, "a", 2))
+	assert.Eventually(t, func() bool {
+		value, err := reader.Get(ctx, "a")
+		return err == nil && value == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, writer.Delete(ctx, "a"))
+	assert.Eventually(t, func() bool {
+		_, err := reader.Get(ctx, "a")
+		return cache.IsErrorKeyNotFound(err)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTieredCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	c := newTestCache(t, client,
+		WithLocalTTL[string, int](200*time.Millisecond),
+		WithRedisTTL[string, int](time.Hour),
+	)
+
+	assert.NoError(t, c.Set(ctx, "a", 1))
+	ttl, err := client.TTL(ctx, "test:a").Result()
+	assert.NoError(t, err)
+	assert.InDelta(t, time.Hour, ttl, float64(time.Minute))
+
+	// The L1 copy outlives the Redis key until the local TTL.
+	assert.NoError(t, client.Del(ctx, "test:a").Err())
+	value, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+
+	assert.Eventually(t, func() bool {
+		_, err := c.Get(ctx, "a")
+		return cache.IsErrorKeyNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestTieredCache_BulkGet(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	loader := &sourceLoader{source: map[string]int{"a": 1, "b": 2, "c": 3}}
+	c := newTestCache(t, client, WithLoader[string, int](loader))
+
+	assert.NoError(t, c.Set(ctx, "a", 10))
+
+	// a is in both tiers, b and c are loaded and written to both tiers.
+	values, err := c.BulkGet(ctx, []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 10, "b": 2, "c": 3}, values)
+	assert.Equal(t, [][]string{{"b", "c"}}, loader.calls())
+	assert.Equal(t, int64(3), client.Exists(ctx, "test:a", "test:b", "test:c").Val())
+
+	other := newTestCache(t, client, WithLoader[string, int](loader))
+	values, err = other.BulkGet(ctx, []string{"a", "b", "c"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 10, "b": 2, "c": 3}, values)
+	assert.Len(t, loader.calls(), 1)
+
+	// Without a loader, a key in neither tier fails the read, like cacheredis.
+	noLoader := newTestCache(t, client)
+	_, err = noLoader.BulkGet(ctx, []string{"a", "d"})
+	assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+}
@@ -0,0 +1,61 @@
+package cachewarmup
+
+import (
+	"github.com/trinhdaiphuc/go-kit/cache"
+)
+
+type Options struct {
+	ChunkSize    int
+	Concurrency  int
+	RateLimit    float64
+	WriteOptions []cache.WriteOption
+	OnProgress   func(Progress)
+}
+
+type Option func(*Options)
+
+// WithChunkSize sets how many keys are loaded by one BulkLoad call.
+func WithChunkSize(size int) Option {
+	return func(o *Options) {
+		o.ChunkSize = size
+	}
+}
+
+// WithConcurrency sets how many chunks are loaded at the same time.
+func WithConcurrency(concurrency int) Option {
+	return func(o *Options) {
+		o.Concurrency = concurrency
+	}
+}
+
+// WithRateLimit caps the number of keys loaded per second, so that the warm-up
+// doesn't overload the source it loads from. 0 means no limit.
+func WithRateLimit(keysPerSecond float64) Option {
+	return func(o *Options) {
+		o.RateLimit = keysPerSecond
+	}
+}
+
+// WithWriteOptions sets the options of the writes into the store, e.g. a TTL.
+// The TTL a TTLLoader chooses for a value overrides them, and the values a
+// Loader writes through the store itself keep that write.
+func WithWriteOptions(opts ...cache.WriteOption) Option {
+	return func(o *Options) {
+		o.WriteOptions = append(o.WriteOptions, opts...)
+	}
+}
+
+// WithProgress sets a function called after every chunk with the progress so
+// far, and once more when the warm-up is done. The calls don't overlap.
+func WithProgress(fn func(Progress)) Option {
+	return func(o *Options) {
+		o.OnProgress = fn
+	}
+}
+
+func newDefaultOption() *Options {
+	return &Options{
+		ChunkSize:   100,
+		Concurrency: 4,
+	}
+}
@@ -0,0 +1,64 @@
+package cachewarmup
+
+import (
+	"context"
+	"iter"
+)
+
+// KeySource returns the keys to warm up. It is called once per Run, and stops
+// the warm-up at its first error.
+type KeySource[K comparable] func(ctx context.Context) iter.Seq2[K, error]
+
+// FromSlice returns a KeySource of the given keys.
+func FromSlice[K comparable](keys []K) KeySource[K] {
+	return func(ctx context.Context) iter.Seq2[K, error] {
+		return func(yield func(K, error) bool) {
+			for _, key := range keys {
+				if !yield(key, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FromFunc returns a KeySource of the keys returned by fn, e.g. the ids of the
+// most read rows.
+func FromFunc[K comparable](fn func(ctx context.Context) ([]K, error)) KeySource[K] {
+	return func(ctx context.Context) iter.Seq2[K, error] {
+		return func(yield func(K, error) bool) {
+			keys, err := fn(ctx)
+			if err != nil {
+				var zero K
+				yield(zero, err)
+				return
+			}
+			for _, key := range keys {
+				if !yield(key, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FromChannel returns a KeySource of the keys received from ch until it is
+// closed or the context is done.
+func FromChannel[K comparable](ch <-chan K) KeySource[K] {
+	return func(ctx context.Context) iter.Seq2[K, error] {
+		return func(yield func(K, error) bool) {
+			for {
+				select {
+				case <-ctx.Done():
+					var zero K
+					yield(zero, ctx.Err())
+					return
+				case key, ok := <-ch:
+					if !ok || !yield(key, nil) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package cachewarmup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// ErrNotReady is returned by Check while the warm-up hasn't finished.
+var ErrNotReady = errors.New("cachewarmup: cache is warming up")
+
+// Progress is a snapshot of a warm-up.
+type Progress struct {
+	// Keys is the number of keys read from the source so far.
+	Keys int64
+	// Loaded is the number of keys loaded and written into the store.
+	Loaded int64
+	// Missing is the number of keys the Loader returned no value for.
+	Missing int64
+	// Failed is the number of keys of the chunks that failed to load or write.
+	Failed  int64
+	Elapsed time.Duration
+	Done    bool
+}
+
+// Warmer fills a store with the values of the keys of a KeySource, loaded with
+// Loader.BulkLoad in chunks. The chunks are loaded concurrently and the number
+// of keys loaded per second can be capped. A failed chunk is logged and
+// counted, the warm-up goes on with the next ones.
+//
+// Readiness probes wait for the warm-up with Done, Wait or Check, which
+// implements the health check of grpcserver.
+type Warmer[K comparable, V any] struct {
+	store   cache.Store[K, V]
+	loader  cache.Loader[K, V]
+	source  KeySource[K]
+	opts    *Options
+	limiter *rate.Limiter
+
+	once sync.Once
+	done chan struct{}
+	err  error
+
+	mu       sync.Mutex
+	progress Progress
+	started  time.Time
+	firstErr error
+	failures int
+	reportMu sync.Mutex
+}
+
+func NewWarmer[K comparable, V any](store cache.Store[K, V], loader cache.Loader[K, V], source KeySource[K], opts ...Option) *Warmer[K, V] {
+	options := newDefaultOption()
+	for _, opt := range opts {
+		opt(options)
+	}
+	options.ChunkSize = max(1, options.ChunkSize)
+	options.Concurrency = max(1, options.Concurrency)
+
+	limit := rate.Inf
+	if options.RateLimit > 0 {
+		limit = rate.Limit(options.RateLimit)
+	}
+
+	return &Warmer[K, V]{
+		store:   store,
+		loader:  loader,
+		source:  source,
+		opts:    options,
+		limiter: rate.NewLimiter(limit, options.ChunkSize),
+		done:    make(chan struct{}),
+	}
+}
+
+// Run warms the store up and returns once every chunk has been loaded. It
+// fails with the error of the key source or of the context, or when a chunk
+// failed. The warm-up only runs once: later calls wait for it and return the
+// same error.
+func (w *Warmer[K, V]) Run(ctx context.Context) error {
+	w.once.Do(func() {
+		w.err = w.run(ctx)
+		close(w.done)
+	})
+	<-w.done
+	return w.err
+}
+
+// Start runs the warm-up in the background.
+func (w *Warmer[K, V]) Start(ctx context.Context) {
+	go func() {
+		_ = w.Run(ctx)
+	}()
+}
+
+// Done returns a channel that is closed once the warm-up has finished, whether
+// it succeeded or not.
+func (w *Warmer[K, V]) Done() <-chan struct{} {
+	return w.done
+}
+
+// Wait waits for the warm-up to finish and returns its error.
+func (w *Warmer[K, V]) Wait(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-w.done:
+		return w.err
+	}
+}
+
+// Check returns ErrNotReady until the warm-up has finished. A failed warm-up
+// doesn't keep the service out of traffic: it only serves more misses.
+func (w *Warmer[K, V]) Check(ctx context.Context) error {
+	select {
+	case <-w.done:
+		return nil
+	default:
+		return ErrNotReady
+	}
+}
+
+func (w *Warmer[K, V]) Progress() Progress {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.snapshot()
+}
+
+func (w *Warmer[K, V]) run(ctx context.Context) error {
+	w.mu.Lock()
+	w.started = time.Now()
+	w.mu.Unlock()
+
+	var (
+		wg    sync.WaitGroup
+		sem   = make(chan struct{}, w.opts.Concurrency)
+		chunk = make([]K, 0, w.opts.ChunkSize)
+		err   error
+	)
+	dispatch := func() error {
+		keys := chunk
+		chunk = make([]K, 0, w.opts.ChunkSize)
+
+		if err := w.limiter.WaitN(ctx, len(keys)); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		w.mu.Lock()
+		w.progress.Keys += int64(len(keys))
+		w.mu.Unlock()
+
+		wg.Go(func() {
+			defer func() { <-sem }()
+			w.load(ctx, keys)
+		})
+		return nil
+	}
+
+	for key, errSource := range w.source(ctx) {
+		if errSource != nil {
+			err = fmt.Errorf("read keys: %w", errSource)
+			break
+		}
+
+		chunk = append(chunk, key)
+		if len(chunk) == w.opts.ChunkSize {
+			if err = dispatch(); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil && len(chunk) > 0 {
+		err = dispatch()
+	}
+	wg.Wait()
+
+	w.mu.Lock()
+	w.progress.Elapsed = time.Since(w.started)
+	w.progress.Done = true
+	progress := w.progress
+	if err == nil && w.failures > 0 {
+		err = fmt.Errorf("%d chunks failed: %w", w.failures, w.firstErr)
+	}
+	w.mu.Unlock()
+	w.report(progress)
+
+	fields := []zap.Field{
+		zap.Int64("keys", progress.Keys),
+		zap.Int64("loaded", progress.Loaded),
+		zap.Int64("missing", progress.Missing),
+		zap.Int64("failed", progress.Failed),
+		zap.Duration("elapsed", progress.Elapsed),
+	}
+	if err != nil {
+		log.For(ctx).Error("Cache warm-up failed", append(fields, zap.Error(err))...)
+	} else {
+		log.For(ctx).Info("Cache warm-up done", fields...)
+	}
+	return err
+}
+
+// load loads a chunk and writes its values into the store.
+func (w *Warmer[K, V]) load(ctx context.Context, keys []K) {
+	values, err := w.loader.BulkLoad(ctx, w.store, keys)
+	if err == nil && len(values) > 0 {
+		err = w.setLoaded(ctx, values)
+	}
+
+	w.mu.Lock()
+	if err != nil {
+		log.For(ctx).Warn("Cache warm-up chunk failed", zap.Int("keys", len(keys)), zap.Error(err))
+		w.progress.Failed += int64(len(keys))
+		w.failures++
+		if w.firstErr == nil {
+			w.firstErr = err
+		}
+	} else {
+		for _, key := range keys {
+			if _, ok := values[key]; ok {
+				w.progress.Loaded++
+			} else {
+				w.progress.Missing++
+			}
+		}
+	}
+
+	progress := w.snapshot()
+	w.mu.Unlock()
+	w.report(progress)
+}
+
+// setLoaded writes the values loaded by a TTLLoader with the TTL it chose for
+// each of them, or with the write options of the warmer when it chose none.
+// The values another Loader wrote through the store keep that write, the
+// others are written with the write options of the warmer.
+func (w *Warmer[K, V]) setLoaded(ctx context.Context, values map[K]V) error {
+	if !cache.ChoosesTTL(w.loader) {
+		return w.setUnwritten(ctx, values)
+	}
+
+	keyVals := make([]cache.KeyVal[K, V], 0, len(values))
+	for key, value := range values {
+		if ttl := cache.LoadTTL(w.loader, key, value); ttl > 0 {
+			opts := append(slices.Clip(w.opts.WriteOptions), cache.WithTTL(ttl))
+			if err := w.store.Set(ctx, key, value, opts...); err != nil {
+				return err
+			}
+			continue
+		}
+		keyVals = append(keyVals, cache.KeyVal[K, V]{Key: key, Value: value})
+	}
+
+	if len(keyVals) == 0 {
+		return nil
+	}
+	return cache.BulkSet(ctx, w.store, keyVals, w.opts.WriteOptions...)
+}
+
+// setUnwritten writes the values missing from the store.
+func (w *Warmer[K, V]) setUnwritten(ctx context.Context, values map[K]V) error {
+	keys := slices.Collect(maps.Keys(values))
+	cached, err := w.store.BulkGet(cache.WithoutLoader(ctx), keys)
+	if err != nil {
+		return err
+	}
+
+	keyVals := make([]cache.KeyVal[K, V], 0, len(values))
+	for _, key := range keys {
+		if _, ok := cached[key]; !ok {
+			keyVals = append(keyVals, cache.KeyVal[K, V]{Key: key, Value: values[key]})
+		}
+	}
+	if len(keyVals) == 0 {
+		return nil
+	}
+	return cache.BulkSet(ctx, w.store, keyVals, w.opts.WriteOptions...)
+}
+
+func (w *Warmer[K, V]) report(progress Progress) {
+	if w.opts.OnProgress == nil {
+		return
+	}
+
+	w.reportMu.Lock()
+	defer w.reportMu.Unlock()
+	w.opts.OnProgress(progress)
+}
+
+func (w *Warmer[K, V]) snapshot() Progress {
+	progress := w.progress
+	if !progress.Done && !w.started.IsZero() {
+		progress.Elapsed = time.Since(w.started)
+	}
+	return progress
+}
@@ -0,0 +1,223 @@
+package cachewarmup
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
+)
+
+type chunkLoader struct {
+	mu     sync.Mutex
+	chunks [][]int
+	fail   int
+	// leaveWrite leaves writing the loaded values to the caller.
+	leaveWrite bool
+}
+
+func (l *chunkLoader) Load(ctx context.Context, c cache.Store[int, string], key int) (string, error) {
+	return "", errors.New("unexpected Load")
+}
+
+func (l *chunkLoader) LoadAll(ctx context.Context, c cache.Store[int, string], key int) (map[int]string, error) {
+	return nil, errors.New("unexpected LoadAll")
+}
+
+func (l *chunkLoader) BulkLoad(ctx context.Context, c cache.Store[int, string], keys []int) (map[int]string, error) {
+	l.mu.Lock()
+	l.chunks = append(l.chunks, slices.Clone(keys))
+	l.mu.Unlock()
+
+	values := make(map[int]string, len(keys))
+	for _, key := range keys {
+		if key == l.fail {
+			return nil, errors.New("source down")
+		}
+		// Multiples of 10 don't exist at the source.
+		if key%10 != 0 {
+			values[key] = string(rune('a' + key%26))
+		}
+	}
+	if !l.leaveWrite {
+		keyVals := make([]cache.KeyVal[int, string], 0, len(values))
+		for key, value := range values {
+			keyVals = append(keyVals, cache.KeyVal[int, string]{Key: key, Value: value})
+		}
+		if err := cache.BulkSet(ctx, c, keyVals, cache.WithTTL(time.Hour)); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// ttlChunkLoader leaves writing its values to the warmer and keeps odd keys for
+// a minute.
+type ttlChunkLoader struct {
+	chunkLoader
+}
+
+func (l *ttlChunkLoader) LoadTTL(key int, value string) time.Duration {
+	if key%2 == 1 {
+		return time.Minute
+	}
+	return 0
+}
+
+func keys(from, to int) []int {
+	var keys []int
+	for key := from; key <= to; key++ {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestWarmer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("loads the keys in chunks", func(t *testing.T) {
+		store := cachelocal.NewClient[int, string]()
+		defer store.Close()
+		loader := &chunkLoader{fail: -1}
+
+		var reports []Progress
+		w := NewWarmer[int, string](store, loader, FromSlice(keys(1, 25)),
+			WithChunkSize(10), WithConcurrency(2), WithProgress(func(p Progress) {
+				reports = append(reports, p)
+			}))
+		assert.ErrorIs(t, w.Check(ctx), ErrNotReady)
+
+		assert.NoError(t, w.Run(ctx))
+		assert.NoError(t, w.Check(ctx))
+		assert.NoError(t, w.Wait(ctx))
+
+		assert.Len(t, loader.chunks, 3)
+		progress := w.Progress()
+		assert.Equal(t, int64(25), progress.Keys)
+		assert.Equal(t, int64(23), progress.Loaded)
+		assert.Equal(t, int64(2), progress.Missing)
+		assert.Zero(t, progress.Failed)
+		assert.True(t, progress.Done)
+		assert.Len(t, reports, 4)
+		assert.Equal(t, progress, reports[3])
+
+		value, err := store.Get(ctx, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, "h", value)
+	})
+
+	t.Run("keeps the writes of the loader", func(t *testing.T) {
+		store := cachelocal.NewClient[int, string]()
+		defer store.Close()
+
+		w := NewWarmer[int, string](store, &chunkLoader{fail: -1}, FromSlice(keys(1, 5)),
+			WithWriteOptions(cache.WithTTL(time.Second)))
+		assert.NoError(t, w.Run(ctx))
+
+		ttl, err := store.TTL(ctx, 3)
+		assert.NoError(t, err)
+		assert.Greater(t, ttl, time.Minute)
+	})
+
+	t.Run("writes the values the loader returns", func(t *testing.T) {
+		store := cachelocal.NewClient[int, string]()
+		defer store.Close()
+		loader := struct {
+			cache.LoaderFunc[int, string]
+			cache.LoaderFuncAll[int, string]
+			cache.BulkLoaderFunc[int, string]
+		}{
+			BulkLoaderFunc: func(ctx context.Context, c cache.Store[int, string], keys []int) (map[int]string, error) {
+				values := make(map[int]string, len(keys))
+				for _, key := range keys {
+					values[key] = string(rune('a' + key))
+				}
+				return values, nil
+			},
+		}
+
+		w := NewWarmer[int, string](store, loader, FromSlice(keys(1, 5)),
+			WithWriteOptions(cache.WithTTL(time.Minute)))
+		assert.NoError(t, w.Run(ctx))
+		assert.Equal(t, int64(5), w.Progress().Loaded)
+
+		value, err := store.Get(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, "d", value)
+		ttl, err := store.TTL(ctx, 3)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, ttl, time.Minute)
+	})
+
+	t.Run("writes the values of a TTLLoader with its TTLs", func(t *testing.T) {
+		store := cachelocal.NewClient[int, string]()
+		defer store.Close()
+		loader := &ttlChunkLoader{chunkLoader{fail: -1, leaveWrite: true}}
+
+		w := NewWarmer[int, string](store, loader, FromSlice(keys(1, 4)),
+			WithWriteOptions(cache.WithTTL(time.Hour)))
+		assert.NoError(t, w.Run(ctx))
+
+		ttl, err := store.TTL(ctx, 3)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, ttl, time.Minute)
+
+		ttl, err = store.TTL(ctx, 4)
+		assert.NoError(t, err)
+		assert.Greater(t, ttl, time.Minute)
+	})
+
+	t.Run("a failed chunk doesn't stop the warm-up", func(t *testing.T) {
+		store := cachelocal.NewClient[int, string]()
+		defer store.Close()
+		loader := &chunkLoader{fail: 5}
+
+		w := NewWarmer[int, string](store, loader, FromSlice(keys(1, 20)), WithChunkSize(10))
+		err := w.Run(ctx)
+		assert.ErrorContains(t, err, "1 chunks failed: source down")
+		assert.NoError(t, w.Check(ctx))
+
+		progress := w.Progress()
+		assert.Equal(t, int64(10), progress.Failed)
+		assert.Equal(t, int64(9), progress.Loaded)
+
+		// The warm-up only runs once.
+		assert.Equal(t, err, w.Run(ctx))
+		assert.Len(t, loader.chunks, 2)
+	})
+
+	t.Run("channel source", func(t *testing.T) {
+		store := cachelocal.NewClient[int, string]()
+		defer store.Close()
+
+		ch := make(chan int)
+		w := NewWarmer[int, string](store, &chunkLoader{fail: -1}, FromChannel(ch), WithChunkSize(2))
+		w.Start(ctx)
+
+		ch <- 1
+		ch <- 2
+		ch <- 3
+		assert.ErrorIs(t, w.Check(ctx), ErrNotReady)
+		close(ch)
+
+		<-w.Done()
+		assert.NoError(t, w.Wait(ctx))
+		assert.Equal(t, int64(3), w.Progress().Loaded)
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		store := cachelocal.NewClient[int, string]()
+		defer store.Close()
+
+		w := NewWarmer[int, string](store, &chunkLoader{fail: -1}, FromFunc(func(ctx context.Context) ([]int, error) {
+			return nil, errors.New("query failed")
+		}))
+		assert.EqualError(t, w.Run(ctx), "read keys: query failed")
+	})
+}
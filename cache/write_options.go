@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// WriteOptions controls the expiry of a single write. The zero value means
+// "use the store-wide TTL".
+type WriteOptions struct {
+	TTL      time.Duration
+	KeepTTL  bool
+	NoExpiry bool
+	// Tags are attached to the written key, see TagInvalidator.
+	Tags []string
+}
+
+type WriteOption func(*WriteOptions)
+
+// WithTTL overrides the store-wide TTL for this write.
+func WithTTL(ttl time.Duration) WriteOption {
+	return func(o *WriteOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithKeepTTL keeps the current TTL of the key, like SET ... KEEPTTL.
+// A new key gets no expiry.
+func WithKeepTTL() WriteOption {
+	return func(o *WriteOptions) {
+		o.KeepTTL = true
+	}
+}
+
+// WithNoExpiry writes the key without an expiry.
+func WithNoExpiry() WriteOption {
+	return func(o *WriteOptions) {
+		o.NoExpiry = true
+	}
+}
+
+// WithTags attaches tags to the written key, so that it can be deleted together
+// with every other key of a tag by InvalidateTags. Tags accumulate: writing
+// the key again without tags doesn't detach it from its previous tags.
+func WithTags(tags ...string) WriteOption {
+	return func(o *WriteOptions) {
+		o.Tags = append(o.Tags, tags...)
+	}
+}
+
+// NewWriteOptions applies opts on top of the store-wide TTL.
+func NewWriteOptions(defaultTTL time.Duration, opts ...WriteOption) *WriteOptions {
+	o := &WriteOptions{TTL: defaultTTL}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Jitter randomizes ttl by up to ±fraction (e.g. 0.1 for 10%) so that keys written
+// together don't expire together. A non-positive ttl or fraction is returned as is.
+func Jitter(ttl time.Duration, fraction float64) time.Duration {
+	if ttl <= 0 || fraction <= 0 {
+		return ttl
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+
+	delta := time.Duration((rand.Float64()*2 - 1) * fraction * float64(ttl)) // nolint: gosec
+	if ttl+delta <= 0 {
+		return ttl
+	}
+	return ttl + delta
+}
+
+// TTLLoader is an optional interface of a Loader that chooses the TTL of each
+// value it loads, e.g. short TTLs for volatile records; a zero duration means
+// the store-wide TTL. The stores write the values loaded by a TTLLoader
+// themselves. Other Loaders write the values they load through the Store they
+// are handed, with the write options they choose, and the stores keep that
+// write.
+type TTLLoader[K comparable, V any] interface {
+	LoadTTL(key K, value V) time.Duration
+}
+
+// ChoosesTTL reports whether loader implements TTLLoader, looking through the
+// decorators embedding WrappedLoader.
+func ChoosesTTL[K comparable, V any](loader Loader[K, V]) bool {
+	for {
+		w, ok := loader.(interface{ Unwrap() Loader[K, V] })
+		if !ok {
+			_, ok = loader.(TTLLoader[K, V])
+			return ok
+		}
+		loader = w.Unwrap()
+	}
+}
+
+// LoadTTL returns the TTL chosen by loader for a loaded value, or 0 if the loader
+// doesn't implement TTLLoader.
+func LoadTTL[K comparable, V any](loader Loader[K, V], key K, value V) time.Duration {
+	if l, ok := loader.(TTLLoader[K, V]); ok {
+		return l.LoadTTL(key, value)
+	}
+	return 0
+}
+
+// WrappedLoader is embedded by the Loaders that decorate another Loader. It
+// holds the decorated Loader and implements TTLLoader by asking it, so that the
+// TTLs it chooses go through the decorators.
+type WrappedLoader[K comparable, V any] struct {
+	Loader Loader[K, V]
+}
+
+func (w WrappedLoader[K, V]) LoadTTL(key K, value V) time.Duration {
+	return LoadTTL(w.Loader, key, value)
+}
+
+// Unwrap returns the decorated Loader.
+func (w WrappedLoader[K, V]) Unwrap() Loader[K, V] {
+	return w.Loader
+}
+
+// TagInvalidator is an optional interface of a Store that deletes keys by the
+// tags they were written with, see WithTags.
+type TagInvalidator interface {
+	// InvalidateTags deletes every key written with one of the tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// InvalidateTags deletes every key of store written with one of the tags. A
+// store that doesn't implement TagInvalidator fails with an UnsupportedError.
+func InvalidateTags[K comparable, V any](ctx context.Context, store Store[K, V], tags ...string) error {
+	if s, ok := store.(TagInvalidator); ok {
+		return s.InvalidateTags(ctx, tags...)
+	}
+	return &UnsupportedError{Op: "invalidate tags", Reason: "the store doesn't implement cache.TagInvalidator"}
+}
+
+// HashSetter is an optional interface of a Store that takes write options when
+// setting the fields of a hash. The options apply to the whole hash.
+type HashSetter[K comparable, V any] interface {
+	HSetWithOptions(ctx context.Context, key K, keyVals []KeyVal[K, V], opts ...WriteOption) error
+}
+
+// HSetWithOptions sets the fields of a hash with write options, through
+// HashSetter when store implements it. Without options it falls back to
+// Store.HSet; with options, a store that doesn't implement HashSetter fails with
+// an UnsupportedError.
+func HSetWithOptions[K comparable, V any](ctx context.Context, store Store[K, V], key K, keyVals []KeyVal[K, V], opts ...WriteOption) error {
+	if s, ok := store.(HashSetter[K, V]); ok {
+		return s.HSetWithOptions(ctx, key, keyVals, opts...)
+	}
+	if len(opts) > 0 {
+		return &UnsupportedError{Op: "hset with options", Reason: "the store doesn't implement cache.HashSetter"}
+	}
+	return store.HSet(ctx, key, keyVals...)
+}
@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	ttl := time.Minute
+	for range 100 {
+		got := Jitter(ttl, 0.1)
+		assert.GreaterOrEqual(t, got, 54*time.Second)
+		assert.LessOrEqual(t, got, 66*time.Second)
+	}
+
+	assert.Equal(t, ttl, Jitter(ttl, 0))
+	assert.Equal(t, time.Duration(0), Jitter(0, 0.1))
+}
+
+func TestNewWriteOptions(t *testing.T) {
+	o := NewWriteOptions(time.Minute)
+	assert.Equal(t, time.Minute, o.TTL)
+
+	o = NewWriteOptions(time.Minute, WithTTL(time.Hour), WithKeepTTL())
+	assert.Equal(t, time.Hour, o.TTL)
+	assert.True(t, o.KeepTTL)
+	assert.False(t, o.NoExpiry)
+}
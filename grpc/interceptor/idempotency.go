@@ -0,0 +1,92 @@
+package grpcinterceptor
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/trinhdaiphuc/go-kit/errorx"
+	"github.com/trinhdaiphuc/go-kit/idempotency"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// IdempotencyUnaryServerInterceptor replays the responses of the calls carrying
+// an idempotency-key metadata to their retries, with an idempotent-replayed
+// header. A retry arriving while the call runs fails with Aborted, a key reused
+// for another method or request with InvalidArgument. Scope the keys to the
+// caller with idempotency.WithScope.
+func IdempotencyUnaryServerInterceptor(store *idempotency.Store) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		values := metadata.ValueFromIncomingContext(ctx, idempotency.MetadataKey)
+		if len(values) == 0 || values[0] == "" {
+			return handler(ctx, req)
+		}
+		key := values[0]
+
+		var body []byte
+		if msg, ok := req.(proto.Message); ok {
+			body, _ = proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		}
+		fingerprint := idempotency.Fingerprint([]byte(info.FullMethod), body)
+		rec, lease, err := store.Begin(ctx, key, fingerprint)
+		if err != nil {
+			return nil, errorx.ConvertGRPCError(idempotency.Error(err))
+		}
+		if rec != nil {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(idempotency.HeaderReplayed, "true"))
+			return replayCall(rec)
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				if err := store.Release(ctx, lease); err != nil {
+					log.For(ctx).Error("Release idempotency key failed", zap.String("key", key), zap.Error(err))
+				}
+			}
+		}()
+		resp, err := handler(ctx, req)
+		if err := store.Complete(ctx, lease, callRecord(fingerprint, resp, err)); err != nil {
+			log.For(ctx).Error("Store idempotent response failed", zap.String("key", key), zap.Error(err))
+		}
+		completed = true
+		return resp, err
+	}
+}
+
+func callRecord(fingerprint string, resp any, err error) *idempotency.Record {
+	st := status.Convert(err)
+	rec := &idempotency.Record{Fingerprint: fingerprint, Code: st.Code(), Message: st.Message()}
+	if msg, ok := resp.(proto.Message); ok && err == nil {
+		a, marshalErr := anypb.New(msg)
+		if marshalErr == nil {
+			rec.Response, marshalErr = proto.Marshal(a)
+		}
+		if marshalErr != nil {
+			// Not replayed: the key is released.
+			rec.Code = codes.Internal
+		}
+	}
+	return rec
+}
+
+func replayCall(rec *idempotency.Record) (any, error) {
+	if rec.Code != codes.OK {
+		return nil, status.Error(rec.Code, rec.Message)
+	}
+	var a anypb.Any
+	if err := proto.Unmarshal(rec.Response, &a); err != nil {
+		return nil, status.Errorf(codes.Internal, "replay response: %v", err)
+	}
+	resp, err := a.UnmarshalNew()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "replay response: %v", err)
+	}
+	return resp, nil
+}
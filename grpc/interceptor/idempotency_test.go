@@ -0,0 +1,93 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/trinhdaiphuc/go-kit/cache/cachetest"
+	"github.com/trinhdaiphuc/go-kit/idempotency"
+)
+
+func TestIdempotencyUnaryServerInterceptor(t *testing.T) {
+	store := idempotency.NewStore(cachetest.NewFakeStore[string, *idempotency.Record]())
+	interceptor := IdempotencyUnaryServerInterceptor(store)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Call"}
+	var charges atomic.Int32
+
+	call := func(key string, req proto.Message, handler grpc.UnaryHandler) (*headerStream, any, error) {
+		stream := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(idempotency.MetadataKey, key))
+		resp, err := interceptor(ctx, req, info, handler)
+		return stream, resp, err
+	}
+	charge := func(ctx context.Context, req any) (any, error) {
+		n := charges.Add(1)
+		return wrapperspb.String(fmt.Sprintf("charge-%d", n)), nil
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		charges.Store(0)
+		stream, first, err := call("k1", wrapperspb.Int64(10), charge)
+		require.NoError(t, err)
+		assert.Empty(t, stream.header.Get(idempotency.HeaderReplayed))
+
+		stream, retry, err := call("k1", wrapperspb.Int64(10), charge)
+		require.NoError(t, err)
+		assert.True(t, proto.Equal(first.(proto.Message), retry.(proto.Message)), "got %v", retry)
+		assert.Equal(t, []string{"true"}, stream.header.Get(idempotency.HeaderReplayed))
+		assert.Equal(t, int32(1), charges.Load())
+
+		_, _, err = call("k1", wrapperspb.Int64(20), charge)
+		assert.Equal(t, codes.InvalidArgument, status.Code(err), "another request with the key")
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		charges.Store(0)
+		inFlight := make(chan struct{})
+		release := make(chan struct{})
+		slow := func(ctx context.Context, req any) (any, error) {
+			close(inFlight)
+			<-release
+			return charge(ctx, req)
+		}
+
+		done := make(chan error)
+		go func() {
+			_, _, err := call("k2", wrapperspb.Int64(10), slow)
+			done <- err
+		}()
+		<-inFlight
+		_, _, err := call("k2", wrapperspb.Int64(10), charge)
+		assert.Equal(t, codes.Aborted, status.Code(err))
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, int32(1), charges.Load())
+	})
+
+	t.Run("release on a code not stored", func(t *testing.T) {
+		charges.Store(0)
+		unavailable := func(ctx context.Context, req any) (any, error) {
+			charges.Add(1)
+			return nil, status.Error(codes.Unavailable, "try again")
+		}
+
+		_, _, err := call("k3", wrapperspb.Int64(10), unavailable)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		stream, _, err := call("k3", wrapperspb.Int64(10), unavailable)
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Empty(t, stream.header.Get(idempotency.HeaderReplayed))
+		assert.Equal(t, int32(2), charges.Load(), "the retry runs again")
+	})
+}
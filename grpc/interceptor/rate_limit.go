@@ -0,0 +1,108 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"net"
+	"strconv"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+	"github.com/trinhdaiphuc/go-kit/ratelimit"
+)
+
+// RateLimitKeyFunc returns the rate limit key of a call.
+type RateLimitKeyFunc func(ctx context.Context, fullMethod string) string
+
+// RateLimitUnaryServerInterceptor limits the calls under limit by the key
+// returned by keyFunc, the peer IP when nil. A denied call fails with
+// ResourceExhausted carrying QuotaFailure and RetryInfo details. The calls go
+// through when the limiter fails.
+func RateLimitUnaryServerInterceptor(limiter ratelimit.Limiter, limit ratelimit.Limit, keyFunc RateLimitKeyFunc) grpc.UnaryServerInterceptor {
+	if keyFunc == nil {
+		keyFunc = peerKey
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, err := allowCall(ctx, limiter, limit, keyFunc(ctx, info.FullMethod))
+		if md != nil {
+			_ = grpc.SetHeader(ctx, md)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamServerInterceptor is RateLimitUnaryServerInterceptor for the
+// streams, counting a stream as one call.
+func RateLimitStreamServerInterceptor(limiter ratelimit.Limiter, limit ratelimit.Limit, keyFunc RateLimitKeyFunc) grpc.StreamServerInterceptor {
+	if keyFunc == nil {
+		keyFunc = peerKey
+	}
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, err := allowCall(ss.Context(), limiter, limit, keyFunc(ss.Context(), info.FullMethod))
+		if md != nil {
+			_ = ss.SetHeader(md)
+		}
+		if err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// allowCall returns the rate limit headers of the call, and the error of a
+// denied one.
+func allowCall(ctx context.Context, limiter ratelimit.Limiter, limit ratelimit.Limit, key string) (metadata.MD, error) {
+	res, err := ratelimit.Allow(ctx, limiter, key, limit)
+	if err != nil {
+		log.For(ctx).Warn("Rate limit call failed", zap.String("key", key), zap.Error(err))
+		return nil, nil
+	}
+
+	md := metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(res.Limit.Rate),
+		"ratelimit-remaining", strconv.Itoa(res.Remaining),
+	)
+	if res.Allowed {
+		return md, nil
+	}
+	return md, rateLimitStatus(key, res).Err()
+}
+
+func rateLimitStatus(key string, res *ratelimit.Result) *status.Status {
+	errWrapper := ratelimit.Error(key, res)
+	st := status.New(errWrapper.GetStatus(), errWrapper.GetMessage())
+
+	quota := &errdetails.QuotaFailure{}
+	for _, v := range errWrapper.ErrorBody.Details.QuotaFailure.Violations {
+		quota.Violations = append(quota.Violations, &errdetails.QuotaFailure_Violation{
+			Subject:     v.Subject,
+			Description: v.Description,
+		})
+	}
+	withDetails, err := st.WithDetails(quota, &errdetails.RetryInfo{RetryDelay: durationpb.New(res.RetryAfter)})
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+func peerKey(ctx context.Context, _ string) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,106 @@
+package grpcinterceptor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/trinhdaiphuc/go-kit/ratelimit"
+)
+
+// stubLimiter returns res or err, and records the keys it was called with.
+type stubLimiter struct {
+	res  *ratelimit.Result
+	err  error
+	keys []string
+}
+
+func (l *stubLimiter) AllowN(ctx context.Context, key string, limit ratelimit.Limit, n int) (*ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	return l.res, l.err
+}
+
+// headerStream records the headers set by the interceptors.
+type headerStream struct {
+	grpc.ServerTransportStream
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string {
+	return "/test.Service/Call"
+}
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestRateLimitUnaryServerInterceptor(t *testing.T) {
+	limit := ratelimit.PerMinute(60)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Call"}
+	call := func(limiter ratelimit.Limiter, keyFunc RateLimitKeyFunc) (*headerStream, bool, error) {
+		stream := &headerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1234}})
+		handled := false
+		_, err := RateLimitUnaryServerInterceptor(limiter, limit, keyFunc)(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			handled = true
+			return "ok", nil
+		})
+		return stream, handled, err
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		limiter := &stubLimiter{res: &ratelimit.Result{Limit: limit, Allowed: true, Remaining: 59}}
+		stream, handled, err := call(limiter, nil)
+		assert.NoError(t, err)
+		assert.True(t, handled)
+		assert.Equal(t, []string{"10.0.0.1"}, limiter.keys, "the peer IP by default")
+		assert.Equal(t, []string{"60"}, stream.header.Get("ratelimit-limit"))
+		assert.Equal(t, []string{"59"}, stream.header.Get("ratelimit-remaining"))
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		limiter := &stubLimiter{res: &ratelimit.Result{Limit: limit, RetryAfter: 1500 * time.Millisecond}}
+		stream, handled, err := call(limiter, func(ctx context.Context, fullMethod string) string { return "user:1" + fullMethod })
+		assert.False(t, handled)
+		assert.Equal(t, []string{"user:1/test.Service/Call"}, limiter.keys)
+		assert.Equal(t, []string{"0"}, stream.header.Get("ratelimit-remaining"))
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+		var quota *errdetails.QuotaFailure
+		var retry *errdetails.RetryInfo
+		for _, detail := range st.Details() {
+			switch d := detail.(type) {
+			case *errdetails.QuotaFailure:
+				quota = d
+			case *errdetails.RetryInfo:
+				retry = d
+			}
+		}
+		require.NotNil(t, quota)
+		require.Len(t, quota.Violations, 1)
+		assert.Equal(t, "user:1/test.Service/Call", quota.Violations[0].Subject)
+		require.NotNil(t, retry)
+		assert.Equal(t, 1500*time.Millisecond, retry.RetryDelay.AsDuration())
+	})
+
+	t.Run("fails open", func(t *testing.T) {
+		stream, handled, err := call(&stubLimiter{err: errors.New("connection refused")}, nil)
+		assert.NoError(t, err)
+		assert.True(t, handled)
+		assert.Empty(t, stream.header)
+	})
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/trinhdaiphuc/go-kit/idempotency"
+)
+
+// GinIdempotency is Idempotency for gin.
+func GinIdempotency(store *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotency.HeaderKey)
+		if key == "" || isSafeMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		fingerprint, rec, lease, err := beginRequest(c, c.Request, store, key)
+		if err != nil {
+			errWrapper := idempotency.Error(err)
+			c.AbortWithStatusJSON(errWrapper.GetCode(), errWrapper)
+			return
+		}
+		if rec != nil {
+			replayResponse(c.Writer, rec)
+			c.Abort()
+			return
+		}
+
+		w := &bodyRecordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		completed := false
+		defer func() {
+			if !completed {
+				releaseKey(c, store, lease)
+			}
+		}()
+		c.Next()
+		completeRequest(c, store, lease, &idempotency.Record{
+			Fingerprint: fingerprint,
+			StatusCode:  w.Status(),
+			Header:      recordedHeader(w.Header()),
+			Body:        w.body.Bytes(),
+		})
+		completed = true
+	}
+}
+
+type bodyRecordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache/cachetest"
+	"github.com/trinhdaiphuc/go-kit/idempotency"
+)
+
+func TestGinIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := idempotency.NewStore(cachetest.NewFakeStore[string, *idempotency.Record]())
+	var charges atomic.Int32
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+
+	router := gin.New()
+	router.Use(GinIdempotency(store))
+	router.POST("/charges", func(c *gin.Context) {
+		charges.Add(1)
+		c.JSON(http.StatusCreated, gin.H{"charge": 1})
+	})
+	router.POST("/slow", func(c *gin.Context) {
+		charges.Add(1)
+		close(inFlight)
+		<-release
+		c.Status(http.StatusCreated)
+	})
+	router.POST("/unavailable", func(c *gin.Context) {
+		charges.Add(1)
+		c.Status(http.StatusServiceUnavailable)
+	})
+
+	serve := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(idempotency.HeaderKey, key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		charges.Store(0)
+		first := serve("/charges", "k1", `{"amount":10}`)
+		assert.Equal(t, http.StatusCreated, first.Code)
+		assert.Empty(t, first.Header().Get(idempotency.HeaderReplayed))
+
+		retry := serve("/charges", "k1", `{"amount":10}`)
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, "application/json; charset=utf-8", retry.Header().Get("Content-Type"))
+		assert.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+		assert.Equal(t, int32(1), charges.Load())
+
+		assert.Equal(t, http.StatusUnprocessableEntity, serve("/charges", "k1", `{"amount":20}`).Code, "another request with the key")
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		charges.Store(0)
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			done <- serve("/slow", "k2", "")
+		}()
+		<-inFlight
+		assert.Equal(t, http.StatusConflict, serve("/slow", "k2", "").Code)
+		close(release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+		assert.Equal(t, int32(1), charges.Load())
+	})
+
+	t.Run("release on a status not stored", func(t *testing.T) {
+		charges.Store(0)
+		assert.Equal(t, http.StatusServiceUnavailable, serve("/unavailable", "k3", "").Code)
+		retry := serve("/unavailable", "k3", "")
+		assert.Equal(t, http.StatusServiceUnavailable, retry.Code)
+		assert.Empty(t, retry.Header().Get(idempotency.HeaderReplayed))
+		assert.Equal(t, int32(2), charges.Load(), "the retry runs again")
+	})
+}
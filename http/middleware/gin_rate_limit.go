@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+	"github.com/trinhdaiphuc/go-kit/ratelimit"
+)
+
+// GinRateLimit limits the requests under limit by the key returned by keyFunc,
+// the client IP when nil. The responses carry the rate limit headers; a denied
+// request is aborted with 429 Too Many Requests and a RESOURCE_EXHAUSTED error
+// body. The requests go through when the limiter fails.
+func GinRateLimit(limiter ratelimit.Limiter, limit ratelimit.Limit, keyFunc func(*gin.Context) string) gin.HandlerFunc {
+	if keyFunc == nil {
+		keyFunc = (*gin.Context).ClientIP
+	}
+	return func(c *gin.Context) {
+		key := keyFunc(c)
+		res, err := ratelimit.Allow(c, limiter, key, limit)
+		if err != nil {
+			log.For(c).Warn("Rate limit request failed", zap.String("key", key), zap.Error(err))
+			c.Next()
+			return
+		}
+
+		ratelimit.SetHeaders(c.Writer.Header(), res)
+		if !res.Allowed {
+			err := ratelimit.Error(key, res)
+			c.AbortWithStatusJSON(err.GetCode(), err)
+			return
+		}
+		c.Next()
+	}
+}
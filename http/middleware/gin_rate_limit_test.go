@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trinhdaiphuc/go-kit/errorx"
+	"github.com/trinhdaiphuc/go-kit/ratelimit"
+)
+
+// stubLimiter returns res or err, and records the keys it was called with.
+type stubLimiter struct {
+	res  *ratelimit.Result
+	err  error
+	keys []string
+}
+
+func (l *stubLimiter) AllowN(ctx context.Context, key string, limit ratelimit.Limit, n int) (*ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	return l.res, l.err
+}
+
+func TestGinRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit := ratelimit.PerMinute(60)
+	serve := func(limiter ratelimit.Limiter, keyFunc func(*gin.Context) string) (*httptest.ResponseRecorder, bool) {
+		handled := false
+		router := gin.New()
+		router.Use(GinRateLimit(limiter, limit, keyFunc))
+		router.POST("/charges", func(c *gin.Context) {
+			handled = true
+			c.Status(http.StatusCreated)
+		})
+		req := httptest.NewRequest(http.MethodPost, "/charges", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec, handled
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		limiter := &stubLimiter{res: &ratelimit.Result{Limit: limit, Allowed: true, Remaining: 59, ResetAfter: time.Second}}
+		rec, handled := serve(limiter, nil)
+		assert.True(t, handled)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Equal(t, []string{"10.0.0.1"}, limiter.keys, "the client IP by default")
+		assert.Equal(t, "60", rec.Header().Get(ratelimit.HeaderLimit))
+		assert.Equal(t, "59", rec.Header().Get(ratelimit.HeaderRemaining))
+		assert.Equal(t, "1", rec.Header().Get(ratelimit.HeaderReset))
+		assert.Equal(t, "60;w=60", rec.Header().Get(ratelimit.HeaderPolicy))
+		assert.Empty(t, rec.Header().Get(ratelimit.HeaderRetryAfter))
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		limiter := &stubLimiter{res: &ratelimit.Result{Limit: limit, RetryAfter: 1500 * time.Millisecond, ResetAfter: time.Minute}}
+		rec, handled := serve(limiter, func(c *gin.Context) string { return "user:1" })
+		assert.False(t, handled)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, []string{"user:1"}, limiter.keys)
+		assert.Equal(t, "0", rec.Header().Get(ratelimit.HeaderRemaining))
+		assert.Equal(t, "2", rec.Header().Get(ratelimit.HeaderRetryAfter))
+
+		var body errorx.ErrorWrapper
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, http.StatusTooManyRequests, body.ErrorBody.Code)
+		assert.Equal(t, "RESOURCE_EXHAUSTED", body.ErrorBody.Status)
+		require.NotNil(t, body.ErrorBody.Details)
+		require.NotNil(t, body.ErrorBody.Details.QuotaFailure)
+		require.Len(t, body.ErrorBody.Details.QuotaFailure.Violations, 1)
+		assert.Equal(t, "user:1", body.ErrorBody.Details.QuotaFailure.Violations[0].Subject)
+	})
+
+	t.Run("fails open", func(t *testing.T) {
+		rec, handled := serve(&stubLimiter{err: errors.New("connection refused")}, nil)
+		assert.True(t, handled)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get(ratelimit.HeaderLimit))
+	})
+}
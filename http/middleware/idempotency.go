@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/errorx"
+	"github.com/trinhdaiphuc/go-kit/idempotency"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// Idempotency replays the responses of the requests carrying an
+// Idempotency-Key header to their retries. The safe methods (GET, HEAD,
+// OPTIONS) aren't concerned. A retry arriving while the request runs gets 409
+// Conflict, a key reused for another method, path or body 422 Unprocessable
+// Entity. The Set-Cookie headers are neither stored nor replayed. Scope the
+// keys to the caller with idempotency.WithScope.
+func Idempotency(store *idempotency.Store) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotency.HeaderKey)
+			if key == "" || isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			fingerprint, rec, lease, err := beginRequest(r.Context(), r, store, key)
+			if err != nil {
+				writeErrorJSON(w, idempotency.Error(err))
+				return
+			}
+			if rec != nil {
+				replayResponse(w, rec)
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			completed := false
+			defer func() {
+				if !completed {
+					releaseKey(r.Context(), store, lease)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+			completeRequest(r.Context(), store, lease, &idempotency.Record{
+				Fingerprint: fingerprint,
+				StatusCode:  rw.status,
+				Header:      recordedHeader(w.Header()),
+				Body:        rw.body.Bytes(),
+			})
+			completed = true
+		})
+	}
+}
+
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// beginRequest reserves key for the request, fingerprinted by its method, path
+// and body, in the scope of the caller of ctx. The body is read, and replaced
+// for the handler.
+func beginRequest(ctx context.Context, r *http.Request, store *idempotency.Store, key string) (string, *idempotency.Record, *idempotency.Lease, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	fingerprint := idempotency.Fingerprint([]byte(r.Method), []byte(r.URL.Path), body)
+	rec, lease, err := store.Begin(ctx, key, fingerprint)
+	return fingerprint, rec, lease, err
+}
+
+// recordedHeader is the header of a response to store, without the cookies set
+// for the caller of the request.
+func recordedHeader(header http.Header) http.Header {
+	recorded := header.Clone()
+	recorded.Del("Set-Cookie")
+	return recorded
+}
+
+func replayResponse(w http.ResponseWriter, rec *idempotency.Record) {
+	for name, values := range rec.Header {
+		if http.CanonicalHeaderKey(name) != "Set-Cookie" {
+			w.Header()[name] = values
+		}
+	}
+	w.Header().Set(idempotency.HeaderReplayed, "true")
+	w.WriteHeader(rec.StatusCode)
+	_, _ = w.Write(rec.Body)
+}
+
+func completeRequest(ctx context.Context, store *idempotency.Store, lease *idempotency.Lease, rec *idempotency.Record) {
+	if err := store.Complete(ctx, lease, rec); err != nil {
+		log.For(ctx).Error("Store idempotent response failed", zap.String("key", lease.Key), zap.Error(err))
+	}
+}
+
+func releaseKey(ctx context.Context, store *idempotency.Store, lease *idempotency.Lease) {
+	if err := store.Release(ctx, lease); err != nil {
+		log.For(ctx).Error("Release idempotency key failed", zap.String("key", lease.Key), zap.Error(err))
+	}
+}
+
+func writeErrorJSON(w http.ResponseWriter, err *errorx.ErrorWrapper) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.GetCode())
+	_ = json.NewEncoder(w).Encode(err)
+}
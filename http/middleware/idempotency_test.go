@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/trinhdaiphuc/go-kit/cache/cachetest"
+	"github.com/trinhdaiphuc/go-kit/idempotency"
+)
+
+func TestIdempotency(t *testing.T) {
+	store := idempotency.NewStore(cachetest.NewFakeStore[string, *idempotency.Record]())
+	charges := 0
+	inFlight := make(chan struct{})
+	release := make(chan struct{})
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		charges++
+		if r.URL.Path == "/slow" {
+			close(inFlight)
+			<-release
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"charge":1}`))
+	}))
+
+	serve := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotency.HeaderKey, key)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := serve(http.MethodPost, "/charges", "k1", `{"amount":10}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	retry := serve(http.MethodPost, "/charges", "k1", `{"amount":10}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, `{"charge":1}`, retry.Body.String())
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+	assert.Equal(t, 1, charges)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, "/charges", "k1", `{"amount":20}`).Code)
+
+	// Without a key, or with a safe method, the requests always run.
+	serve(http.MethodPost, "/charges", "", `{"amount":10}`)
+	serve(http.MethodGet, "/charges", "k1", "")
+	assert.Equal(t, 3, charges)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- serve(http.MethodPost, "/slow", "k2", "")
+	}()
+	<-inFlight
+	assert.Equal(t, http.StatusConflict, serve(http.MethodPost, "/slow", "k2", "").Code)
+	close(release)
+	assert.Equal(t, http.StatusCreated, (<-done).Code)
+}
+
+type callerKey struct{}
+
+func TestIdempotency_Scope(t *testing.T) {
+	store := idempotency.NewStore(cachetest.NewFakeStore[string, *idempotency.Record](),
+		idempotency.WithScope(func(ctx context.Context) string {
+			caller, _ := ctx.Value(callerKey{}).(string)
+			return caller
+		}))
+	charges := 0
+	handler := Idempotency(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		charges++
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.Context().Value(callerKey{}).(string)})
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	serve := func(caller string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/charges", strings.NewReader(`{"amount":10}`))
+		req.Header.Set(idempotency.HeaderKey, "k1")
+		req = req.WithContext(context.WithValue(req.Context(), callerKey{}, caller))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.NotEmpty(t, serve("alice").Header().Get("Set-Cookie"))
+	assert.Empty(t, serve("bob").Header().Get(idempotency.HeaderReplayed))
+	assert.Equal(t, 2, charges)
+
+	retry := serve("alice")
+	assert.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+	assert.Empty(t, retry.Header().Get("Set-Cookie"))
+	assert.Equal(t, 2, charges)
+}
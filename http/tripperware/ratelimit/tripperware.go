@@ -0,0 +1,41 @@
+package tripperwareratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	httptripperware "github.com/trinhdaiphuc/go-kit/http/tripperware"
+	"github.com/trinhdaiphuc/go-kit/log"
+	"github.com/trinhdaiphuc/go-kit/ratelimit"
+)
+
+// Tripperware is client side HTTP ware that keeps the requests under limit, by
+// the key returned by keyFunc, the host of the request when nil.
+//
+// A request waits until it is allowed. It fails with ratelimit.ErrLimited when
+// it wouldn't be allowed before the deadline of its context. The requests go
+// through when the limiter fails.
+func Tripperware(limiter ratelimit.Limiter, limit ratelimit.Limit, keyFunc func(*http.Request) string) httptripperware.Tripperware {
+	if keyFunc == nil {
+		keyFunc = func(req *http.Request) string {
+			return req.URL.Host
+		}
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httptripperware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			key := keyFunc(req)
+			_, err := ratelimit.Wait(req.Context(), limiter, key, limit)
+			switch {
+			case err == nil:
+			case errors.Is(err, ratelimit.ErrLimited):
+				return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL.Redacted(), err)
+			default:
+				log.For(req.Context()).Warn("Rate limit request failed", zap.String("key", key), zap.Error(err))
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
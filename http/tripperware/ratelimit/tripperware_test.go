@@ -0,0 +1,40 @@
+package tripperwareratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	httptripperware "github.com/trinhdaiphuc/go-kit/http/tripperware"
+	"github.com/trinhdaiphuc/go-kit/ratelimit"
+)
+
+func TestTripperware(t *testing.T) {
+	calls := 0
+	rt := Tripperware(ratelimit.NewLocal(), ratelimit.PerHour(1), nil)(
+		httptripperware.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return &http.Response{StatusCode: http.StatusOK}, nil
+		}),
+	)
+
+	resp, err := rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://partner/a", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The next request of the host wouldn't be allowed in time.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodGet, "http://partner/b", nil)
+	_, err = rt.RoundTrip(req)
+	assert.ErrorIs(t, err, ratelimit.ErrLimited)
+
+	// The other hosts have their own limit.
+	_, err = rt.RoundTrip(httptest.NewRequest(http.MethodGet, "http://other/a", nil))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
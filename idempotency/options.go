@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+type Options struct {
+	// Lease is how long a key stays reserved by a request in flight.
+	Lease time.Duration
+	// TTL is how long the response of a key is replayed.
+	TTL time.Duration
+	// StoreStatus reports whether an HTTP response of the status is replayed.
+	// The others release the key, so that a retry runs again.
+	StoreStatus func(status int) bool
+	// StoreCode is StoreStatus for the gRPC responses.
+	StoreCode func(code codes.Code) bool
+	// Scope returns the caller of a request, see WithScope.
+	Scope func(ctx context.Context) string
+}
+
+type Option func(*Options)
+
+// WithLease sets how long a request in flight holds its key, 1 minute by
+// default. A retry arriving after the lease runs again: the lease should
+// outlast the slowest request.
+func WithLease(lease time.Duration) Option {
+	return func(o *Options) {
+		o.Lease = lease
+	}
+}
+
+// WithTTL sets how long the responses are replayed, 24 hours by default.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// WithStoreStatus sets which HTTP responses are replayed, by default the ones
+// under 500 but 408, 409 and 429.
+func WithStoreStatus(f func(status int) bool) Option {
+	return func(o *Options) {
+		o.StoreStatus = f
+	}
+}
+
+// WithStoreCode sets which gRPC responses are replayed, by default the
+// successes and the errors a retry would get again, see DefaultStoreCode.
+func WithStoreCode(f func(code codes.Code) bool) Option {
+	return func(o *Options) {
+		o.StoreCode = f
+	}
+}
+
+// WithScope scopes the keys to the caller returned by scope, e.g. the subject
+// of the authenticated user, so that two callers sending the same key don't
+// get each other's responses. Without it the keys are global. scope is given
+// the context of the request; the gin middleware passes the *gin.Context.
+func WithScope(scope func(ctx context.Context) string) Option {
+	return func(o *Options) {
+		o.Scope = scope
+	}
+}
+
+func DefaultStoreStatus(status int) bool {
+	switch status {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return false
+	}
+	return status < http.StatusInternalServerError
+}
+
+func DefaultStoreCode(code codes.Code) bool {
+	switch code {
+	case codes.OK, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.PermissionDenied,
+		codes.FailedPrecondition, codes.OutOfRange, codes.Unimplemented, codes.Unauthenticated:
+		return true
+	}
+	return false
+}
+
+func newDefaultOptions() *Options {
+	return &Options{
+		Lease:       time.Minute,
+		TTL:         24 * time.Hour,
+		StoreStatus: DefaultStoreStatus,
+		StoreCode:   DefaultStoreCode,
+	}
+}
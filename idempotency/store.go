@@ -0,0 +1,233 @@
+// Package idempotency replays the response of a request to its retries. The
+// client sends a unique key with the request; the first request with the key
+// runs, and the retries get its response instead of running again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/errorx"
+)
+
+const (
+	// HeaderKey is the HTTP header carrying the idempotency key.
+	HeaderKey = "Idempotency-Key"
+	// MetadataKey is the gRPC metadata carrying the idempotency key.
+	MetadataKey = "idempotency-key"
+	// HeaderReplayed is set on the replayed HTTP responses.
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+var (
+	// ErrInFlight is returned by Begin while the request holding the key runs.
+	ErrInFlight = errors.New("idempotency: request in flight")
+	// ErrKeyMismatch is returned by Begin for a key already used by another
+	// request.
+	ErrKeyMismatch = errors.New("idempotency: key used by another request")
+	// ErrLeaseLost is returned by Complete and Release when the request no
+	// longer holds the key: its lease expired, and the key was freed or
+	// reserved by a retry. The key is left as it is.
+	ErrLeaseLost = errors.New("idempotency: lease of the key lost")
+)
+
+// Record is what the Store keeps for a key.
+type Record struct {
+	// Done is false while the request runs.
+	Done bool `json:"done"`
+	// Owner is a random token of the request holding the key while it runs.
+	Owner string `json:"owner,omitempty"`
+	// Fingerprint identifies the request, see Fingerprint.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// StatusCode, Header and Body are the HTTP response.
+	StatusCode int         `json:"status_code,omitempty"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+	// Code and Message are the gRPC status, Response the marshaled anypb.Any
+	// of the gRPC response.
+	Code     codes.Code `json:"code,omitempty"`
+	Message  string     `json:"message,omitempty"`
+	Response []byte     `json:"response,omitempty"`
+}
+
+// Lease is the reservation of a key by the request that runs. Begin returns
+// it, Complete or Release ends it.
+type Lease struct {
+	Key string
+	// reservation is the Record written by Begin.
+	reservation *Record
+}
+
+// Store keeps the idempotency keys in a cache.Store, e.g. a Redis store shared
+// by the replicas. Its SetNX must be atomic, as the stores of the kit are: two
+// requests reserving a key together would both run otherwise.
+type Store struct {
+	store cache.Store[string, *Record]
+	opts  *Options
+}
+
+func NewStore(store cache.Store[string, *Record], opts ...Option) *Store {
+	options := newDefaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	return &Store{store: store, opts: options}
+}
+
+// Begin reserves key for the request with the fingerprint for the lease. It
+// returns the Lease when the request holds the key and should run, and the
+// stored Record when it should be replayed. It returns ErrInFlight while
+// another request holds the key, and ErrKeyMismatch when the key was used by a
+// request with another fingerprint. The key is scoped to the caller, see
+// WithScope.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Record, *Lease, error) {
+	key = s.scopedKey(ctx, key)
+	reservation := &Record{Fingerprint: fingerprint, Owner: uuid.New().String()}
+	// The record may expire between SetNX and Get, try again once.
+	for range 2 {
+		ok, err := s.store.SetNX(ctx, key, reservation, cache.WithTTL(s.opts.Lease))
+		if err != nil {
+			return nil, nil, fmt.Errorf("idempotency: reserve key: %w", err)
+		}
+		if ok {
+			return nil, &Lease{Key: key, reservation: reservation}, nil
+		}
+
+		rec, err := s.store.Get(ctx, key)
+		if errors.Is(err, cache.ErrorKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("idempotency: get key: %w", err)
+		}
+		if rec.Fingerprint != "" && fingerprint != "" && rec.Fingerprint != fingerprint {
+			return nil, nil, ErrKeyMismatch
+		}
+		if !rec.Done {
+			return nil, nil, ErrInFlight
+		}
+		return rec, nil, nil
+	}
+	return nil, nil, ErrInFlight
+}
+
+// Complete stores the response of the request holding lease, or releases the
+// key when the response isn't replayed, see WithStoreStatus and WithStoreCode.
+// rec is stored with the fingerprint given to Begin. A Record without
+// StatusCode is a gRPC response. It returns ErrLeaseLost, and leaves the key
+// alone, when the lease expired.
+func (s *Store) Complete(ctx context.Context, lease *Lease, rec *Record) error {
+	stored := s.opts.StoreCode(rec.Code)
+	if rec.StatusCode != 0 {
+		stored = s.opts.StoreStatus(rec.StatusCode)
+	}
+	if !stored {
+		return s.Release(ctx, lease)
+	}
+
+	rec.Done = true
+	rec.Fingerprint = lease.reservation.Fingerprint
+	if err := s.settle(ctx, lease, rec); err != nil {
+		return fmt.Errorf("idempotency: store response: %w", err)
+	}
+	return nil
+}
+
+// Release frees the key of lease, so that a retry runs again. It returns
+// ErrLeaseLost, and leaves the key alone, when the lease expired.
+func (s *Store) Release(ctx context.Context, lease *Lease) error {
+	if err := s.settle(ctx, lease, nil); err != nil {
+		return fmt.Errorf("idempotency: release key: %w", err)
+	}
+	return nil
+}
+
+// settle replaces the reservation of lease with rec, or deletes it for a nil
+// rec, as long as the key holds it. The check is atomic on a store
+// implementing cache.CompareAndSwapper, e.g. the Redis one. On the others, a
+// retry reserving the key between the check and the write loses its
+// reservation.
+func (s *Store) settle(ctx context.Context, lease *Lease, rec *Record) error {
+	var ok bool
+	var err error
+	if rec != nil {
+		ok, err = cache.CompareAndSet(ctx, s.store, lease.Key, lease.reservation, rec, cache.WithTTL(s.opts.TTL))
+	} else {
+		ok, err = cache.CompareAndDelete(ctx, s.store, lease.Key, lease.reservation)
+	}
+	if errors.Is(err, errors.ErrUnsupported) {
+		ok, err = s.settleUnchecked(ctx, lease, rec)
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// settleUnchecked is settle with a Get then a write.
+func (s *Store) settleUnchecked(ctx context.Context, lease *Lease, rec *Record) (bool, error) {
+	current, err := s.store.Get(cache.WithoutLoader(ctx), lease.Key)
+	if errors.Is(err, cache.ErrorKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if current.Done || current.Owner != lease.reservation.Owner {
+		return false, nil
+	}
+	if rec != nil {
+		return true, s.store.Set(ctx, lease.Key, rec, cache.WithTTL(s.opts.TTL))
+	}
+	return true, s.store.Delete(ctx, lease.Key)
+}
+
+// scopedKey prefixes key with the caller scope and its length, so that the
+// caller can't pick a key overlapping the one of another scope.
+func (s *Store) scopedKey(ctx context.Context, key string) string {
+	if s.opts.Scope == nil {
+		return key
+	}
+	scope := s.opts.Scope(ctx)
+	return strconv.Itoa(len(scope)) + ":" + scope + ":" + key
+}
+
+// Fingerprint hashes the parts of a request that a retry repeats, e.g. the
+// method, the path and the body.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		// The length keeps the parts apart.
+		_, _ = fmt.Fprintf(h, "%d:", len(part))
+		_, _ = h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Error returns the error response of a Begin error: ABORTED (409) for
+// ErrInFlight, INVALID_ARGUMENT (422 over HTTP) for ErrKeyMismatch and
+// UNAVAILABLE otherwise.
+func Error(err error) *errorx.ErrorWrapper {
+	switch {
+	case errors.Is(err, ErrInFlight):
+		return errorx.New("A request with the same idempotency key is in progress").WithCodeFromStatus(codes.Aborted)
+	case errors.Is(err, ErrKeyMismatch):
+		return errorx.New("The idempotency key was used by another request").
+			WithCodeFromStatus(codes.InvalidArgument).
+			WithCode(http.StatusUnprocessableEntity)
+	default:
+		return errorx.Wrap(err, "Idempotency key store unavailable").WithCodeFromStatus(codes.Unavailable)
+	}
+}
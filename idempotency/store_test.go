@@ -0,0 +1,148 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/cache/cachetest"
+)
+
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	fake := cachetest.NewFakeStore[string, *Record](cachetest.WithClock[string, *Record](now))
+	store := NewStore(fake, WithLease(time.Second), WithTTL(time.Hour))
+
+	rec, lease, err := store.Begin(ctx, "charge-1", "a")
+	assert.NoError(t, err)
+	assert.Nil(t, rec)
+	assert.Equal(t, "charge-1", lease.Key)
+
+	_, _, err = store.Begin(ctx, "charge-1", "a")
+	assert.ErrorIs(t, err, ErrInFlight)
+	_, _, err = store.Begin(ctx, "charge-1", "b")
+	assert.ErrorIs(t, err, ErrKeyMismatch)
+
+	assert.NoError(t, store.Complete(ctx, lease, &Record{StatusCode: http.StatusCreated, Body: []byte(`{"id":1}`)}))
+	rec, _, err = store.Begin(ctx, "charge-1", "a")
+	assert.NoError(t, err)
+	assert.Equal(t, &Record{Done: true, Fingerprint: "a", StatusCode: http.StatusCreated, Body: []byte(`{"id":1}`)}, rec)
+	_, _, err = store.Begin(ctx, "charge-1", "b")
+	assert.ErrorIs(t, err, ErrKeyMismatch)
+
+	// The responses are replayed for the TTL.
+	now.now = now.now.Add(time.Hour)
+	rec, lease, err = store.Begin(ctx, "charge-1", "a")
+	assert.NoError(t, err)
+	assert.Nil(t, rec)
+
+	// An expired lease lets a retry run.
+	now.now = now.now.Add(time.Second)
+	rec, lease, err = store.Begin(ctx, "charge-1", "a")
+	assert.NoError(t, err)
+	assert.Nil(t, rec)
+
+	// The responses a retry could change release the key.
+	assert.NoError(t, store.Complete(ctx, lease, &Record{StatusCode: http.StatusServiceUnavailable}))
+	assert.Equal(t, 0, fake.Len())
+	_, lease, err = store.Begin(ctx, "call-1", "")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Complete(ctx, lease, &Record{Code: codes.Unavailable}))
+	assert.Equal(t, 0, fake.Len())
+	_, lease, err = store.Begin(ctx, "call-1", "")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Complete(ctx, lease, &Record{Code: codes.NotFound, Message: "no account"}))
+	assert.Equal(t, 1, fake.Len())
+}
+
+// plainStore hides the cache.CompareAndSwapper of the fake store.
+type plainStore struct {
+	cache.Store[string, *Record]
+}
+
+func TestStore_LeaseLost(t *testing.T) {
+	ctx := context.Background()
+	stores := map[string]func(cache.Store[string, *Record]) cache.Store[string, *Record]{
+		"compare and swap": func(s cache.Store[string, *Record]) cache.Store[string, *Record] { return s },
+		"get then write":   func(s cache.Store[string, *Record]) cache.Store[string, *Record] { return plainStore{s} },
+	}
+	for name, wrap := range stores {
+		t.Run(name, func(t *testing.T) {
+			now := &manualClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+			fake := cachetest.NewFakeStore[string, *Record](cachetest.WithClock[string, *Record](now))
+			store := NewStore(wrap(fake), WithLease(time.Second), WithTTL(time.Hour))
+
+			_, slow, err := store.Begin(ctx, "charge-1", "a")
+			assert.NoError(t, err)
+
+			// The lease of the slow request expires, and a retry takes the key.
+			now.now = now.now.Add(time.Second)
+			_, retry, err := store.Begin(ctx, "charge-1", "a")
+			assert.NoError(t, err)
+
+			assert.ErrorIs(t, store.Release(ctx, slow), ErrLeaseLost)
+			assert.ErrorIs(t, store.Complete(ctx, slow, &Record{StatusCode: http.StatusCreated}), ErrLeaseLost)
+			_, _, err = store.Begin(ctx, "charge-1", "a")
+			assert.ErrorIs(t, err, ErrInFlight, "the retry still holds the key")
+
+			assert.NoError(t, store.Complete(ctx, retry, &Record{StatusCode: http.StatusCreated, Body: []byte("retry")}))
+			assert.ErrorIs(t, store.Release(ctx, slow), ErrLeaseLost)
+			rec, _, err := store.Begin(ctx, "charge-1", "a")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("retry"), rec.Body)
+
+			// A lease whose key expired is lost too.
+			_, lease, err := store.Begin(ctx, "charge-2", "a")
+			assert.NoError(t, err)
+			now.now = now.now.Add(time.Second)
+			assert.ErrorIs(t, store.Release(ctx, lease), ErrLeaseLost)
+		})
+	}
+}
+
+func TestError(t *testing.T) {
+	assert.Equal(t, http.StatusConflict, Error(ErrInFlight).GetCode())
+	assert.Equal(t, http.StatusUnprocessableEntity, Error(ErrKeyMismatch).GetCode())
+	assert.Equal(t, codes.InvalidArgument, Error(ErrKeyMismatch).GetStatus())
+	assert.Equal(t, codes.Unavailable, Error(errors.New("connection refused")).GetStatus())
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint([]byte("POST"), []byte("/a")), Fingerprint([]byte("POST"), []byte("/a")))
+	assert.NotEqual(t, Fingerprint([]byte("POST"), []byte("/a")), Fingerprint([]byte("POST/"), []byte("a")))
+}
+
+func TestStore_Scope(t *testing.T) {
+	ctx := context.Background()
+	fake := cachetest.NewFakeStore[string, *Record]()
+	store := NewStore(fake, WithScope(func(ctx context.Context) string {
+		caller, _ := ctx.Value(callerKey{}).(string)
+		return caller
+	}))
+
+	_, lease, err := store.Begin(context.WithValue(ctx, callerKey{}, "a:b"), "c", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, "3:a:b:c", lease.Key)
+
+	// Another caller can't reach the key by moving the separator.
+	_, lease, err = store.Begin(context.WithValue(ctx, callerKey{}, "a"), "b:c", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, "1:a:b:c", lease.Key)
+}
+
+type callerKey struct{}
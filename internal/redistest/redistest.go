@@ -0,0 +1,67 @@
+// Package redistest starts a throwaway Redis server for the tests that need a
+// real one, e.g. to run Lua scripts or pub/sub. The tests are skipped when
+// Docker isn't available.
+package redistest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Image is the Redis image the tests run against.
+const Image = "redis:7"
+
+// Addr starts a Redis container for the test and returns its address. The
+// container is removed when the test ends.
+func Addr(t *testing.T) string {
+	t.Helper()
+	skipWithoutDocker(t)
+
+	ctx := context.Background()
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        Image,
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor: wait.NewExecStrategy(
+				[]string{"redis-cli", "-h", "localhost", "-p", "6379", "ping"},
+			),
+		},
+		Started: true,
+	})
+	testcontainers.CleanupContainer(t, container)
+	require.NoError(t, err)
+
+	endpoint, err := container.Endpoint(ctx, "")
+	require.NoError(t, err)
+	return endpoint
+}
+
+// NewClient starts a Redis container for the test and returns a client of it,
+// closed when the test ends.
+func NewClient(t *testing.T) *redis.Client {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: Addr(t)})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	return client
+}
+
+// skipWithoutDocker skips the test when there is no Docker to run Redis in. The
+// provider lookup panics rather than failing in some environments, e.g.
+// without a rootless Docker socket.
+func skipWithoutDocker(t *testing.T) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Skipf("Docker is not available: %v", r)
+		}
+	}()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+}
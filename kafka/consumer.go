@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -17,17 +18,50 @@ type consumer struct {
 	cli             sarama.ConsumerGroup
 	consumerHandler sarama.ConsumerGroupHandler
 	cfg             *Config
+	topics          []string
 	stop            chan bool
 	quit            *sync.WaitGroup
 }
 
+type ConsumerOptions struct {
+	Retry *RetryPolicy
+}
+
+type ConsumerOption func(*ConsumerOptions)
+
+// WithRetryPolicy republishes the messages that the handler fails to process
+// to the retry and dead-letter topics of the policy, rather than aborting the
+// claim (ConsumerHandler) or skipping them (ConsumerBatchHandler). The consumer
+// subscribes to the retry topics of its topics too.
+func WithRetryPolicy(policy *RetryPolicy) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.Retry = policy
+	}
+}
+
+func newConsumerOptions(opts []ConsumerOption) *ConsumerOptions {
+	options := &ConsumerOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// subscriptions returns the topics of the config and their retry topics.
+func (o *ConsumerOptions) subscriptions(topics []string) []string {
+	if o.Retry == nil {
+		return topics
+	}
+	return append(slices.Clone(topics), o.Retry.Topics(topics)...)
+}
+
 //go:generate mockgen -destination=./mocks/$GOFILE -source=$GOFILE -package=kafkamock
 type Consumer interface {
 	Start()
 	Close()
 }
 
-func NewConsumerClient(cfg *Config, client Client, handler ConsumerHandlerFn) (Consumer, error) {
+func NewConsumerClient(cfg *Config, client Client, handler ConsumerHandlerFn, opts ...ConsumerOption) (Consumer, error) {
 	cli, err := sarama.NewConsumerGroupFromClient(cfg.GroupID, client)
 	if err != nil {
 		return nil, fmt.Errorf("error creating the consumer client: %w", err)
@@ -36,24 +70,25 @@ func NewConsumerClient(cfg *Config, client Client, handler ConsumerHandlerFn) (C
 	return &consumer{
 		cli:             cli,
 		cfg:             cfg,
-		consumerHandler: NewConsumerHandler(handler),
+		consumerHandler: NewConsumerHandler(handler, opts...),
+		topics:          newConsumerOptions(opts).subscriptions(cfg.Topics),
 		stop:            make(chan bool),
 		quit:            &sync.WaitGroup{},
 	}, nil
 }
 
-func NewConsumer(cfg *Config, handler ConsumerHandlerFn) (Consumer, error) {
+func NewConsumer(cfg *Config, handler ConsumerHandlerFn, opts ...ConsumerOption) (Consumer, error) {
 	client, err := NewClient(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("error creating the consumer client: %w", err)
 	}
 
-	return NewConsumerClient(cfg, client, handler)
+	return NewConsumerClient(cfg, client, handler, opts...)
 }
 
 // NewBatchConsumerClient creates a batch consumer using an existing Client.
 // See NewConsumerBatchHandler for batchSize and delayInterval semantics.
-func NewBatchConsumerClient(cfg *Config, client Client, handler ConsumerBatchHandlerFn, batchSize int, delayInterval time.Duration) (Consumer, error) {
+func NewBatchConsumerClient(cfg *Config, client Client, handler ConsumerBatchHandlerFn, batchSize int, delayInterval time.Duration, opts ...ConsumerOption) (Consumer, error) {
 	cli, err := sarama.NewConsumerGroupFromClient(cfg.GroupID, client)
 	if err != nil {
 		return nil, fmt.Errorf("error creating the batch consumer client: %w", err)
@@ -62,7 +97,8 @@ func NewBatchConsumerClient(cfg *Config, client Client, handler ConsumerBatchHan
 	return &consumer{
 		cli:             cli,
 		cfg:             cfg,
-		consumerHandler: NewConsumerBatchHandler(handler, batchSize, delayInterval),
+		consumerHandler: NewConsumerBatchHandler(handler, batchSize, delayInterval, opts...),
+		topics:          newConsumerOptions(opts).subscriptions(cfg.Topics),
 		stop:            make(chan bool),
 		quit:            &sync.WaitGroup{},
 	}, nil
@@ -71,13 +107,13 @@ func NewBatchConsumerClient(cfg *Config, client Client, handler ConsumerBatchHan
 // NewBatchConsumer creates a new consumer that processes messages in batches.
 // The handler is called when batchSize messages have accumulated or delayInterval
 // elapses — whichever comes first.
-func NewBatchConsumer(cfg *Config, handler ConsumerBatchHandlerFn, batchSize int, delayInterval time.Duration) (Consumer, error) {
+func NewBatchConsumer(cfg *Config, handler ConsumerBatchHandlerFn, batchSize int, delayInterval time.Duration, opts ...ConsumerOption) (Consumer, error) {
 	client, err := NewClient(cfg)
 	if err != nil {
 		return nil, fmt.Errorf("error creating the batch consumer client: %w", err)
 	}
 
-	return NewBatchConsumerClient(cfg, client, handler, batchSize, delayInterval)
+	return NewBatchConsumerClient(cfg, client, handler, batchSize, delayInterval, opts...)
 }
 
 func (consumer *consumer) Start() {
@@ -93,7 +129,7 @@ func (consumer *consumer) Start() {
 			// `Consume` should be called inside an infinite loop, when a
 			// server-side rebalance happens, the consumer session will need to be
 			// recreated to get the new claims
-			if err := consumer.cli.Consume(ctx, consumer.cfg.Topics, consumer.consumerHandler); err != nil {
+			if err := consumer.cli.Consume(ctx, consumer.topics, consumer.consumerHandler); err != nil {
 				if errors.Is(err, sarama.ErrClosedConsumerGroup) {
 					return
 				}
@@ -17,6 +17,7 @@ type ConsumerBatchHandler struct {
 	handler       ConsumerBatchHandlerFn
 	batchSize     int
 	delayInterval time.Duration
+	retry         *RetryPolicy
 }
 
 // MessageResult holds the processing outcome for a single message.
@@ -38,17 +39,23 @@ const (
 //
 //   - batchSize: max number of messages per batch. Must be <= ChannelBufferSize of Sarama.
 //   - delayInterval: max time to wait before flushing a partial batch.
-func NewConsumerBatchHandler(handler ConsumerBatchHandlerFn, batchSize int, delayInterval time.Duration) sarama.ConsumerGroupHandler {
+//
+// The failed messages are skipped, unless a RetryPolicy is set with
+// WithRetryPolicy. Then a failed message that can't be republished aborts the
+// claim, and neither it nor the messages after it are marked.
+func NewConsumerBatchHandler(handler ConsumerBatchHandlerFn, batchSize int, delayInterval time.Duration, opts ...ConsumerOption) sarama.ConsumerGroupHandler {
 	if batchSize <= 0 {
 		batchSize = DefaultBatchSize
 	}
 	if delayInterval <= 0 {
 		delayInterval = DefaultDelayInterval
 	}
+	options := newConsumerOptions(opts)
 	return &ConsumerBatchHandler{
 		handler:       handler,
 		batchSize:     batchSize,
 		delayInterval: delayInterval,
+		retry:         options.Retry,
 	}
 }
 
@@ -103,7 +110,15 @@ func (c *ConsumerBatchHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 					zap.Int32("partition", msg.Partition),
 					zap.Int64("offset", msg.Offset),
 					zap.Error(results[i].Error))
-				continue
+				if c.retry == nil {
+					continue
+				}
+				// The claim is aborted, and this message and the ones after it
+				// redelivered, when it can't be republished.
+				if err := c.retry.Fail(session.Context(), msg, results[i].Error); err != nil {
+					batch = batch[:0]
+					return err
+				}
 			}
 			session.MarkMessage(msg, "")
 		}
@@ -122,8 +137,19 @@ func (c *ConsumerBatchHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 					zap.String("topic", claim.Topic()),
 					zap.Int32("partition", claim.Partition()),
 					zap.Int64("next_offset", claim.HighWaterMarkOffset()))
-				_ = flush()
-				return nil
+				return flush()
+			}
+
+			// A retried message is held back until it is due, after the
+			// messages before it are processed.
+			if c.retry != nil && c.retry.delay(message) > 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+				if err := c.retry.Wait(session.Context(), message); err != nil {
+					return nil
+				}
+				ticker.Reset(c.delayInterval)
 			}
 
 			batch = append(batch, message)
@@ -145,8 +171,7 @@ func (c *ConsumerBatchHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 		// `ErrRebalanceInProgress` or i/o timeout on Kafka rebalance.
 		// https://github.com/Shopify/sarama/issues/1192
 		case <-session.Context().Done():
-			_ = flush()
-			return nil
+			return flush()
 		}
 	}
 }
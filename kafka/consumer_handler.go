@@ -12,10 +12,12 @@ import (
 // ConsumerHandler represents a Sarama consumer group consumer
 type ConsumerHandler struct {
 	handler ConsumerHandlerFn
+	retry   *RetryPolicy
 }
 
-func NewConsumerHandler(handler ConsumerHandlerFn) sarama.ConsumerGroupHandler {
-	return &ConsumerHandler{handler: handler}
+func NewConsumerHandler(handler ConsumerHandlerFn, opts ...ConsumerOption) sarama.ConsumerGroupHandler {
+	options := newConsumerOptions(opts)
+	return &ConsumerHandler{handler: handler, retry: options.Retry}
 }
 
 // ConsumerHandlerFn is invoked for each message received by consumer
@@ -46,9 +48,24 @@ func (c *ConsumerHandler) ConsumeClaim(session sarama.ConsumerGroupSession, clai
 				return nil
 			}
 
-			err := c.handler(session.Context(), message)
-			if err != nil {
-				return err
+			if c.retry == nil {
+				if err := c.handler(session.Context(), message); err != nil {
+					return err
+				}
+				session.MarkMessage(message, "")
+				continue
+			}
+
+			// A retried message is held back until it is due.
+			if err := c.retry.Wait(session.Context(), message); err != nil {
+				return nil
+			}
+			if err := c.handler(session.Context(), message); err != nil {
+				// The claim is aborted, and the message redelivered, only when it
+				// can't be republished.
+				if err := c.retry.Fail(session.Context(), message, err); err != nil {
+					return err
+				}
 			}
 			session.MarkMessage(message, "")
 
@@ -0,0 +1,245 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// Headers of the messages republished by a RetryPolicy.
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	// HeaderAttempt is the number of failed attempts so far.
+	HeaderAttempt = "x-attempt"
+	// HeaderNotBefore is when a retried message is due, in Unix milliseconds.
+	HeaderNotBefore = "x-not-before"
+)
+
+type RetryOptions struct {
+	RetryTopic      func(topic string, delay time.Duration) string
+	DeadLetterTopic func(topic string) string
+	Retryable       func(error) bool
+}
+
+type RetryOption func(*RetryOptions)
+
+// WithRetryTopicName sets how the retry topics are named, <topic>.retry.<delay>
+// by default, e.g. orders.retry.1m.
+func WithRetryTopicName(name func(topic string, delay time.Duration) string) RetryOption {
+	return func(o *RetryOptions) {
+		o.RetryTopic = name
+	}
+}
+
+// WithDeadLetterTopicName sets how the dead-letter topic is named,
+// <topic>.dlq by default.
+func WithDeadLetterTopicName(name func(topic string) string) RetryOption {
+	return func(o *RetryOptions) {
+		o.DeadLetterTopic = name
+	}
+}
+
+// WithRetryable sets which handler errors are worth retrying. The other ones
+// are sent to the dead-letter topic right away. Every error is retried by
+// default.
+func WithRetryable(retryable func(error) bool) RetryOption {
+	return func(o *RetryOptions) {
+		o.Retryable = retryable
+	}
+}
+
+func newDefaultRetryOption() *RetryOptions {
+	return &RetryOptions{
+		RetryTopic: func(topic string, delay time.Duration) string {
+			return topic + ".retry." + formatDelay(delay)
+		},
+		DeadLetterTopic: func(topic string) string {
+			return topic + ".dlq"
+		},
+		Retryable: func(error) bool { return true },
+	}
+}
+
+// RetryPolicy republishes the messages that the handler of a consumer failed to
+// process instead of blocking or skipping them. A failed message goes to the
+// retry topic of the first delay, then of the next one every time it fails
+// again, and finally to the dead-letter topic. The consumer subscribes to the
+// retry topics too, and holds a retried message back until its delay is over;
+// as every message of a retry topic has the same delay, they are due in order.
+// The topics must exist, or the brokers must create them.
+//
+// The republished messages keep their key, value and headers, and carry the
+// original topic, partition and offset, the last error and the attempt count in
+// the Header* headers. The dead-letter topic isn't consumed.
+type RetryPolicy struct {
+	producer Producer
+	delays   []time.Duration
+	opts     *RetryOptions
+}
+
+// NewRetryPolicy creates a RetryPolicy that publishes with producer, retrying a
+// message once per delay, e.g. time.Minute then 10*time.Minute. Without delays
+// the failed messages go to the dead-letter topic right away.
+func NewRetryPolicy(producer Producer, delays []time.Duration, opts ...RetryOption) *RetryPolicy {
+	options := newDefaultRetryOption()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return &RetryPolicy{
+		producer: producer,
+		delays:   delays,
+		opts:     options,
+	}
+}
+
+// Topics returns the retry topics of the topics, to subscribe to.
+func (p *RetryPolicy) Topics(topics []string) []string {
+	retryTopics := make([]string, 0, len(topics)*len(p.delays))
+	for _, topic := range topics {
+		for _, delay := range p.delays {
+			retryTopics = append(retryTopics, p.opts.RetryTopic(topic, delay))
+		}
+	}
+	return retryTopics
+}
+
+// Wait blocks until a retried message is due. It returns the context error if
+// ctx is done first, and the message should then be left uncommitted.
+func (p *RetryPolicy) Wait(ctx context.Context, msg *sarama.ConsumerMessage) error {
+	delay := p.delay(msg)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// delay returns how long a retried message is still held back.
+func (p *RetryPolicy) delay(msg *sarama.ConsumerMessage) time.Duration {
+	notBefore, err := strconv.ParseInt(header(msg, HeaderNotBefore), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Until(time.UnixMilli(notBefore))
+}
+
+// Fail republishes a message that the handler failed to process with cause, to
+// its next retry topic or to the dead-letter topic. Once it returns nil, the
+// message can be committed.
+func (p *RetryPolicy) Fail(ctx context.Context, msg *sarama.ConsumerMessage, cause error) error {
+	topic := OriginalTopic(msg)
+	attempt := Attempt(msg) + 1
+
+	out := &sarama.ProducerMessage{Value: sarama.ByteEncoder(msg.Value)}
+	if msg.Key != nil {
+		out.Key = sarama.ByteEncoder(msg.Key)
+	}
+	for _, h := range msg.Headers {
+		if h == nil || isRetryHeader(string(h.Key)) {
+			continue
+		}
+		out.Headers = append(out.Headers, *h)
+	}
+
+	partition, offset := header(msg, HeaderOriginalPartition), header(msg, HeaderOriginalOffset)
+	if header(msg, HeaderOriginalTopic) == "" {
+		partition = strconv.FormatInt(int64(msg.Partition), 10)
+		offset = strconv.FormatInt(msg.Offset, 10)
+	}
+	out.Headers = append(out.Headers,
+		recordHeader(HeaderOriginalTopic, topic),
+		recordHeader(HeaderOriginalPartition, partition),
+		recordHeader(HeaderOriginalOffset, offset),
+		recordHeader(HeaderError, cause.Error()),
+		recordHeader(HeaderAttempt, strconv.Itoa(attempt)),
+	)
+
+	if attempt <= len(p.delays) && p.opts.Retryable(cause) {
+		delay := p.delays[attempt-1]
+		out.Topic = p.opts.RetryTopic(topic, delay)
+		out.Headers = append(out.Headers,
+			recordHeader(HeaderNotBefore, strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10)))
+	} else {
+		out.Topic = p.opts.DeadLetterTopic(topic)
+	}
+
+	if _, _, err := p.producer.SendMessage(out); err != nil {
+		return fmt.Errorf("republish message to %s: %w", out.Topic, err)
+	}
+	log.For(ctx).Warn("Message republished after a failure",
+		zap.String("topic", msg.Topic),
+		zap.Int32("partition", msg.Partition),
+		zap.Int64("offset", msg.Offset),
+		zap.String("to", out.Topic),
+		zap.Int("attempt", attempt),
+		zap.Error(cause))
+	return nil
+}
+
+// OriginalTopic returns the topic a message was first published to, which
+// differs from its topic when it is being retried.
+func OriginalTopic(msg *sarama.ConsumerMessage) string {
+	if topic := header(msg, HeaderOriginalTopic); topic != "" {
+		return topic
+	}
+	return msg.Topic
+}
+
+// Attempt returns the number of times the message failed to be processed
+// before, 0 unless it is being retried.
+func Attempt(msg *sarama.ConsumerMessage) int {
+	attempt, _ := strconv.Atoi(header(msg, HeaderAttempt))
+	return attempt
+}
+
+func header(msg *sarama.ConsumerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func recordHeader(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
+
+func isRetryHeader(key string) bool {
+	switch key {
+	case HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset, HeaderError, HeaderAttempt, HeaderNotBefore:
+		return true
+	}
+	return false
+}
+
+// formatDelay formats a delay in its largest whole unit, e.g. 10m or 90s.
+func formatDelay(d time.Duration) string {
+	switch {
+	case d >= time.Hour && d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d >= time.Minute && d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	case d >= time.Second && d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	default:
+		return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+	}
+}
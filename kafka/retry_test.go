@@ -0,0 +1,215 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProducer struct {
+	Producer
+	mu   sync.Mutex
+	sent []*sarama.ProducerMessage
+	err  error
+}
+
+func (p *fakeProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return 0, 0, p.err
+	}
+	p.sent = append(p.sent, msg)
+	return 0, int64(len(p.sent)), nil
+}
+
+type fakeSession struct {
+	sarama.ConsumerGroupSession
+	ctx    context.Context
+	mu     sync.Mutex
+	marked []int64
+}
+
+func (s *fakeSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.marked = append(s.marked, msg.Offset)
+}
+
+type fakeClaim struct {
+	sarama.ConsumerGroupClaim
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage {
+	return c.messages
+}
+
+func (c *fakeClaim) Topic() string              { return "orders" }
+func (c *fakeClaim) Partition() int32           { return 0 }
+func (c *fakeClaim) InitialOffset() int64       { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64 { return 0 }
+
+func newFakeClaim(messages ...*sarama.ConsumerMessage) *fakeClaim {
+	ch := make(chan *sarama.ConsumerMessage, len(messages))
+	for _, msg := range messages {
+		ch <- msg
+	}
+	close(ch)
+	return &fakeClaim{messages: ch}
+}
+
+// consumed turns a republished message back into a consumed one.
+func consumed(msg *sarama.ProducerMessage, offset int64) *sarama.ConsumerMessage {
+	out := &sarama.ConsumerMessage{Topic: msg.Topic, Partition: 3, Offset: offset}
+	out.Key, _ = msg.Key.Encode()
+	out.Value, _ = msg.Value.Encode()
+	for _, h := range msg.Headers {
+		out.Headers = append(out.Headers, &sarama.RecordHeader{Key: h.Key, Value: h.Value})
+	}
+	return out
+}
+
+func producedHeader(msg *sarama.ProducerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	producer := &fakeProducer{}
+	policy := NewRetryPolicy(producer, []time.Duration{time.Minute, 10 * time.Minute})
+
+	assert.Equal(t, []string{"orders.retry.1m", "orders.retry.10m"}, policy.Topics([]string{"orders"}))
+
+	msg := &sarama.ConsumerMessage{
+		Topic: "orders", Partition: 1, Offset: 42, Key: []byte("k"), Value: []byte("v"),
+		Headers: []*sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("t")}},
+	}
+	cause := errors.New("boom")
+
+	// Every failure moves the message one tier further, then to the DLQ.
+	for i, topic := range []string{"orders.retry.1m", "orders.retry.10m", "orders.dlq"} {
+		assert.NoError(t, policy.Fail(ctx, msg, cause))
+		out := producer.sent[i]
+		assert.Equal(t, topic, out.Topic)
+		assert.Equal(t, "orders", producedHeader(out, HeaderOriginalTopic))
+		assert.Equal(t, "1", producedHeader(out, HeaderOriginalPartition))
+		assert.Equal(t, "42", producedHeader(out, HeaderOriginalOffset))
+		assert.Equal(t, "boom", producedHeader(out, HeaderError))
+		assert.Equal(t, strconv.Itoa(i+1), producedHeader(out, HeaderAttempt))
+		assert.Equal(t, "t", producedHeader(out, "traceparent"))
+
+		msg = consumed(out, int64(i))
+		assert.Equal(t, "orders", OriginalTopic(msg))
+		assert.Equal(t, i+1, Attempt(msg))
+	}
+	assert.Empty(t, producedHeader(producer.sent[2], HeaderNotBefore))
+	assert.Len(t, producer.sent[2].Headers, 6)
+
+	// A retried message is held back until it is due.
+	notBefore := producedHeader(producer.sent[0], HeaderNotBefore)
+	held := &sarama.ConsumerMessage{Headers: []*sarama.RecordHeader{{Key: []byte(HeaderNotBefore), Value: []byte(notBefore)}}}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.ErrorIs(t, policy.Wait(canceled, held), context.Canceled)
+	assert.NoError(t, policy.Wait(canceled, &sarama.ConsumerMessage{}))
+
+	// A non-retryable error goes to the DLQ right away.
+	policy = NewRetryPolicy(producer, []time.Duration{time.Minute},
+		WithRetryable(func(err error) bool { return false }),
+		WithDeadLetterTopicName(func(topic string) string { return "dead-" + topic }))
+	assert.NoError(t, policy.Fail(ctx, &sarama.ConsumerMessage{Topic: "orders"}, cause))
+	assert.Equal(t, "dead-orders", producer.sent[3].Topic)
+	assert.Nil(t, producer.sent[3].Key)
+
+	producer.err = errors.New("broker down")
+	assert.ErrorContains(t, policy.Fail(ctx, &sarama.ConsumerMessage{Topic: "orders"}, cause), "republish message to dead-orders")
+}
+
+func TestConsumerHandler_Retry(t *testing.T) {
+	failing := func(ctx context.Context, msg *sarama.ConsumerMessage) error {
+		if string(msg.Value) == "bad" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	messages := func() *fakeClaim {
+		return newFakeClaim(
+			&sarama.ConsumerMessage{Topic: "orders", Offset: 1, Value: []byte("good")},
+			&sarama.ConsumerMessage{Topic: "orders", Offset: 2, Value: []byte("bad")},
+			&sarama.ConsumerMessage{Topic: "orders", Offset: 3, Value: []byte("good")},
+		)
+	}
+
+	t.Run("without policy", func(t *testing.T) {
+		session := &fakeSession{ctx: context.Background()}
+		err := NewConsumerHandler(failing).ConsumeClaim(session, messages())
+		assert.EqualError(t, err, "boom")
+		assert.Equal(t, []int64{1}, session.marked)
+	})
+
+	t.Run("with policy", func(t *testing.T) {
+		producer := &fakeProducer{}
+		session := &fakeSession{ctx: context.Background()}
+		handler := NewConsumerHandler(failing, WithRetryPolicy(NewRetryPolicy(producer, []time.Duration{time.Minute})))
+		assert.NoError(t, handler.ConsumeClaim(session, messages()))
+		assert.Equal(t, []int64{1, 2, 3}, session.marked)
+		assert.Len(t, producer.sent, 1)
+		assert.Equal(t, "orders.retry.1m", producer.sent[0].Topic)
+	})
+
+	t.Run("republish failure aborts the claim", func(t *testing.T) {
+		producer := &fakeProducer{err: errors.New("broker down")}
+		session := &fakeSession{ctx: context.Background()}
+		handler := NewConsumerHandler(failing, WithRetryPolicy(NewRetryPolicy(producer, nil)))
+		assert.ErrorContains(t, handler.ConsumeClaim(session, messages()), "broker down")
+		assert.Equal(t, []int64{1}, session.marked)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		producer := &fakeProducer{}
+		session := &fakeSession{ctx: context.Background()}
+		batch := func(ctx context.Context, msgs []*sarama.ConsumerMessage) []MessageResult {
+			results := make([]MessageResult, len(msgs))
+			for i, msg := range msgs {
+				results[i] = MessageResult{Offset: msg.Offset, Error: failing(ctx, msg)}
+			}
+			return results
+		}
+		handler := NewConsumerBatchHandler(batch, 10, time.Hour, WithRetryPolicy(NewRetryPolicy(producer, nil)))
+		assert.NoError(t, handler.ConsumeClaim(session, messages()))
+		assert.Equal(t, []int64{1, 2, 3}, session.marked)
+		assert.Len(t, producer.sent, 1)
+		assert.Equal(t, "orders.dlq", producer.sent[0].Topic)
+	})
+
+	t.Run("batch republish failure aborts the claim", func(t *testing.T) {
+		producer := &fakeProducer{err: errors.New("broker down")}
+		session := &fakeSession{ctx: context.Background()}
+		batch := func(ctx context.Context, msgs []*sarama.ConsumerMessage) []MessageResult {
+			results := make([]MessageResult, len(msgs))
+			for i, msg := range msgs {
+				results[i] = MessageResult{Offset: msg.Offset, Error: failing(ctx, msg)}
+			}
+			return results
+		}
+		handler := NewConsumerBatchHandler(batch, 10, time.Hour, WithRetryPolicy(NewRetryPolicy(producer, nil)))
+		assert.ErrorContains(t, handler.ConsumeClaim(session, messages()), "broker down")
+		assert.Equal(t, []int64{1}, session.marked)
+	})
+}
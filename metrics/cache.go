@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"time"
+)
+
+var (
+	cacheLabels     = []string{"service_name", "store"}
+	cacheLoadLabels = []string{"service_name", "store", "operation"}
+
+	compressionRatioBuckets = []float64{.1, .2, .3, .4, .5, .6, .7, .8, .9, 1}
+)
+
+// CacheHits counts keys served from the cache named store. It is a no-op until
+// NewServerMonitor is called.
+func CacheHits(store string, n int) {
+	if monitor == nil || n <= 0 {
+		return
+	}
+	monitor.cacheHits.WithLabelValues(monitor.serviceName, store).Add(float64(n))
+}
+
+// CacheMisses counts keys missing from the cache named store.
+func CacheMisses(store string, n int) {
+	if monitor == nil || n <= 0 {
+		return
+	}
+	monitor.cacheMisses.WithLabelValues(monitor.serviceName, store).Add(float64(n))
+}
+
+// CacheLoad records a loader call of the cache named store. operation is the
+// loader method, e.g. "load" or "bulk_load".
+func CacheLoad(store, operation string, err error, elapsed time.Duration) {
+	if monitor == nil {
+		return
+	}
+	monitor.cacheLoads.WithLabelValues(monitor.serviceName, store, operation).Inc()
+	monitor.cacheLoadSeconds.WithLabelValues(monitor.serviceName, store, operation).Observe(elapsed.Seconds())
+	if err != nil {
+		monitor.cacheLoadErrors.WithLabelValues(monitor.serviceName, store, operation).Inc()
+	}
+}
+
+// CacheCompression records the compression ratio of a value written to the cache
+// named store.
+func CacheCompression(store, algorithm string, raw, compressed int) {
+	if monitor == nil || raw <= 0 {
+		return
+	}
+	monitor.cacheCompressionRatio.WithLabelValues(monitor.serviceName, store, algorithm).Observe(float64(compressed) / float64(raw))
+}
+
+// CacheHotKey records the estimated reads per second of a hot key of the cache
+// named store.
+func CacheHotKey(store, key string, qps float64) {
+	if monitor == nil {
+		return
+	}
+	monitor.cacheHotKeys.WithLabelValues(monitor.serviceName, store, key).Set(qps)
+}
+
+// CacheHotKeyCooled removes a key that is no longer hot from the hot keys of
+// the cache named store.
+func CacheHotKeyCooled(store, key string) {
+	if monitor == nil {
+		return
+	}
+	monitor.cacheHotKeys.DeleteLabelValues(monitor.serviceName, store, key)
+}
@@ -13,6 +13,9 @@ func WrapOnStateChange(changeFunc OnStateChangeFunc) OnStateChangeFunc {
 		if changeFunc != nil {
 			changeFunc(name, from, to)
 		}
+		if monitor == nil {
+			return
+		}
 		monitor.circuitBreakerState.WithLabelValues(monitor.serviceName, name, to.String()).Set(float64(to))
 	}
 }
@@ -23,6 +26,9 @@ func WrapIsSuccessful(name string, isSuccessfulFunc IsSuccessfulFunc) IsSuccessf
 		if isSuccessfulFunc != nil {
 			isSuccessful = isSuccessfulFunc(err)
 		}
+		if monitor == nil {
+			return isSuccessful
+		}
 		monitor.requestCounter.WithLabelValues(monitor.serviceName, name).Inc()
 		if isSuccessful {
 			monitor.successCounter.WithLabelValues(monitor.serviceName, name).Inc()
@@ -16,6 +16,13 @@ type Monitor struct {
 	requestCounter        *prom.CounterVec
 	successCounter        *prom.CounterVec
 	failureCounter        *prom.CounterVec
+	cacheHits             *prom.CounterVec
+	cacheMisses           *prom.CounterVec
+	cacheLoads            *prom.CounterVec
+	cacheLoadErrors       *prom.CounterVec
+	cacheLoadSeconds      *prom.HistogramVec
+	cacheCompressionRatio *prom.HistogramVec
+	cacheHotKeys          *prom.GaugeVec
 }
 
 const (
@@ -107,6 +114,57 @@ func NewServerMonitor(serviceName string) *Monitor {
 			},
 			[]string{"service_name", "name"},
 		),
+		cacheHits: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: "cache_hits_total",
+				Help: "Total number of keys served from the cache.",
+			},
+			cacheLabels,
+		),
+		cacheMisses: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: "cache_misses_total",
+				Help: "Total number of keys missing from the cache, loaded or not.",
+			},
+			cacheLabels,
+		),
+		cacheLoads: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: "cache_loads_total",
+				Help: "Total number of loader calls.",
+			},
+			cacheLoadLabels,
+		),
+		cacheLoadErrors: prom.NewCounterVec(
+			prom.CounterOpts{
+				Name: "cache_load_errors_total",
+				Help: "Total number of failed loader calls.",
+			},
+			cacheLoadLabels,
+		),
+		cacheLoadSeconds: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:    "cache_load_duration_seconds",
+				Help:    "Histogram of the duration (seconds) of the loader calls.",
+				Buckets: defaultBuckets,
+			},
+			cacheLoadLabels,
+		),
+		cacheCompressionRatio: prom.NewHistogramVec(
+			prom.HistogramOpts{
+				Name:    "cache_compression_ratio",
+				Help:    "Histogram of the compressed to raw size ratio of the cached values.",
+				Buckets: compressionRatioBuckets,
+			},
+			[]string{"service_name", "store", "algorithm"},
+		),
+		cacheHotKeys: prom.NewGaugeVec(
+			prom.GaugeOpts{
+				Name: "cache_hot_key_qps",
+				Help: "Estimated reads per second of the keys currently detected as hot.",
+			},
+			[]string{"service_name", "store", "key"},
+		),
 	}
 	prom.MustRegister(
 		monitor.requestRates,
@@ -115,6 +173,13 @@ func NewServerMonitor(serviceName string) *Monitor {
 		monitor.requestCounter,
 		monitor.successCounter,
 		monitor.failureCounter,
+		monitor.cacheHits,
+		monitor.cacheMisses,
+		monitor.cacheLoads,
+		monitor.cacheLoadErrors,
+		monitor.cacheLoadSeconds,
+		monitor.cacheCompressionRatio,
+		monitor.cacheHotKeys,
 	)
 	return monitor
 }
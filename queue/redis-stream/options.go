@@ -7,6 +7,9 @@ import (
 
 	"github.com/golang-queue/queue"
 	"github.com/golang-queue/queue/core"
+	"github.com/redis/go-redis/v9"
+
+	cacheredis "github.com/trinhdaiphuc/go-kit/cache/redis"
 )
 
 // Option for queue system
@@ -27,6 +30,8 @@ type options struct {
 	maxLength        int64
 	blockTime        time.Duration
 	tls              *tls.Config
+	client           redis.UniversalClient
+	config           *cacheredis.Config
 }
 
 // WithAddr setup the addr of redis
@@ -109,6 +114,24 @@ func WithConnectionString(connectionString string) Option {
 	}
 }
 
+// WithClient uses a client shared with the rest of the service instead of
+// building one. The worker doesn't close it on Shutdown.
+func WithClient(client redis.UniversalClient) Option {
+	return func(w *options) {
+		w.client = client
+	}
+}
+
+// WithConfig builds the client with cacheredis.NewClient, so that the worker
+// connects like the other Redis consumers of the service: ACL user, TLS,
+// Sentinel, timeouts and DB. It takes precedence over the connection string and
+// the address options.
+func WithConfig(cfg *cacheredis.Config) Option {
+	return func(w *options) {
+		w.config = cfg
+	}
+}
+
 // WithRunFunc setup the run func of queue
 func WithRunFunc(fn func(context.Context, core.TaskMessage) error) Option {
 	return func(w *options) {
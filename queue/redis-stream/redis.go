@@ -14,6 +14,8 @@ import (
 	"github.com/golang-queue/queue/core"
 	"github.com/golang-queue/queue/job"
 	"github.com/redis/go-redis/v9"
+
+	cacheredis "github.com/trinhdaiphuc/go-kit/cache/redis"
 )
 
 var _ core.Worker = (*Worker)(nil)
@@ -29,6 +31,9 @@ type Worker struct {
 	stop      chan struct{}
 	exit      chan struct{}
 	opts      options
+	// closeClient closes the client built by the worker, nil for an injected
+	// client.
+	closeClient func()
 }
 
 // NewWorker for struc
@@ -41,15 +46,26 @@ func NewWorker(opts ...Option) *Worker {
 		tasks: make(chan redis.XMessage),
 	}
 
-	if w.opts.connectionString != "" {
+	switch {
+	case w.opts.client != nil:
+		w.rdb = w.opts.client
+	case w.opts.config != nil:
+		client, cleanup, err := cacheredis.NewClient(w.opts.config)
+		if err != nil {
+			w.opts.logger.Fatal(err)
+		}
+		w.rdb, w.closeClient = client, cleanup
+	case w.opts.connectionString != "":
 		options, err := redis.ParseURL(w.opts.connectionString)
 		if err != nil {
 			w.opts.logger.Fatal(err)
 		}
-		w.rdb = redis.NewClient(options)
-	} else if w.opts.addr != "" {
+		client := redis.NewClient(options)
+		w.rdb, w.closeClient = client, func() { _ = client.Close() }
+	case w.opts.addr != "":
+		var client redis.UniversalClient
 		if w.opts.cluster {
-			w.rdb = redis.NewClusterClient(&redis.ClusterOptions{
+			client = redis.NewClusterClient(&redis.ClusterOptions{
 				Addrs:     strings.Split(w.opts.addr, ","),
 				Username:  w.opts.username,
 				Password:  w.opts.password,
@@ -63,8 +79,9 @@ func NewWorker(opts ...Option) *Worker {
 				DB:        w.opts.db,
 				TLSConfig: w.opts.tls,
 			}
-			w.rdb = redis.NewClient(options)
+			client = redis.NewClient(options)
 		}
+		w.rdb, w.closeClient = client, func() { _ = client.Close() }
 	}
 
 	_, err = w.rdb.Ping(context.Background()).Result()
@@ -151,11 +168,8 @@ func (w *Worker) Shutdown() error {
 		case <-time.After(200 * time.Millisecond):
 		}
 
-		switch v := w.rdb.(type) {
-		case *redis.Client:
-			v.Close()
-		case *redis.ClusterClient:
-			v.Close()
+		if w.closeClient != nil {
+			w.closeClient()
 		}
 		close(w.tasks)
 	})
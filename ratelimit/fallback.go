@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+type fallbackLimiter struct {
+	primary  Limiter
+	fallback Limiter
+}
+
+// NewFallback returns a Limiter asking primary, and fallback when primary
+// fails, e.g. a Local limiter while Redis is unavailable. The failures are
+// logged. A Local fallback enforces the limit per replica rather than across
+// them.
+func NewFallback(primary, fallback Limiter) Limiter {
+	return &fallbackLimiter{primary: primary, fallback: fallback}
+}
+
+func (f *fallbackLimiter) AllowN(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	res, err := f.primary.AllowN(ctx, key, limit, n)
+	if err == nil || errors.Is(err, ErrInvalidLimit) || ctx.Err() != nil {
+		return res, err
+	}
+
+	log.For(ctx).Warn("Rate limiter failed, falling back", zap.String("key", key), zap.Error(err))
+	return f.fallback.AllowN(ctx, key, limit, n)
+}
@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"github.com/trinhdaiphuc/go-kit/errorx"
+)
+
+// Headers of the IETF draft "RateLimit header fields for HTTP", plus
+// Retry-After.
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderPolicy     = "RateLimit-Policy"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// SetHeaders sets the rate limit headers of res on h, and Retry-After when the
+// events were denied. The durations are in seconds, rounded up.
+func SetHeaders(h http.Header, res *Result) {
+	h.Set(HeaderLimit, strconv.Itoa(res.Limit.Rate))
+	h.Set(HeaderRemaining, strconv.Itoa(res.Remaining))
+	h.Set(HeaderReset, strconv.Itoa(seconds(res.ResetAfter)))
+	h.Set(HeaderPolicy, fmt.Sprintf("%d;w=%d", res.Limit.Rate, seconds(res.Limit.Period)))
+	if !res.Allowed {
+		h.Set(HeaderRetryAfter, strconv.Itoa(seconds(res.RetryAfter)))
+	}
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// QuotaFailure describes the limit of subject that res exceeded.
+func QuotaFailure(subject string, res *Result) *errorx.QuotaFailure {
+	return &errorx.QuotaFailure{
+		Violations: []*errorx.QuotaFailureViolation{{
+			Subject:     subject,
+			Description: fmt.Sprintf("Limit of %d requests per %s exceeded, retry after %s", res.Limit.Rate, res.Limit.Period, res.RetryAfter),
+		}},
+	}
+}
+
+// Error returns the RESOURCE_EXHAUSTED error of a denied result, with its
+// QuotaFailure.
+func Error(subject string, res *Result) *errorx.ErrorWrapper {
+	return errorx.New("Rate limit exceeded").
+		WithCodeFromStatus(codes.ResourceExhausted).
+		WithDetails(QuotaFailure(subject, res))
+}
@@ -0,0 +1,115 @@
+// Package ratelimit limits the rate of events by key, e.g. the requests of a
+// client or the calls to a partner service. The Redis limiters share a limit
+// across replicas, the Local limiter holds it in process.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLimited is returned by Wait when the events can't be allowed before the
+// deadline of the context.
+var ErrLimited = errors.New("ratelimit: rate limit exceeded")
+
+// ErrInvalidLimit is returned for a Limit without a rate or a period, or for
+// more events than the Limit lets through at once.
+var ErrInvalidLimit = errors.New("ratelimit: invalid limit")
+
+// Limit is Rate events per Period, in bursts of up to Burst events. A zero
+// Burst is Rate. The sliding window limiter has no bursts.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+func PerSecond(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+func PerMinute(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+func PerHour(rate int) Limit {
+	return Limit{Rate: rate, Period: time.Hour}
+}
+
+// WithBurst returns the limit with bursts of up to burst events.
+func (l Limit) WithBurst(burst int) Limit {
+	l.Burst = burst
+	return l
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d per %s (burst %d)", l.Rate, l.Period, l.burst())
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// check validates the limit for n events at once.
+func (l Limit) check(n, most int) error {
+	if l.Rate <= 0 || l.Period <= 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidLimit, l)
+	}
+	if n < 1 || n > most {
+		return fmt.Errorf("%w: %d events at once for %s", ErrInvalidLimit, n, l)
+	}
+	return nil
+}
+
+// Result is the outcome of a Limiter call.
+type Result struct {
+	Limit   Limit
+	Allowed bool
+	// Remaining is the number of events still allowed right away.
+	Remaining int
+	// RetryAfter is the time until the denied events would be allowed, zero when
+	// they were allowed.
+	RetryAfter time.Duration
+	// ResetAfter is the time until the limit is fully available again.
+	ResetAfter time.Duration
+}
+
+// Limiter allows or denies events by key.
+type Limiter interface {
+	// AllowN reports whether n events of key may happen now under limit, and
+	// counts them when they may.
+	AllowN(ctx context.Context, key string, limit Limit, n int) (*Result, error)
+}
+
+// Allow is AllowN for one event.
+func Allow(ctx context.Context, l Limiter, key string, limit Limit) (*Result, error) {
+	return l.AllowN(ctx, key, limit, 1)
+}
+
+// Wait blocks until an event of key is allowed. It returns ErrLimited without
+// waiting when the event wouldn't be allowed before the deadline of ctx.
+func Wait(ctx context.Context, l Limiter, key string, limit Limit) (*Result, error) {
+	for {
+		res, err := l.AllowN(ctx, key, limit, 1)
+		if err != nil || res.Allowed {
+			return res, err
+		}
+
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < res.RetryAfter {
+			return res, ErrLimited
+		}
+
+		timer := time.NewTimer(res.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, fmt.Errorf("%w: %w", ErrLimited, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
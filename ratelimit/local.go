@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// sweepInterval is how often Local forgets the keys whose bucket is full again.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	limiter *rate.Limiter
+	limit   Limit
+	// full is when the bucket is full again after the last event.
+	full time.Time
+}
+
+// Local is an in-process Limiter with a token bucket per key: the bucket holds
+// up to Limit.Burst tokens and gains Limit.Rate tokens per Limit.Period. The
+// limit isn't shared between the replicas, see NewFallback.
+type Local struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+var _ Limiter = (*Local)(nil)
+
+func NewLocal() *Local {
+	return &Local{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *Local) AllowN(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	if err := limit.check(n, limit.burst()); err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	perSecond := rate.Limit(float64(limit.Rate) / limit.Period.Seconds())
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(perSecond, limit.burst()), limit: limit}
+		l.buckets[key] = b
+	} else if b.limit != limit {
+		b.limiter.SetLimitAt(now, perSecond)
+		b.limiter.SetBurstAt(now, limit.burst())
+		b.limit = limit
+	}
+
+	res := &Result{Limit: limit, Allowed: true}
+	r := b.limiter.ReserveN(now, n)
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		res.Allowed = false
+		res.RetryAfter = delay
+	}
+
+	tokens := b.limiter.TokensAt(now)
+	res.Remaining = max(int(math.Floor(tokens)), 0)
+	res.ResetAfter = time.Duration((float64(limit.burst()) - tokens) / float64(perSecond) * float64(time.Second))
+	b.full = now.Add(res.ResetAfter)
+	return res, nil
+}
+
+// sweep drops the buckets that are full again, they are the same as new ones.
+func (l *Local) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
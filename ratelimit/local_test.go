@@ -0,0 +1,109 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewLocal()
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+	limit := PerSecond(10).WithBurst(2)
+
+	for want := 1; want >= 0; want-- {
+		res, err := Allow(ctx, limiter, "a", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, want, res.Remaining)
+	}
+	res, err := Allow(ctx, limiter, "a", limit)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 100*time.Millisecond, res.RetryAfter)
+	assert.Equal(t, 200*time.Millisecond, res.ResetAfter)
+
+	// The keys have their own bucket.
+	res, err = Allow(ctx, limiter, "b", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	now = now.Add(100 * time.Millisecond)
+	res, err = Allow(ctx, limiter, "a", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// The full buckets are forgotten.
+	now = now.Add(sweepInterval)
+	_, err = Allow(ctx, limiter, "c", limit)
+	assert.NoError(t, err)
+	assert.Len(t, limiter.buckets, 1)
+
+	_, err = limiter.AllowN(ctx, "a", limit, 3)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+}
+
+func TestWait(t *testing.T) {
+	limiter := NewLocal()
+	limit := PerSecond(50).WithBurst(1)
+
+	start := time.Now()
+	for range 3 {
+		res, err := Wait(context.Background(), limiter, "a", limit)
+		assert.NoError(t, err)
+		assert.True(t, res.Allowed)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := Wait(ctx, limiter, "b", PerHour(1))
+	assert.NoError(t, err)
+	_, err = Wait(ctx, limiter, "b", PerHour(1))
+	assert.ErrorIs(t, err, ErrLimited)
+}
+
+func TestNewFallback(t *testing.T) {
+	ctx := context.Background()
+	client, mock := redismock.NewClientMock()
+	limiter := NewFallback(NewGCRA(client), NewLocal())
+	limit := PerMinute(1)
+
+	mock.ExpectEvalSha(gcraScript.Hash(), []string{"ratelimit:a"}, 1, int64(60000), 1, 1).
+		SetErr(errors.New("connection refused"))
+	res, err := Allow(ctx, limiter, "a", limit)
+	assert.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	// The invalid limits aren't retried.
+	_, err = limiter.AllowN(ctx, "a", limit, 2)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSetHeaders(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h, &Result{
+		Limit:      PerMinute(100),
+		RetryAfter: 1500 * time.Millisecond,
+		ResetAfter: 30 * time.Second,
+	})
+	assert.Equal(t, http.Header{
+		"Ratelimit-Limit":     {"100"},
+		"Ratelimit-Remaining": {"0"},
+		"Ratelimit-Reset":     {"30"},
+		"Ratelimit-Policy":    {"100;w=60"},
+		"Retry-After":         {"2"},
+	}, h)
+
+	err := Error("user:1", &Result{Limit: PerMinute(100), RetryAfter: time.Second})
+	assert.Equal(t, 429, err.GetCode())
+	assert.Equal(t, "user:1", err.ErrorBody.Details.QuotaFailure.Violations[0].Subject)
+}
@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Both scripts read the time of the Redis server, so that the replicas share a
+// clock, and return {allowed, remaining, retry after in ms, reset after in ms}.
+
+// gcraScript is the generic cell rate algorithm: KEYS[1] holds the theoretical
+// arrival time (TAT) of the next event in ms. An event is allowed when it
+// arrives no earlier than its TAT minus the burst tolerance.
+// ARGV[1]: rate, ARGV[2]: period in ms, ARGV[3]: burst, ARGV[4]: events.
+var gcraScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + tonumber(time[2]) / 1000
+local interval = tonumber(ARGV[2]) / tonumber(ARGV[1])
+local tolerance = interval * tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local tat = tonumber(redis.call('GET', KEYS[1])) or now
+tat = math.max(tat, now)
+local new_tat = tat + interval * cost
+local allow_at = new_tat - tolerance
+if now < allow_at then
+  local remaining = math.floor((now - tat + tolerance) / interval)
+  return {0, math.max(remaining, 0), math.ceil(allow_at - now), math.ceil(tat - now)}
+end
+local reset = math.ceil(new_tat - now)
+redis.call('SET', KEYS[1], string.format('%.3f', new_tat), 'PX', math.max(reset, 1))
+return {1, math.floor((now - allow_at) / interval), 0, reset}
+`)
+
+// slidingWindowScript counts the events of fixed windows in the fields of the
+// hash KEYS[1], and estimates the events of the sliding window as the ones of
+// the current window plus the ones of the previous window weighted by its part
+// still in the sliding window.
+// ARGV[1]: rate, ARGV[2]: period in ms, ARGV[3]: events.
+var slidingWindowScript = redis.NewScript(`
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local window = math.floor(now / period)
+local elapsed = now - window * period
+local curr = tonumber(redis.call('HGET', KEYS[1], window)) or 0
+local prev = tonumber(redis.call('HGET', KEYS[1], window - 1)) or 0
+local count = math.floor(prev * (period - elapsed) / period) + curr
+if count + cost > limit then
+  local retry = period - elapsed
+  local room = limit - cost - curr
+  if room >= 0 and prev > 0 then
+    retry = math.ceil(period * (1 - room / prev)) - elapsed
+  end
+  local reset = period - elapsed
+  if curr > 0 then
+    reset = reset + period
+  end
+  return {0, math.max(limit - count, 0), math.max(retry, 1), reset}
+end
+redis.call('HINCRBY', KEYS[1], window, cost)
+for _, field in ipairs(redis.call('HKEYS', KEYS[1])) do
+  if tonumber(field) < window - 1 then
+    redis.call('HDEL', KEYS[1], field)
+  end
+end
+local reset = 2 * period - elapsed
+redis.call('PEXPIRE', KEYS[1], reset)
+return {1, limit - count - cost, 0, reset}
+`)
+
+type Options struct {
+	// Prefix is prepended to the keys, followed by a colon.
+	Prefix string
+}
+
+type Option func(*Options)
+
+// WithPrefix sets the prefix of the Redis keys, "ratelimit" by default.
+func WithPrefix(prefix string) Option {
+	return func(o *Options) {
+		o.Prefix = prefix
+	}
+}
+
+func newDefaultOptions() *Options {
+	return &Options{
+		Prefix: "ratelimit",
+	}
+}
+
+type redisLimiter struct {
+	client redis.UniversalClient
+	opts   *Options
+	script *redis.Script
+	// args returns the arguments of the script after the key.
+	args func(limit Limit, n int) []any
+	// most is the number of events the limit allows at once.
+	most func(limit Limit) int
+}
+
+// NewGCRA returns a Limiter running the generic cell rate algorithm in Redis.
+// It lets bursts of up to Limit.Burst events through, then spaces the events
+// evenly. A key costs a single string.
+func NewGCRA(client redis.UniversalClient, opts ...Option) Limiter {
+	return newRedisLimiter(client, gcraScript, opts, func(limit Limit, n int) []any {
+		return []any{limit.Rate, limit.Period.Milliseconds(), limit.burst(), n}
+	}, Limit.burst)
+}
+
+// NewSlidingWindow returns a Limiter counting the events of a sliding window of
+// Limit.Period in Redis, approximated from the counts of the current and the
+// previous fixed windows. Limit.Burst is ignored: up to Limit.Rate events can
+// happen at once. A key costs a small hash.
+func NewSlidingWindow(client redis.UniversalClient, opts ...Option) Limiter {
+	return newRedisLimiter(client, slidingWindowScript, opts, func(limit Limit, n int) []any {
+		return []any{limit.Rate, limit.Period.Milliseconds(), n}
+	}, func(limit Limit) int {
+		return limit.Rate
+	})
+}
+
+func newRedisLimiter(client redis.UniversalClient, script *redis.Script, opts []Option, args func(Limit, int) []any, most func(Limit) int) *redisLimiter {
+	options := newDefaultOptions()
+	for _, opt := range opts {
+		opt(options)
+	}
+	return &redisLimiter{client: client, opts: options, script: script, args: args, most: most}
+}
+
+func (r *redisLimiter) AllowN(ctx context.Context, key string, limit Limit, n int) (*Result, error) {
+	if err := limit.check(n, r.most(limit)); err != nil {
+		return nil, err
+	}
+	if limit.Period < time.Millisecond {
+		return nil, fmt.Errorf("%w: period under 1ms", ErrInvalidLimit)
+	}
+
+	if r.opts.Prefix != "" {
+		key = r.opts.Prefix + ":" + key
+	}
+	values, err := r.script.Run(ctx, r.client, []string{key}, r.args(limit, n)...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: run script: %w", err)
+	}
+	if len(values) != 4 {
+		return nil, fmt.Errorf("ratelimit: unexpected script result %v", values)
+	}
+
+	return &Result{
+		Limit:      limit,
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trinhdaiphuc/go-kit/internal/redistest"
+)
+
+func TestNewGCRA(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	limiter := NewGCRA(client)
+	ctx := context.Background()
+	limit := PerMinute(60).WithBurst(10)
+
+	mock.ExpectEvalSha(gcraScript.Hash(), []string{"ratelimit:user:1"}, 60, int64(60000), 10, 1).
+		SetVal([]any{int64(1), int64(9), int64(0), int64(1000)})
+	res, err := Allow(ctx, limiter, "user:1", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, &Result{Limit: limit, Allowed: true, Remaining: 9, ResetAfter: time.Second}, res)
+
+	mock.ExpectEvalSha(gcraScript.Hash(), []string{"ratelimit:user:1"}, 60, int64(60000), 10, 3).
+		SetVal([]any{int64(0), int64(2), int64(1000), int64(8000)})
+	res, err = limiter.AllowN(ctx, "user:1", limit, 3)
+	assert.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	_, err = limiter.AllowN(ctx, "user:1", limit, 11)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	_, err = Allow(ctx, limiter, "user:1", Limit{Rate: 1})
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+
+	mock.ExpectEvalSha(gcraScript.Hash(), []string{"ratelimit:user:1"}, 60, int64(60000), 10, 1).
+		SetErr(errors.New("connection refused"))
+	_, err = Allow(ctx, limiter, "user:1", limit)
+	assert.ErrorContains(t, err, "connection refused")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewSlidingWindow(t *testing.T) {
+	client, mock := redismock.NewClientMock()
+	limiter := NewSlidingWindow(client, WithPrefix("api"))
+	ctx := context.Background()
+	limit := PerSecond(5)
+
+	mock.ExpectEvalSha(slidingWindowScript.Hash(), []string{"api:client"}, 5, int64(1000), 1).
+		SetVal([]any{int64(0), int64(0), int64(250), int64(1500)})
+	res, err := Allow(ctx, limiter, "client", limit)
+	assert.NoError(t, err)
+	assert.Equal(t, &Result{Limit: limit, Remaining: 0, RetryAfter: 250 * time.Millisecond, ResetAfter: 1500 * time.Millisecond}, res)
+
+	// Without bursts, the rate is the most events at once.
+	_, err = limiter.AllowN(ctx, "client", limit.WithBurst(10), 6)
+	assert.ErrorIs(t, err, ErrInvalidLimit)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGCRA_Redis(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	limiter := NewGCRA(client)
+	limit := PerMinute(60).WithBurst(3)
+
+	// The burst goes through at once, then an event every second.
+	for remaining := 2; remaining >= 0; remaining-- {
+		res, err := Allow(ctx, limiter, "burst", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, remaining, res.Remaining)
+	}
+	res, err := Allow(ctx, limiter, "burst", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Zero(t, res.Remaining)
+	assert.InDelta(t, time.Second, res.RetryAfter, float64(100*time.Millisecond))
+	assert.InDelta(t, 3*time.Second, res.ResetAfter, float64(100*time.Millisecond))
+
+	// The key expires when the limit is fully available again.
+	ttl := client.PTTL(ctx, "ratelimit:burst").Val()
+	assert.Positive(t, ttl)
+	assert.LessOrEqual(t, ttl, 3*time.Second)
+
+	// n events take n cells.
+	res, err = limiter.AllowN(ctx, "n", limit, 3)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Zero(t, res.Remaining)
+
+	// The denied events aren't counted: the next one is allowed after RetryAfter.
+	fast := PerSecond(10).WithBurst(1)
+	res, err = Allow(ctx, limiter, "fast", fast)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	res, err = Allow(ctx, limiter, "fast", fast)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	time.Sleep(res.RetryAfter)
+	res, err = Allow(ctx, limiter, "fast", fast)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
+
+func TestSlidingWindow_Redis(t *testing.T) {
+	ctx := context.Background()
+	client := redistest.NewClient(t)
+	limiter := NewSlidingWindow(client)
+	limit := PerMinute(3)
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		res, err := Allow(ctx, limiter, "window", limit)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, remaining, res.Remaining)
+	}
+	res, err := Allow(ctx, limiter, "window", limit)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Zero(t, res.Remaining)
+	assert.Positive(t, res.RetryAfter)
+	assert.LessOrEqual(t, res.RetryAfter, 2*time.Minute)
+
+	// The hash keeps the current and the previous windows, and expires with them.
+	ttl := client.PTTL(ctx, "ratelimit:window").Val()
+	assert.Positive(t, ttl)
+	assert.LessOrEqual(t, ttl, 2*time.Minute)
+	assert.LessOrEqual(t, client.HLen(ctx, "ratelimit:window").Val(), int64(2))
+
+	// Once the events slid out of the window, they are allowed again.
+	fast := PerSecond(3)
+	res, err = limiter.AllowN(ctx, "fast", fast, 3)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+	res, err = Allow(ctx, limiter, "fast", fast)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	time.Sleep(2 * time.Second)
+	res, err = limiter.AllowN(ctx, "fast", fast, 3)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Zero(t, res.Remaining)
+
+	// Wait blocks until the event is allowed.
+	res, err = Wait(ctx, limiter, "fast", fast)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+}
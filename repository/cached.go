@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// WriteMode is how CachedBase keeps the store in sync with the writes.
+type WriteMode int
+
+const (
+	// WriteThrough writes the database, then the store. Created rows are cached
+	// as they are, updated rows are read again, deleted rows are evicted.
+	WriteThrough WriteMode = iota
+	// WriteInvalidate writes the database, then evicts the row from the store.
+	// The next read loads it.
+	WriteInvalidate
+	// WriteBehind writes the store right away and queues the database write,
+	// see CachedBase.
+	WriteBehind
+)
+
+type CachedOptions struct {
+	Mode         WriteMode
+	WriteOptions []cache.WriteOption
+	// ReadFallback makes GetByID load a row missing from a store that has no
+	// Loader, see WithoutReadFallback.
+	ReadFallback bool
+	// FlushInterval, FlushSize, MaxPending, MaxRetries and RetryBackoff tune the
+	// write-behind queue, see WithWriteBehind.
+	FlushInterval time.Duration
+	FlushSize     int
+	MaxPending    int
+	MaxRetries    int
+	RetryBackoff  time.Duration
+}
+
+type CachedOption func(*CachedOptions)
+
+// WithWriteMode sets how the writes reach the store, WriteThrough by default.
+func WithWriteMode(mode WriteMode) CachedOption {
+	return func(o *CachedOptions) {
+		o.Mode = mode
+	}
+}
+
+// WithCacheWriteOptions sets the options of the writes into the store, e.g. a
+// TTL.
+func WithCacheWriteOptions(opts ...cache.WriteOption) CachedOption {
+	return func(o *CachedOptions) {
+		o.WriteOptions = append(o.WriteOptions, opts...)
+	}
+}
+
+// WithoutReadFallback stops GetByID from loading the rows itself. Use it when
+// the store was built with the Loader of the CachedBase: a cached tombstone then
+// isn't bypassed.
+func WithoutReadFallback() CachedOption {
+	return func(o *CachedOptions) {
+		o.ReadFallback = false
+	}
+}
+
+// WithWriteBehind switches to WriteBehind. The queued writes are flushed every
+// interval, or as soon as size of them are queued; a non-positive interval
+// flushes them by size only. At most maxPending writes are queued, the next
+// ones fail with ErrWriteQueueFull.
+func WithWriteBehind(interval time.Duration, size, maxPending int) CachedOption {
+	return func(o *CachedOptions) {
+		o.Mode = WriteBehind
+		o.FlushInterval = interval
+		o.FlushSize = size
+		o.MaxPending = maxPending
+	}
+}
+
+// WithWriteRetry sets how many times a failed write-behind write is retried,
+// doubling the backoff between the attempts.
+func WithWriteRetry(retries int, backoff time.Duration) CachedOption {
+	return func(o *CachedOptions) {
+		o.MaxRetries = retries
+		o.RetryBackoff = backoff
+	}
+}
+
+func newDefaultCachedOption() *CachedOptions {
+	return &CachedOptions{
+		Mode:          WriteThrough,
+		ReadFallback:  true,
+		FlushInterval: time.Second,
+		FlushSize:     100,
+		MaxPending:    10000,
+		MaxRetries:    3,
+		RetryBackoff:  100 * time.Millisecond,
+	}
+}
+
+// CachedBase is a Base whose rows are cached by id in a store. GetByID reads
+// the store, and loads the row with GetByID of the wrapped Base on a miss; the
+// other reads go to the database. The writes keep the store in sync according
+// to the WriteMode. A failed store write after a successful database write is
+// logged, and the row evicted, rather than returned: the database write can't
+// be undone.
+//
+// With WriteBehind, Create, UpdateByID, Updates and Delete write the store and
+// queue the database write, returning 1 row affected and success. Their model
+// must be complete, as it replaces the cached row. A created row without an id
+// is written through instead, since only the database can assign its id. A
+// deleted row isn't loaded again until its delete is flushed. The queue is
+// flushed in order by a background goroutine; a write still failing after the
+// retries is logged and dropped, and its row evicted. UpdateColumns and the
+// writes with clauses are written through. Close flushes the queue.
+type CachedBase[M Model, ID comparable] struct {
+	base   Base[M, ID]
+	store  cache.Store[ID, *M]
+	idOf   func(*M) ID
+	loader *Loader[M, ID]
+	opts   *CachedOptions
+
+	queue *writeQueue[M, ID]
+	once  sync.Once
+}
+
+var _ Base[any, int] = (*CachedBase[any, int])(nil)
+
+// NewCachedBase wraps base with the store. idOf returns the id of a row.
+func NewCachedBase[M Model, ID comparable](base Base[M, ID], store cache.Store[ID, *M], idOf func(*M) ID, opts ...CachedOption) *CachedBase[M, ID] {
+	options := newDefaultCachedOption()
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	c := &CachedBase[M, ID]{
+		base:   base,
+		store:  store,
+		idOf:   idOf,
+		loader: NewLoader(base, idOf, options.WriteOptions...),
+		opts:   options,
+	}
+	if options.Mode == WriteBehind {
+		c.queue = newWriteQueue(c)
+		c.loader.deleted = c.queue.deleted
+		go c.queue.run()
+	}
+	return c
+}
+
+// Loader returns the Loader of the rows, to build the store with. It writes the
+// rows with the WithCacheWriteOptions of the CachedBase.
+func (c *CachedBase[M, ID]) Loader() *Loader[M, ID] {
+	return c.loader
+}
+
+func (c *CachedBase[M, ID]) Create(ctx context.Context, i *M) (*M, error) {
+	var zero ID
+	if id := c.idOf(i); c.queue != nil && id != zero {
+		return i, c.queue.push(ctx, pendingWrite[M, ID]{kind: writeCreate, id: id, model: i})
+	}
+
+	m, err := c.base.Create(ctx, i)
+	if err != nil {
+		return m, err
+	}
+	if c.opts.Mode != WriteInvalidate {
+		c.set(ctx, c.idOf(m), m)
+	} else {
+		// Clears a tombstone cached while the row didn't exist.
+		c.evict(ctx, c.idOf(m))
+	}
+	return m, nil
+}
+
+func (c *CachedBase[M, ID]) List(ctx context.Context, params QueryParams, clauses ...Clause) ([]*M, error) {
+	return c.base.List(ctx, params, clauses...)
+}
+
+func (c *CachedBase[M, ID]) Get(ctx context.Context, i *M) (*M, error) {
+	return c.base.Get(ctx, i)
+}
+
+func (c *CachedBase[M, ID]) GetByID(ctx context.Context, id ID) (*M, error) {
+	m, err := c.store.Get(ctx, id)
+	// A bare ErrorKeyNotFound is a miss the store didn't load.
+	if err == cache.ErrorKeyNotFound && c.opts.ReadFallback { // nolint: errorlint
+		return c.loader.Load(ctx, c.store, id)
+	}
+	return m, err
+}
+
+func (c *CachedBase[M, ID]) UpdateByID(ctx context.Context, id ID, i *M, clauses ...Clause) (rowsAffected int64, err error) {
+	if c.queue != nil && len(clauses) == 0 {
+		if err = c.queue.push(ctx, pendingWrite[M, ID]{kind: writeUpdate, id: id, model: i}); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	rowsAffected, err = c.base.UpdateByID(ctx, id, i, clauses...)
+	if err == nil && rowsAffected > 0 {
+		c.refresh(ctx, id)
+	}
+	return rowsAffected, err
+}
+
+func (c *CachedBase[M, ID]) Updates(ctx context.Context, i *M, clauses ...Clause) (rowsAffected int64, err error) {
+	if c.queue != nil && len(clauses) == 0 {
+		if err = c.queue.push(ctx, pendingWrite[M, ID]{kind: writeUpdate, id: c.idOf(i), model: i}); err != nil {
+			return 0, err
+		}
+		return 1, nil
+	}
+
+	rowsAffected, err = c.base.Updates(ctx, i, clauses...)
+	if err == nil && rowsAffected > 0 {
+		c.refresh(ctx, c.idOf(i))
+	}
+	return rowsAffected, err
+}
+
+func (c *CachedBase[M, ID]) UpdateColumns(ctx context.Context, id ID, columns map[string]any, clauses ...Clause) (rowsAffected int64, err error) {
+	rowsAffected, err = c.base.UpdateColumns(ctx, id, columns, clauses...)
+	if err == nil && rowsAffected > 0 {
+		c.refresh(ctx, id)
+	}
+	return rowsAffected, err
+}
+
+func (c *CachedBase[M, ID]) Count(ctx context.Context, clauses ...Clause) (rowsAffected int64, err error) {
+	return c.base.Count(ctx, clauses...)
+}
+
+func (c *CachedBase[M, ID]) DeleteByID(ctx context.Context, id ID) (success bool, err error) {
+	if c.queue != nil {
+		if err = c.queue.push(ctx, pendingWrite[M, ID]{kind: writeDelete, id: id}); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	success, err = c.base.DeleteByID(ctx, id)
+	if err == nil {
+		c.evict(ctx, id)
+	}
+	return success, err
+}
+
+func (c *CachedBase[M, ID]) Delete(ctx context.Context, i *M) (success bool, err error) {
+	if c.queue != nil {
+		if err = c.queue.push(ctx, pendingWrite[M, ID]{kind: writeDelete, id: c.idOf(i)}); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	success, err = c.base.Delete(ctx, i)
+	if err == nil {
+		c.evict(ctx, c.idOf(i))
+	}
+	return success, err
+}
+
+// Flush writes the queued write-behind writes into the database. It returns
+// the error of the first write that was dropped.
+func (c *CachedBase[M, ID]) Flush(ctx context.Context) error {
+	if c.queue == nil {
+		return nil
+	}
+	return c.queue.flush(ctx)
+}
+
+// Close stops the write-behind goroutine and flushes the queue. The store is
+// left open.
+func (c *CachedBase[M, ID]) Close(ctx context.Context) error {
+	if c.queue == nil {
+		return nil
+	}
+	c.once.Do(c.queue.stop)
+	return c.queue.flush(ctx)
+}
+
+// refresh caches the row as it is in the database after an update.
+func (c *CachedBase[M, ID]) refresh(ctx context.Context, id ID) {
+	if c.opts.Mode != WriteThrough {
+		c.evict(ctx, id)
+		return
+	}
+
+	m, err := c.base.GetByID(ctx, id)
+	if err != nil {
+		log.For(ctx).Warn("Read updated row failed", zap.Any("id", id), zap.Error(err))
+		c.evict(ctx, id)
+		return
+	}
+	c.set(ctx, id, m)
+}
+
+func (c *CachedBase[M, ID]) set(ctx context.Context, id ID, m *M) {
+	if err := c.store.Set(ctx, id, m, c.opts.WriteOptions...); err != nil {
+		log.For(ctx).Error("Cache row failed", zap.Any("id", id), zap.Error(err))
+		c.evict(ctx, id)
+	}
+}
+
+func (c *CachedBase[M, ID]) evict(ctx context.Context, id ID) {
+	if err := c.store.Delete(ctx, id); err != nil {
+		log.For(ctx).Error("Evict cached row failed", zap.Any("id", id), zap.Error(err))
+	}
+}
@@ -0,0 +1,356 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redismock/v9"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	cachelocal "github.com/trinhdaiphuc/go-kit/cache/local"
+	cacheredis "github.com/trinhdaiphuc/go-kit/cache/redis"
+)
+
+type user struct {
+	ID   int
+	Name string
+}
+
+// memoryBase is a Base over a map. Only the methods used by CachedBase are
+// meaningful.
+type memoryBase struct {
+	mu    sync.Mutex
+	rows  map[int]user
+	reads int
+	fails int
+}
+
+func newMemoryBase() *memoryBase {
+	return &memoryBase{rows: make(map[int]user)}
+}
+
+func (b *memoryBase) fail() error {
+	if b.fails > 0 {
+		b.fails--
+		return errors.New("database down")
+	}
+	return nil
+}
+
+func (b *memoryBase) Create(ctx context.Context, i *user) (*user, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.fail(); err != nil {
+		return nil, err
+	}
+	if i.ID == 0 {
+		// Like an auto-increment primary key.
+		i.ID = 1000 + len(b.rows)
+	}
+	b.rows[i.ID] = *i
+	return i, nil
+}
+
+func (b *memoryBase) List(ctx context.Context, params QueryParams, clauses ...Clause) ([]*user, error) {
+	return nil, errors.New("unexpected List")
+}
+
+func (b *memoryBase) Get(ctx context.Context, i *user) (*user, error) {
+	return nil, errors.New("unexpected Get")
+}
+
+func (b *memoryBase) GetByID(ctx context.Context, id int) (*user, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reads++
+	row, ok := b.rows[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &row, nil
+}
+
+func (b *memoryBase) UpdateByID(ctx context.Context, id int, i *user, clauses ...Clause) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.fail(); err != nil {
+		return 0, err
+	}
+	if _, ok := b.rows[id]; !ok {
+		return 0, nil
+	}
+	if i.Name != "" {
+		b.rows[id] = user{ID: id, Name: i.Name}
+	}
+	return 1, nil
+}
+
+func (b *memoryBase) Updates(ctx context.Context, i *user, clauses ...Clause) (int64, error) {
+	return b.UpdateByID(ctx, i.ID, i, clauses...)
+}
+
+func (b *memoryBase) UpdateColumns(ctx context.Context, id int, columns map[string]any, clauses ...Clause) (int64, error) {
+	return 0, errors.New("unexpected UpdateColumns")
+}
+
+func (b *memoryBase) Count(ctx context.Context, clauses ...Clause) (int64, error) {
+	return 0, errors.New("unexpected Count")
+}
+
+func (b *memoryBase) DeleteByID(ctx context.Context, id int) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.fail(); err != nil {
+		return false, err
+	}
+	_, ok := b.rows[id]
+	delete(b.rows, id)
+	return ok, nil
+}
+
+func (b *memoryBase) Delete(ctx context.Context, i *user) (bool, error) {
+	return b.DeleteByID(ctx, i.ID)
+}
+
+func (b *memoryBase) row(id int) (user, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	row, ok := b.rows[id]
+	return row, ok
+}
+
+func userID(u *user) int {
+	return u.ID
+}
+
+// boundLoader forwards to the Loader of a CachedBase, which only exists once the
+// store it is built with does.
+type boundLoader struct {
+	*Loader[user, int]
+}
+
+func TestCachedBase(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("write-through", func(t *testing.T) {
+		base := newMemoryBase()
+		store := cachelocal.NewClient[int, *user]()
+		defer store.Close()
+		repo := NewCachedBase[user, int](base, store, userID)
+
+		_, err := repo.Create(ctx, &user{ID: 1, Name: "John"})
+		assert.NoError(t, err)
+		cached, err := store.Get(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "John", cached.Name)
+
+		// A partial update is cached as the row reads after it.
+		rows, err := repo.UpdateByID(ctx, 1, &user{Name: "Jane"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), rows)
+		got, err := repo.GetByID(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, &user{ID: 1, Name: "Jane"}, got)
+		assert.Equal(t, 1, base.reads)
+
+		ok, err := repo.DeleteByID(ctx, 1)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		_, err = repo.GetByID(ctx, 1)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("reads fall back to the database", func(t *testing.T) {
+		base := newMemoryBase()
+		base.rows[2] = user{ID: 2, Name: "Bob"}
+		store := cachelocal.NewClient[int, *user]()
+		defer store.Close()
+		repo := NewCachedBase[user, int](base, store, userID)
+
+		for range 3 {
+			got, err := repo.GetByID(ctx, 2)
+			assert.NoError(t, err)
+			assert.Equal(t, "Bob", got.Name)
+		}
+		assert.Equal(t, 1, base.reads)
+	})
+
+	t.Run("store loader", func(t *testing.T) {
+		base := newMemoryBase()
+		base.rows[6] = user{ID: 6, Name: "Sam"}
+		loader := &boundLoader{}
+		store := cachelocal.NewClient[int, *user](
+			cachelocal.WithLoader[int, *user](loader),
+			cachelocal.WithTTL[int, *user](time.Minute),
+		)
+		defer store.Close()
+		repo := NewCachedBase[user, int](base, store, userID,
+			WithCacheWriteOptions(cache.WithTTL(time.Hour)), WithoutReadFallback())
+		loader.Loader = repo.Loader()
+
+		got, err := repo.GetByID(ctx, 6)
+		assert.NoError(t, err)
+		assert.Equal(t, "Sam", got.Name)
+
+		// The row loaded by the store keeps the TTL of the CachedBase.
+		ttl, err := store.TTL(ctx, 6)
+		assert.NoError(t, err)
+		assert.InDelta(t, time.Hour, ttl, float64(time.Second))
+		_, err = repo.GetByID(ctx, 6)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, base.reads)
+	})
+
+	t.Run("redis store loader", func(t *testing.T) {
+		base := newMemoryBase()
+		base.rows[7] = user{ID: 7, Name: "Sue"}
+		client, mock := redismock.NewClientMock()
+		loader := &boundLoader{}
+		store := cacheredis.NewRedisCache[int, *user](client,
+			cacheredis.WithLoader[int, *user](loader),
+			cacheredis.WithPrefix[int, *user]("user"),
+			cacheredis.WithTTL[int, *user](time.Minute),
+		)
+		repo := NewCachedBase[user, int](base, store, userID,
+			WithCacheWriteOptions(cache.WithTTL(time.Hour)), WithoutReadFallback())
+		loader.Loader = repo.Loader()
+
+		// The row is written once, by the Loader, with the TTL of the CachedBase.
+		mock.ExpectGet("user:7").RedisNil()
+		mock.ExpectSet("user:7", `{"ID":7,"Name":"Sue"}`, time.Hour).SetVal("OK")
+		got, err := repo.GetByID(ctx, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, "Sue", got.Name)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("invalidate", func(t *testing.T) {
+		base := newMemoryBase()
+		base.rows[3] = user{ID: 3, Name: "Ann"}
+		store := cachelocal.NewClient[int, *user]()
+		defer store.Close()
+		repo := NewCachedBase[user, int](base, store, userID, WithWriteMode(WriteInvalidate))
+
+		_, err := repo.GetByID(ctx, 3)
+		assert.NoError(t, err)
+		_, err = repo.Updates(ctx, &user{ID: 3, Name: "Anna"})
+		assert.NoError(t, err)
+
+		_, err = store.Get(ctx, 3)
+		assert.Error(t, err)
+		got, err := repo.GetByID(ctx, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, "Anna", got.Name)
+	})
+
+	t.Run("write-behind", func(t *testing.T) {
+		base := newMemoryBase()
+		store := cachelocal.NewClient[int, *user]()
+		defer store.Close()
+		repo := NewCachedBase[user, int](base, store, userID,
+			WithWriteBehind(time.Hour, 10, 100), WithWriteRetry(1, time.Millisecond))
+
+		_, err := repo.Create(ctx, &user{ID: 4, Name: "Tom"})
+		assert.NoError(t, err)
+		_, err = repo.Create(ctx, &user{ID: 5, Name: "Tim"})
+		assert.NoError(t, err)
+		_, err = repo.Updates(ctx, &user{ID: 4, Name: "Tommy"})
+		assert.NoError(t, err)
+
+		// The store is ahead of the database until the flush.
+		got, err := repo.GetByID(ctx, 4)
+		assert.NoError(t, err)
+		assert.Equal(t, "Tommy", got.Name)
+		_, ok := base.row(4)
+		assert.False(t, ok)
+
+		assert.NoError(t, repo.Flush(ctx))
+		row, ok := base.row(4)
+		assert.True(t, ok)
+		assert.Equal(t, "Tommy", row.Name)
+
+		// The write fails twice: once, then once more on its retry.
+		base.fails = 2
+		_, err = repo.Updates(ctx, &user{ID: 5, Name: "Timothy"})
+		assert.NoError(t, err)
+		assert.ErrorContains(t, repo.Close(ctx), "update 5: database down")
+		got, err = repo.GetByID(ctx, 5)
+		assert.NoError(t, err)
+		assert.Equal(t, "Tim", got.Name)
+
+		success, err := repo.DeleteByID(ctx, 5)
+		assert.ErrorIs(t, err, ErrWriteQueueClosed)
+		assert.False(t, success)
+	})
+
+	t.Run("write-behind delete", func(t *testing.T) {
+		base := newMemoryBase()
+		base.rows[6] = user{ID: 6, Name: "Tina"}
+		store := cachelocal.NewClient[int, *user]()
+		defer store.Close()
+		repo := NewCachedBase[user, int](base, store, userID, WithWriteBehind(0, 10, 100))
+		defer repo.Close(ctx)
+
+		_, err := repo.GetByID(ctx, 6)
+		assert.NoError(t, err)
+		_, err = repo.DeleteByID(ctx, 6)
+		assert.NoError(t, err)
+
+		// The row is still in the database, but isn't loaded again.
+		_, err = repo.GetByID(ctx, 6)
+		assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+		_, ok := base.row(6)
+		assert.True(t, ok)
+
+		// A row cached while the delete was queued is evicted by the flush.
+		assert.NoError(t, store.Set(ctx, 6, &user{ID: 6, Name: "Tina"}))
+		assert.NoError(t, repo.Flush(ctx))
+		_, ok = base.row(6)
+		assert.False(t, ok)
+		_, err = store.Get(ctx, 6)
+		assert.ErrorIs(t, err, cache.ErrorKeyNotFound)
+	})
+
+	t.Run("write-behind create without an id", func(t *testing.T) {
+		base := newMemoryBase()
+		store := cachelocal.NewClient[int, *user]()
+		defer store.Close()
+		repo := NewCachedBase[user, int](base, store, userID, WithWriteBehind(time.Hour, 10, 100))
+		defer repo.Close(ctx)
+
+		// The database assigns the id, so the create is written through.
+		created, err := repo.Create(ctx, &user{Name: "Tom"})
+		assert.NoError(t, err)
+		assert.NotZero(t, created.ID)
+		_, ok := base.row(created.ID)
+		assert.True(t, ok)
+
+		reads := base.reads
+		got, err := repo.GetByID(ctx, created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "Tom", got.Name)
+		assert.Equal(t, reads, base.reads, "the created row is cached")
+		_, err = store.Get(ctx, 0)
+		assert.Error(t, err, "nothing is cached under the zero id")
+	})
+
+	t.Run("write-behind queue full", func(t *testing.T) {
+		store := cachelocal.NewClient[int, *user]()
+		defer store.Close()
+		repo := NewCachedBase[user, int](newMemoryBase(), store, userID, WithWriteBehind(time.Hour, 10, 1))
+		defer repo.Close(ctx)
+
+		_, err := repo.Create(ctx, &user{ID: 1, Name: "Tom"})
+		assert.NoError(t, err)
+		_, err = repo.Create(ctx, &user{ID: 2, Name: "Ted"})
+		assert.ErrorIs(t, err, ErrWriteQueueFull)
+		rowsAffected, err := repo.UpdateByID(ctx, 1, &user{ID: 1, Name: "Ted"})
+		assert.ErrorIs(t, err, ErrWriteQueueFull)
+		assert.Zero(t, rowsAffected)
+	})
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/trinhdaiphuc/go-kit/cache"
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// Loader is a cache.Loader reading the rows of a Base by id. It isn't a
+// cache.TTLLoader: Load and BulkLoad write the rows into the store themselves,
+// with the write options of the Loader, as cache.Loader allows. A
+// missing row fails with an error matching both cache.ErrorKeyNotFound and
+// gorm.ErrRecordNotFound, so that the store can cache a tombstone.
+type Loader[M Model, ID comparable] struct {
+	base      Base[M, ID]
+	idOf      func(*M) ID
+	writeOpts []cache.WriteOption
+	// deleted reports the rows of a write-behind CachedBase whose delete isn't
+	// flushed yet. They are loaded as missing.
+	deleted func(ID) bool
+}
+
+func NewLoader[M Model, ID comparable](base Base[M, ID], idOf func(*M) ID, opts ...cache.WriteOption) *Loader[M, ID] {
+	return &Loader[M, ID]{
+		base:      base,
+		idOf:      idOf,
+		writeOpts: opts,
+	}
+}
+
+func (l *Loader[M, ID]) Load(ctx context.Context, c cache.Store[ID, *M], id ID) (*M, error) {
+	if l.deleted != nil && l.deleted(id) {
+		return nil, cache.NotFoundError(gorm.ErrRecordNotFound)
+	}
+
+	m, err := l.base.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, cache.NotFoundError(err)
+		}
+		return nil, err
+	}
+
+	if c != nil {
+		if err = c.Set(ctx, id, m, l.writeOpts...); err != nil {
+			log.For(ctx).Error("Cache loaded row failed", zap.Any("id", id), zap.Error(err))
+		}
+	}
+	return m, nil
+}
+
+// LoadAll isn't supported: a row isn't a hash.
+func (l *Loader[M, ID]) LoadAll(ctx context.Context, c cache.Store[ID, *M], id ID) (map[ID]*M, error) {
+	return nil, &cache.UnsupportedError{Op: "load all", Reason: "rows are not hashes"}
+}
+
+// BulkLoad reads the rows with a single query and writes them into the store.
+// The ids without a row are left out of the result.
+func (l *Loader[M, ID]) BulkLoad(ctx context.Context, c cache.Store[ID, *M], ids []ID) (map[ID]*M, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	rows, err := l.base.List(ctx, QueryParams{Limit: int32(len(ids))}, func(tx *gorm.DB) { // nolint: gosec
+		tx.Where("id IN ?", ids)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[ID]*M, len(rows))
+	keyVals := make([]cache.KeyVal[ID, *M], 0, len(rows))
+	for _, row := range rows {
+		if l.deleted != nil && l.deleted(l.idOf(row)) {
+			continue
+		}
+		values[l.idOf(row)] = row
+		keyVals = append(keyVals, cache.KeyVal[ID, *M]{Key: l.idOf(row), Value: row})
+	}
+
+	if c != nil {
+		if err = cache.BulkSet(ctx, c, keyVals, l.writeOpts...); err != nil {
+			log.For(ctx).Error("Cache loaded rows failed", zap.Int("rows", len(keyVals)), zap.Error(err))
+		}
+	}
+	return values, nil
+}
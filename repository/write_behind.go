@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/trinhdaiphuc/go-kit/log"
+)
+
+// ErrWriteQueueFull is returned by the writes of a WriteBehind CachedBase while
+// the queue holds MaxPending writes.
+var ErrWriteQueueFull = errors.New("repository: write-behind queue is full")
+
+// ErrWriteQueueClosed is returned by the writes of a WriteBehind CachedBase
+// after Close.
+var ErrWriteQueueClosed = errors.New("repository: write-behind queue is closed")
+
+type writeKind int
+
+const (
+	writeCreate writeKind = iota
+	writeUpdate
+	writeDelete
+)
+
+func (k writeKind) String() string {
+	switch k {
+	case writeCreate:
+		return "create"
+	case writeUpdate:
+		return "update"
+	default:
+		return "delete"
+	}
+}
+
+type pendingWrite[M Model, ID comparable] struct {
+	kind  writeKind
+	id    ID
+	model *M
+	// seq orders the writes, see writeQueue.deletes.
+	seq uint64
+}
+
+// writeQueue holds the write-behind writes until they are flushed. The writes
+// are flushed one at a time in the order they were queued, so that the writes
+// of a row reach the database in order.
+type writeQueue[M Model, ID comparable] struct {
+	c *CachedBase[M, ID]
+
+	// mu orders the queue and the store writes of push, so that the store
+	// ends up with the last write of a row.
+	mu      sync.Mutex
+	pending []pendingWrite[M, ID]
+	seq     uint64
+	// deletes holds the seq of the last write of the rows whose last queued
+	// write is a delete. The Loader doesn't read them back from the database
+	// until the delete is flushed.
+	deletes map[ID]uint64
+
+	flushMu sync.Mutex
+	full    chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newWriteQueue[M Model, ID comparable](c *CachedBase[M, ID]) *writeQueue[M, ID] {
+	return &writeQueue[M, ID]{
+		c:       c,
+		deletes: make(map[ID]uint64),
+		full:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// push writes the store and queues the database write.
+func (q *writeQueue[M, ID]) push(ctx context.Context, w pendingWrite[M, ID]) error {
+	select {
+	case <-q.done:
+		return ErrWriteQueueClosed
+	default:
+	}
+
+	q.mu.Lock()
+	if len(q.pending) >= max(1, q.c.opts.MaxPending) {
+		q.mu.Unlock()
+		q.signal()
+		return ErrWriteQueueFull
+	}
+	q.seq++
+	w.seq = q.seq
+	q.pending = append(q.pending, w)
+	size := len(q.pending)
+
+	if w.kind == writeDelete {
+		q.deletes[w.id] = w.seq
+		q.c.evict(ctx, w.id)
+	} else {
+		delete(q.deletes, w.id)
+		q.c.set(ctx, w.id, w.model)
+	}
+	q.mu.Unlock()
+
+	if size >= q.c.opts.FlushSize {
+		q.signal()
+	}
+	return nil
+}
+
+func (q *writeQueue[M, ID]) signal() {
+	select {
+	case q.full <- struct{}{}:
+	default:
+	}
+}
+
+// deleted reports whether the last queued write of the row is a delete.
+func (q *writeQueue[M, ID]) deleted(id ID) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.deletes[id]
+	return ok
+}
+
+// settled evicts the row of a delete that reached the database, or was
+// dropped, unless the row was written again since: a read may have cached the
+// row before the delete was flushed.
+func (q *writeQueue[M, ID]) settled(ctx context.Context, w pendingWrite[M, ID]) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if seq, ok := q.deletes[w.id]; ok && seq == w.seq {
+		delete(q.deletes, w.id)
+		q.c.evict(ctx, w.id)
+	}
+}
+
+func (q *writeQueue[M, ID]) run() {
+	defer close(q.stopped)
+
+	// Without an interval, the queue is only flushed by size and by Flush.
+	var tick <-chan time.Time
+	if q.c.opts.FlushInterval > 0 {
+		ticker := time.NewTicker(q.c.opts.FlushInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-tick:
+		case <-q.full:
+		}
+
+		// The dropped writes are already logged.
+		_ = q.flush(context.Background())
+	}
+}
+
+func (q *writeQueue[M, ID]) stop() {
+	close(q.done)
+	<-q.stopped
+}
+
+// flush writes the queued writes batch by batch until the queue is empty.
+func (q *writeQueue[M, ID]) flush(ctx context.Context) error {
+	q.flushMu.Lock()
+	defer q.flushMu.Unlock()
+
+	var firstErr error
+	for {
+		q.mu.Lock()
+		n := min(len(q.pending), max(1, q.c.opts.FlushSize))
+		batch := q.pending[:n:n]
+		q.pending = q.pending[n:]
+		q.mu.Unlock()
+
+		if len(batch) == 0 {
+			return firstErr
+		}
+
+		for _, w := range batch {
+			err := q.write(ctx, w)
+			if w.kind == writeDelete {
+				q.settled(ctx, w)
+			}
+			if err != nil {
+				log.For(ctx).Error("Write-behind write dropped", zap.Stringer("kind", w.kind), zap.Any("id", w.id), zap.Error(err))
+				// The cached row is ahead of a database that won't catch up.
+				q.c.evict(ctx, w.id)
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s %v: %w", w.kind, w.id, err)
+				}
+			}
+		}
+	}
+}
+
+// write applies a write to the database, retrying with an exponential backoff.
+func (q *writeQueue[M, ID]) write(ctx context.Context, w pendingWrite[M, ID]) error {
+	backoff := q.c.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		var err error
+		switch w.kind {
+		case writeCreate:
+			_, err = q.c.base.Create(ctx, w.model)
+		case writeUpdate:
+			_, err = q.c.base.UpdateByID(ctx, w.id, w.model)
+		case writeDelete:
+			_, err = q.c.base.DeleteByID(ctx, w.id)
+		}
+		if err == nil || attempt >= q.c.opts.MaxRetries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}